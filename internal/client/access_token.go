@@ -5,55 +5,157 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"sync"
+	"time"
 )
 
-func getAccessToken(ctx context.Context, config *ClientConfig) (string, error) {
-	if config.AccessToken != nil {
-		return *config.AccessToken, nil
+// tokenRefreshWindow is how long before expiry a minted token is replaced, so
+// that requests never go out with a token about to expire in flight. Tokens
+// that live only a few minutes are replaced after three quarters of their
+// lifetime instead, so that a short lifetime does not mean a new token for
+// every request.
+const tokenRefreshWindow = time.Minute
+
+// refreshWindow returns how long before expiry a token lasting expiresIn is
+// replaced.
+func refreshWindow(expiresIn time.Duration) time.Duration {
+	return min(tokenRefreshWindow, expiresIn/4)
+}
+
+type tokenSource interface {
+	// Token returns a valid access token.
+	Token(ctx context.Context) (string, error)
+	// Invalidate discards token after the server rejected it, if it is still the
+	// current token. It reports whether a fresh token can be obtained.
+	Invalidate(token string) bool
+}
+
+func newTokenSource(c *Client) tokenSource {
+	if c.config.AccessToken != nil {
+		return &staticTokenSource{token: *c.config.AccessToken}
 	}
+	return &clientCredentialsTokenSource{client: c, now: time.Now}
+}
+
+// staticTokenSource serves a user-supplied access token, which cannot be
+// refreshed.
+type staticTokenSource struct {
+	token string
+}
+
+func (s *staticTokenSource) Token(_ context.Context) (string, error) {
+	return s.token, nil
+}
+
+func (s *staticTokenSource) Invalidate(_ string) bool {
+	return false
+}
+
+// clientCredentialsTokenSource mints tokens with the OAuth client credentials
+// grant, authenticating with either a client secret or a private key JWT, and
+// caches them until shortly before they expire. The mutex is held while
+// minting so that concurrent callers share a single token request.
+type clientCredentialsTokenSource struct {
+	client *Client
+	now    func() time.Time
+
+	mu        sync.Mutex
+	token     string
+	refreshAt time.Time
+}
 
-	if config.ClientID == nil || config.ClientSecret == nil {
-		return "", fmt.Errorf("client ID or client secret is not set")
+func (s *clientCredentialsTokenSource) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token != "" && (s.refreshAt.IsZero() || s.now().Before(s.refreshAt)) {
+		return s.token, nil
+	}
+
+	token, expiresIn, err := s.client.mintAccessToken(ctx)
+	if err != nil {
+		return "", err
+	}
+	s.token = token
+	s.refreshAt = time.Time{}
+	if expiresIn > 0 {
+		s.refreshAt = s.now().Add(expiresIn - refreshWindow(expiresIn))
+	}
+	return s.token, nil
+}
+
+func (s *clientCredentialsTokenSource) Invalidate(token string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token == token {
+		s.token = ""
+		s.refreshAt = time.Time{}
 	}
+	return true
+}
 
-	url := "https://auth.zapehr.com/oauth/token"
+func (c *Client) mintAccessToken(ctx context.Context) (string, time.Duration, error) {
+	config := c.config
+	if config.ClientID == nil || (config.ClientSecret == nil && config.PrivateKey == nil) {
+		return "", 0, fmt.Errorf("client ID and either a client secret or a private key must be set")
+	}
+
+	url := config.Endpoints.endpoint(serviceAuth) + "/oauth/token"
 	data := map[string]string{
-		"grant_type":    "client_credentials",
-		"client_id":     *config.ClientID,
-		"client_secret": *config.ClientSecret,
-		"audience":      "https://api.zapehr.com",
+		"grant_type": "client_credentials",
+		"client_id":  *config.ClientID,
+		"audience":   "https://api.zapehr.com",
+	}
+	if config.PrivateKey != nil {
+		var keyID string
+		if config.KeyID != nil {
+			keyID = *config.KeyID
+		}
+		assertion, err := signClientAssertion(config.PrivateKey, keyID, *config.ClientID, url, time.Now())
+		if err != nil {
+			return "", 0, err
+		}
+		data["client_assertion_type"] = clientAssertionType
+		data["client_assertion"] = assertion
+	} else {
+		data["client_secret"] = *config.ClientSecret
 	}
 
 	jsonData, err := json.Marshal(data)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal request data: %w", err)
+		return "", 0, fmt.Errorf("failed to marshal request data: %w", err)
 	}
 
 	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
+		return "", 0, fmt.Errorf("failed to create request: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("failed to make request: %w", err)
+		return "", 0, fmt.Errorf("failed to make request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return "", fmt.Errorf("received non-2xx response: %d", resp.StatusCode)
+		responseBody, _ := io.ReadAll(resp.Body)
+		return "", 0, newAPIError(http.MethodPost, url, resp.StatusCode, resp.Header, responseBody)
 	}
 
 	var result struct {
 		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return "", fmt.Errorf("failed to decode response: %w", err)
+		return "", 0, fmt.Errorf("failed to decode response: %w", err)
+	}
+	if result.AccessToken == "" {
+		return "", 0, fmt.Errorf("token response does not contain an access token")
 	}
 
-	config.AccessToken = &result.AccessToken
-	return result.AccessToken, nil
+	return result.AccessToken, time.Duration(result.ExpiresIn) * time.Second, nil
 }
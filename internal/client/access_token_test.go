@@ -0,0 +1,199 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/masslight/terraform-provider-oystehr/internal/retry"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTokenTestClient returns a client using client credentials against a server
+// that mints sequentially numbered tokens and rejects any token in revoked.
+func newTokenTestClient(t *testing.T, expiresIn int64, revoked map[string]bool) (*Client, *atomic.Int32) {
+	t.Helper()
+	var mints atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /auth/oauth/token", func(w http.ResponseWriter, r *http.Request) {
+		n := mints.Add(1)
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"access_token": fmt.Sprintf("token-%d", n),
+			"expires_in":   expiresIn,
+		})
+	})
+	mux.HandleFunc("/zambda/", func(w http.ResponseWriter, r *http.Request) {
+		if revoked[r.Header.Get("Authorization")] {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`{}`))
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	projectID := "project"
+	clientID := "client"
+	clientSecret := "secret"
+	return New(&ClientConfig{
+		ProjectID:    &projectID,
+		ClientID:     &clientID,
+		ClientSecret: &clientSecret,
+		Endpoints:    &Endpoints{Base: &server.URL},
+	}), &mints
+}
+
+func TestTokenSourceConcurrent(t *testing.T) {
+	c, mints := newTokenTestClient(t, 3600, nil)
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			token, err := c.tokens.Token(t.Context())
+			assert.NoError(t, err)
+			assert.Equal(t, "token-1", token)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), mints.Load(), "expected concurrent callers to share one token")
+}
+
+func TestTokenSourceRefreshesBeforeExpiry(t *testing.T) {
+	c, mints := newTokenTestClient(t, 600, nil)
+	source := c.tokens.(*clientCredentialsTokenSource)
+	now := time.Now()
+	source.now = func() time.Time { return now }
+
+	token, err := source.Token(t.Context())
+	assert.NoError(t, err)
+	assert.Equal(t, "token-1", token)
+
+	now = now.Add(600*time.Second - tokenRefreshWindow - time.Second)
+	token, err = source.Token(t.Context())
+	assert.NoError(t, err)
+	assert.Equal(t, "token-1", token, "expected cached token outside the refresh window")
+
+	now = now.Add(2 * time.Second)
+	token, err = source.Token(t.Context())
+	assert.NoError(t, err)
+	assert.Equal(t, "token-2", token, "expected a fresh token inside the refresh window")
+	assert.Equal(t, int32(2), mints.Load())
+}
+
+func TestTokenSourceShortLivedTokens(t *testing.T) {
+	c, mints := newTokenTestClient(t, 60, nil)
+	source := c.tokens.(*clientCredentialsTokenSource)
+	now := time.Now()
+	source.now = func() time.Time { return now }
+
+	for range 3 {
+		token, err := source.Token(t.Context())
+		assert.NoError(t, err)
+		assert.Equal(t, "token-1", token, "expected a token lasting no longer than the refresh window to be reused")
+	}
+
+	now = now.Add(44 * time.Second)
+	token, err := source.Token(t.Context())
+	assert.NoError(t, err)
+	assert.Equal(t, "token-1", token, "expected cached token for three quarters of its lifetime")
+
+	now = now.Add(2 * time.Second)
+	token, err = source.Token(t.Context())
+	assert.NoError(t, err)
+	assert.Equal(t, "token-2", token, "expected a fresh token in the last quarter of its lifetime")
+	assert.Equal(t, int32(2), mints.Load())
+}
+
+func TestRequestRetriesUnauthorizedWithFreshToken(t *testing.T) {
+	c, mints := newTokenTestClient(t, 3600, map[string]bool{"Bearer token-1": true})
+	_, err := c.request(t.Context(), http.MethodGet, c.config.Endpoints.endpoint(serviceZambda)+"/v1/zambda", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), mints.Load())
+}
+
+func TestRequestDoesNotRefreshStaticToken(t *testing.T) {
+	var attempts atomic.Int32
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+	_, err := c.request(t.Context(), http.MethodGet, c.config.Endpoints.endpoint(serviceZambda), nil)
+	assert.Error(t, err)
+	assert.Equal(t, int32(1), attempts.Load())
+	assert.Equal(t, "token", *c.config.AccessToken, "expected the configured token to be left untouched")
+}
+
+func TestRequestRetriesTokenFailures(t *testing.T) {
+	tt := []struct {
+		name          string
+		status        int
+		expectedMints int32
+		ok            bool
+	}{
+		{
+			name:          "throttled",
+			status:        http.StatusTooManyRequests,
+			expectedMints: 2,
+			ok:            true,
+		},
+		{
+			name:          "server error",
+			status:        http.StatusServiceUnavailable,
+			expectedMints: 2,
+			ok:            true,
+		},
+		{
+			name:          "rejected credentials",
+			status:        http.StatusUnauthorized,
+			expectedMints: 1,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var mints atomic.Int32
+			mux := http.NewServeMux()
+			mux.HandleFunc("POST /auth/oauth/token", func(w http.ResponseWriter, r *http.Request) {
+				if mints.Add(1) == 1 {
+					w.WriteHeader(tc.status)
+					return
+				}
+				_ = json.NewEncoder(w).Encode(map[string]any{"access_token": "token", "expires_in": 3600})
+			})
+			mux.HandleFunc("/zambda/", func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte(`{}`))
+			})
+			server := httptest.NewServer(mux)
+			t.Cleanup(server.Close)
+			projectID := "project"
+			clientID := "client"
+			clientSecret := "secret"
+			c := New(&ClientConfig{
+				ProjectID:    &projectID,
+				ClientID:     &clientID,
+				ClientSecret: &clientSecret,
+				Endpoints:    &Endpoints{Base: &server.URL},
+				Retry: &retry.RetryConfig{
+					BaseBackoff: time.Millisecond,
+					MaxBackoff:  time.Millisecond,
+					MaxDuration: retry.MaxDurationDefault,
+					MaxAttempts: retry.MaxAttemptsDefault,
+				},
+			})
+			_, err := c.request(t.Context(), http.MethodGet, c.config.Endpoints.endpoint(serviceZambda)+"/v1/zambda", nil)
+			if tc.ok {
+				assert.NoError(t, err)
+			} else {
+				var apiErr *APIError
+				if assert.ErrorAs(t, err, &apiErr) {
+					assert.Equal(t, tc.status, apiErr.StatusCode)
+				}
+			}
+			assert.Equal(t, tc.expectedMints, mints.Load())
+		})
+	}
+}
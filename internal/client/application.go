@@ -7,10 +7,6 @@ import (
 	"net/http"
 )
 
-const (
-	appBaseURL = "https://app-api.zapehr.com/v1/application"
-)
-
 type Application struct {
 	ID                     *string  `json:"id,omitempty"`
 	Name                   *string  `json:"name"`
@@ -31,23 +27,25 @@ type Application struct {
 }
 
 type applicationClient struct {
-	config *ClientConfig
+	client  *Client
+	baseURL string
 }
 
-func newApplicationClient(config *ClientConfig) *applicationClient {
+func newApplicationClient(client *Client) *applicationClient {
 	return &applicationClient{
-		config,
+		client:  client,
+		baseURL: client.config.Endpoints.endpoint(serviceApplication) + "/v1/application",
 	}
 }
 
 func (c *applicationClient) CreateApplication(ctx context.Context, app *Application) (*Application, error) {
-	url := appBaseURL
+	url := c.baseURL
 	body, err := json.Marshal(app)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal application: %w", err)
 	}
 
-	responseBody, err := request(ctx, c.config, http.MethodPost, url, body)
+	responseBody, err := c.client.request(ctx, http.MethodPost, url, body)
 	if err != nil {
 		// return nil, fmt.Errorf("failed to create application: %w", err)
 		return nil, fmt.Errorf("failed to create application: %w", err)
@@ -62,9 +60,9 @@ func (c *applicationClient) CreateApplication(ctx context.Context, app *Applicat
 }
 
 func (c *applicationClient) GetApplication(ctx context.Context, id string) (*Application, error) {
-	url := fmt.Sprintf("%s/%s", appBaseURL, id)
+	url := fmt.Sprintf("%s/%s", c.baseURL, id)
 
-	responseBody, err := request(ctx, c.config, http.MethodGet, url, nil)
+	responseBody, err := c.client.request(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get application: %w", err)
 	}
@@ -78,13 +76,13 @@ func (c *applicationClient) GetApplication(ctx context.Context, id string) (*App
 }
 
 func (c *applicationClient) UpdateApplication(ctx context.Context, id string, app *Application) (*Application, error) {
-	url := fmt.Sprintf("%s/%s", appBaseURL, id)
+	url := fmt.Sprintf("%s/%s", c.baseURL, id)
 	body, err := json.Marshal(app)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal application: %w", err)
 	}
 
-	responseBody, err := request(ctx, c.config, http.MethodPatch, url, body)
+	responseBody, err := c.client.request(ctx, http.MethodPatch, url, body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to update application: %w", err)
 	}
@@ -98,9 +96,9 @@ func (c *applicationClient) UpdateApplication(ctx context.Context, id string, ap
 }
 
 func (c *applicationClient) DeleteApplication(ctx context.Context, id string) error {
-	url := fmt.Sprintf("%s/%s", appBaseURL, id)
+	url := fmt.Sprintf("%s/%s", c.baseURL, id)
 
-	_, err := request(ctx, c.config, http.MethodDelete, url, nil)
+	_, err := c.client.request(ctx, http.MethodDelete, url, nil)
 	if err != nil {
 		return fmt.Errorf("failed to delete application: %w", err)
 	}
@@ -1,42 +1,103 @@
 package client
 
+import (
+	"crypto"
+	"net/http"
+	"time"
+
+	"github.com/masslight/terraform-provider-oystehr/internal/retry"
+)
+
+const (
+	defaultRequestTimeout = 60 * time.Second
+	maxIdleConnsPerHost   = 10 // Terraform runs up to 10 operations in parallel by default
+)
+
 type ClientConfig struct {
 	ProjectID    *string
 	AccessToken  *string
 	ClientID     *string
 	ClientSecret *string
+	// PrivateKey authenticates ClientID with a signed JWT client assertion
+	// instead of ClientSecret.
+	PrivateKey crypto.Signer
+	// KeyID identifies PrivateKey in the M2M client's JWKS.
+	KeyID     *string
+	Endpoints *Endpoints
+	// Retry configures retries of throttled and transiently failing requests.
+	// Defaults to retry.DefaultRetryConfig.
+	Retry *retry.RetryConfig
+	// RequestTimeout bounds each API request attempt. Defaults to 60 seconds.
+	RequestTimeout *time.Duration
+	// RateLimits configures the client-side rate limit of each service.
+	// Defaults to DefaultRateLimit.
+	RateLimits *RateLimits
+	// FhirBatchWindow is how long FHIR operations are collected into a batch
+	// bundle before it is sent. Defaults to DefaultFhirBatchWindow.
+	FhirBatchWindow *time.Duration
+	// FhirBatchTimeout bounds sending a FHIR batch bundle, including retries.
+	// Defaults to DefaultFhirBatchTimeout.
+	FhirBatchTimeout *time.Duration
+	// CompressRequests gzip compresses API request bodies of at least
+	// minCompressedBodySize bytes.
+	CompressRequests bool
+	// HTTPClient replaces the shared HTTP client, e.g. in tests.
+	HTTPClient *http.Client
 }
 
 type Client struct {
-	config      *ClientConfig
-	Application *applicationClient
-	Fax         *faxClient
-	Fhir        *fhirClient
-	Lab         *labClient
-	M2M         *m2mClient
-	Project     *projectClient
-	Role        *roleClient
-	Secret      *secretClient
-	Z3          *z3Client
-	Zambda      *zambdaClient
+	config         *ClientConfig
+	httpClient     *http.Client
+	retryConfig    retry.RetryConfig
+	requestTimeout time.Duration
+	tokens         tokenSource
+	limiters       []serviceLimiter
+	Application    *applicationClient
+	Fax            *faxClient
+	Fhir           *fhirClient
+	Lab            *labClient
+	M2M            *m2mClient
+	Project        *projectClient
+	Role           *roleClient
+	Secret         *secretClient
+	Z3             *z3Client
+	Zambda         *zambdaClient
 }
 
-func (c *Client) Request() {
-
+func newHTTPClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = maxIdleConnsPerHost
+	return &http.Client{Transport: transport}
 }
 
 func New(config *ClientConfig) *Client {
-	return &Client{
-		config:      config,
-		Application: newApplicationClient(config),
-		Fax:         newFaxClient(config),
-		Fhir:        newFhirClient(config),
-		Lab:         newLabClient(config),
-		M2M:         newM2MClient(config),
-		Project:     newProjectClient(config),
-		Role:        newRoleClient(config),
-		Secret:      newSecretClient(config),
-		Z3:          newZ3Client(config),
-		Zambda:      newZambdaClient(config),
+	c := &Client{
+		config:         config,
+		retryConfig:    retry.DefaultRetryConfig,
+		requestTimeout: defaultRequestTimeout,
+	}
+	if config.HTTPClient != nil {
+		c.httpClient = withLogging(config.HTTPClient)
+	} else {
+		c.httpClient = withLogging(newHTTPClient())
+	}
+	if config.Retry != nil {
+		c.retryConfig = *config.Retry
+	}
+	if config.RequestTimeout != nil {
+		c.requestTimeout = *config.RequestTimeout
 	}
+	c.tokens = newTokenSource(c)
+	c.limiters = newServiceLimiters(config)
+	c.Application = newApplicationClient(c)
+	c.Fax = newFaxClient(c)
+	c.Fhir = newFhirClient(c)
+	c.Lab = newLabClient(c)
+	c.M2M = newM2MClient(c)
+	c.Project = newProjectClient(c)
+	c.Role = newRoleClient(c)
+	c.Secret = newSecretClient(c)
+	c.Z3 = newZ3Client(c)
+	c.Zambda = newZambdaClient(c)
+	return c
 }
@@ -0,0 +1,125 @@
+package client
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+type service string
+
+const (
+	serviceApplication service = "app"
+	serviceAuth        service = "auth"
+	serviceFax         service = "fax"
+	serviceFhir        service = "fhir"
+	serviceIAM         service = "iam"
+	serviceLab         service = "labs"
+	serviceProject     service = "project"
+	serviceZ3          service = "z3"
+	serviceZambda      service = "zambda"
+)
+
+var defaultEndpoints = map[service]string{
+	serviceApplication: "https://app-api.zapehr.com",
+	serviceAuth:        "https://auth.zapehr.com",
+	serviceFax:         "https://fax-api.zapehr.com",
+	serviceFhir:        "https://fhir-api.zapehr.com",
+	serviceIAM:         "https://iam-api.zapehr.com",
+	serviceLab:         "https://labs-api.zapehr.com",
+	serviceProject:     "https://project-api.zapehr.com",
+	serviceZ3:          "https://z3-api.zapehr.com",
+	serviceZambda:      "https://zambda-api.zapehr.com",
+}
+
+// Endpoints overrides the URLs used to reach the Oystehr services. Per-service
+// values take precedence over Base. When Base is set, each service without an
+// override is reached at Base followed by the service name, e.g.
+// http://localhost:8080/fhir, so a single host can serve every API.
+type Endpoints struct {
+	Base        *string
+	Application *string
+	Auth        *string
+	Fax         *string
+	Fhir        *string
+	IAM         *string
+	Lab         *string
+	Project     *string
+	Z3          *string
+	Zambda      *string
+}
+
+func (e *Endpoints) override(s service) *string {
+	if e == nil {
+		return nil
+	}
+	switch s {
+	case serviceApplication:
+		return e.Application
+	case serviceAuth:
+		return e.Auth
+	case serviceFax:
+		return e.Fax
+	case serviceFhir:
+		return e.Fhir
+	case serviceIAM:
+		return e.IAM
+	case serviceLab:
+		return e.Lab
+	case serviceProject:
+		return e.Project
+	case serviceZ3:
+		return e.Z3
+	case serviceZambda:
+		return e.Zambda
+	default:
+		return nil
+	}
+}
+
+// endpoint returns the root URL of a service, without a trailing slash.
+func (e *Endpoints) endpoint(s service) string {
+	if o := e.override(s); o != nil && *o != "" {
+		return strings.TrimSuffix(*o, "/")
+	}
+	if e != nil && e.Base != nil && *e.Base != "" {
+		return strings.TrimSuffix(*e.Base, "/") + "/" + string(s)
+	}
+	return defaultEndpoints[s]
+}
+
+// Validate checks that every configured endpoint is an absolute http(s) URL.
+func (e *Endpoints) Validate() error {
+	if e == nil {
+		return nil
+	}
+	values := []struct {
+		name  string
+		value *string
+	}{
+		{"base_url", e.Base},
+		{"application", e.Application},
+		{"auth", e.Auth},
+		{"fax", e.Fax},
+		{"fhir", e.Fhir},
+		{"iam", e.IAM},
+		{"lab", e.Lab},
+		{"project", e.Project},
+		{"z3", e.Z3},
+		{"zambda", e.Zambda},
+	}
+	for _, ev := range values {
+		name, v := ev.name, ev.value
+		if v == nil || *v == "" {
+			continue
+		}
+		u, err := url.Parse(*v)
+		if err != nil {
+			return fmt.Errorf("invalid %s endpoint %q: %w", name, *v, err)
+		}
+		if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid %s endpoint %q: expected an absolute http or https URL", name, *v)
+		}
+	}
+	return nil
+}
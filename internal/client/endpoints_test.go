@@ -0,0 +1,64 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEndpoint(t *testing.T) {
+	base := "http://localhost:8080/"
+	fhir := "https://fhir-api.staging.zapehr.com/"
+	tt := []struct {
+		name      string
+		endpoints *Endpoints
+		service   service
+		expected  string
+	}{
+		{
+			name:      "nil endpoints use default",
+			endpoints: nil,
+			service:   serviceFhir,
+			expected:  "https://fhir-api.zapehr.com",
+		},
+		{
+			name:      "empty endpoints use default",
+			endpoints: &Endpoints{},
+			service:   serviceAuth,
+			expected:  "https://auth.zapehr.com",
+		},
+		{
+			name:      "base url appends service",
+			endpoints: &Endpoints{Base: &base},
+			service:   serviceZambda,
+			expected:  "http://localhost:8080/zambda",
+		},
+		{
+			name:      "service override wins over base",
+			endpoints: &Endpoints{Base: &base, Fhir: &fhir},
+			service:   serviceFhir,
+			expected:  "https://fhir-api.staging.zapehr.com",
+		},
+		{
+			name:      "service override does not affect other services",
+			endpoints: &Endpoints{Fhir: &fhir},
+			service:   serviceIAM,
+			expected:  "https://iam-api.zapehr.com",
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.endpoints.endpoint(tc.service))
+		})
+	}
+}
+
+func TestEndpointsValidate(t *testing.T) {
+	valid := "https://fhir-api.zapehr.com"
+	relative := "/fhir"
+	scheme := "ftp://example.com"
+	assert.NoError(t, (*Endpoints)(nil).Validate())
+	assert.NoError(t, (&Endpoints{Fhir: &valid}).Validate())
+	assert.Error(t, (&Endpoints{Base: &relative}).Validate())
+	assert.Error(t, (&Endpoints{Z3: &scheme}).Validate())
+}
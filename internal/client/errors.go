@@ -0,0 +1,138 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ErrNotFound is returned when a lookup succeeds at the HTTP level but the
+// requested object is absent from the response, e.g. a bucket missing from a
+// bucket listing.
+var ErrNotFound = errors.New("not found")
+
+// requestIDHeaders are the response headers that may carry the server request
+// ID, in order of preference.
+var requestIDHeaders = []string{
+	"x-oystehr-request-id",
+	"x-request-id",
+	"x-amzn-requestid",
+}
+
+// OperationOutcome is the FHIR error body returned by the FHIR API and by most
+// Oystehr services.
+type OperationOutcome struct {
+	ResourceType string                  `json:"resourceType"`
+	Issue        []OperationOutcomeIssue `json:"issue"`
+}
+
+type OperationOutcomeIssue struct {
+	Severity    string   `json:"severity"`
+	Code        string   `json:"code"`
+	Details     *Concept `json:"details,omitempty"`
+	Diagnostics string   `json:"diagnostics,omitempty"`
+	Expression  []string `json:"expression,omitempty"`
+	Location    []string `json:"location,omitempty"`
+}
+
+type Concept struct {
+	Text string `json:"text,omitempty"`
+}
+
+// Message returns the most descriptive text available for the issue.
+func (i OperationOutcomeIssue) Message() string {
+	if i.Details != nil && i.Details.Text != "" {
+		return i.Details.Text
+	}
+	if i.Diagnostics != "" {
+		return i.Diagnostics
+	}
+	return i.Code
+}
+
+// APIError is returned for any non-2xx response from an Oystehr API, including
+// failed entries of a FHIR batch bundle.
+type APIError struct {
+	StatusCode       int
+	Method           string
+	URL              string
+	Header           http.Header
+	Body             []byte
+	OperationOutcome *OperationOutcome
+	RequestID        string
+}
+
+func newAPIError(method, url string, statusCode int, header http.Header, body []byte) *APIError {
+	apiErr := &APIError{
+		StatusCode: statusCode,
+		Method:     method,
+		URL:        url,
+		Header:     header,
+		Body:       body,
+	}
+	for _, h := range requestIDHeaders {
+		if v := header.Get(h); v != "" {
+			apiErr.RequestID = v
+			break
+		}
+	}
+	var outcome OperationOutcome
+	if err := json.Unmarshal(body, &outcome); err == nil && outcome.ResourceType == "OperationOutcome" {
+		apiErr.OperationOutcome = &outcome
+	}
+	return apiErr
+}
+
+func (e *APIError) Error() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "unexpected status code: %d", e.StatusCode)
+	if e.Method != "" || e.URL != "" {
+		fmt.Fprintf(&sb, " (%s %s)", e.Method, e.URL)
+	}
+	if e.RequestID != "" {
+		fmt.Fprintf(&sb, ", request ID: %s", e.RequestID)
+	}
+	if e.OperationOutcome != nil && len(e.OperationOutcome.Issue) > 0 {
+		messages := make([]string, len(e.OperationOutcome.Issue))
+		for i, issue := range e.OperationOutcome.Issue {
+			messages[i] = issue.Message()
+		}
+		fmt.Fprintf(&sb, ", issues: %s", strings.Join(messages, "; "))
+	} else {
+		fmt.Fprintf(&sb, ", response body: %s", string(e.Body))
+	}
+	return sb.String()
+}
+
+func hasStatus(err error, statusCode int) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == statusCode
+}
+
+// IsNotFound reports whether err is a 404 response or an ErrNotFound.
+func IsNotFound(err error) bool {
+	return errors.Is(err, ErrNotFound) || hasStatus(err, http.StatusNotFound)
+}
+
+func isUnauthorized(err error) bool {
+	return hasStatus(err, http.StatusUnauthorized)
+}
+
+// IsGone reports whether err is a 410 response, which the FHIR API returns for
+// deleted resources.
+func IsGone(err error) bool {
+	return hasStatus(err, http.StatusGone)
+}
+
+// IsPreconditionFailed reports whether err is a 412 response, which the FHIR
+// API returns when the version in If-Match is not the current version.
+func IsPreconditionFailed(err error) bool {
+	return hasStatus(err, http.StatusPreconditionFailed)
+}
+
+// IsConflict reports whether err is a 409 response.
+func IsConflict(err error) bool {
+	return hasStatus(err, http.StatusConflict)
+}
@@ -0,0 +1,59 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPIError(t *testing.T) {
+	header := http.Header{}
+	header.Set("x-request-id", "req-123")
+	body := []byte(`{"resourceType":"OperationOutcome","issue":[{"severity":"error","code":"not-found","diagnostics":"Patient/abc is not known"}]}`)
+
+	apiErr := newAPIError(http.MethodGet, "https://fhir-api.zapehr.com/Patient/abc", http.StatusNotFound, header, body)
+
+	assert.Equal(t, "req-123", apiErr.RequestID)
+	if assert.NotNil(t, apiErr.OperationOutcome) {
+		assert.Len(t, apiErr.OperationOutcome.Issue, 1)
+		assert.Equal(t, "Patient/abc is not known", apiErr.OperationOutcome.Issue[0].Message())
+	}
+	assert.Contains(t, apiErr.Error(), "unexpected status code: 404")
+	assert.Contains(t, apiErr.Error(), "req-123")
+
+	wrapped := fmt.Errorf("failed to get resource: %w", apiErr)
+	assert.True(t, IsNotFound(wrapped))
+	assert.False(t, IsGone(wrapped))
+	assert.False(t, IsConflict(wrapped))
+	assert.False(t, IsPreconditionFailed(wrapped))
+}
+
+func TestIsPreconditionFailed(t *testing.T) {
+	apiErr := newAPIError(http.MethodPut, "https://fhir-api.zapehr.com/Patient/abc", http.StatusPreconditionFailed, http.Header{}, nil)
+	assert.True(t, IsPreconditionFailed(fmt.Errorf("failed to update resource: %w", apiErr)))
+	assert.False(t, IsPreconditionFailed(fmt.Errorf("something else")))
+}
+
+func TestAPIErrorPlainBody(t *testing.T) {
+	apiErr := newAPIError(http.MethodPost, "https://iam-api.zapehr.com/v1/m2m", http.StatusConflict, http.Header{}, []byte(`{"message":"already exists"}`))
+
+	assert.Nil(t, apiErr.OperationOutcome)
+	assert.Empty(t, apiErr.RequestID)
+	assert.Contains(t, apiErr.Error(), `{"message":"already exists"}`)
+	assert.True(t, IsConflict(apiErr))
+}
+
+func TestIsNotFoundSentinel(t *testing.T) {
+	assert.True(t, IsNotFound(fmt.Errorf("bucket foo: %w", ErrNotFound)))
+	assert.False(t, IsNotFound(fmt.Errorf("something else")))
+	assert.False(t, IsNotFound(nil))
+}
+
+func TestParseEntryStatus(t *testing.T) {
+	assert.Equal(t, 200, parseEntryStatus("200"))
+	assert.Equal(t, 201, parseEntryStatus("201 Created"))
+	assert.Equal(t, 410, parseEntryStatus(" 410 Gone"))
+	assert.Equal(t, 0, parseEntryStatus("garbage"))
+}
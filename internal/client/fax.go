@@ -7,10 +7,6 @@ import (
 	"net/http"
 )
 
-const (
-	faxBaseURL = "https://fax-api.zapehr.com/v1"
-)
-
 type FaxNumber struct {
 	Number *string `json:"faxNumber,omitempty"`
 }
@@ -21,17 +17,21 @@ type FaxGetConfigOutput struct {
 }
 
 type faxClient struct {
-	config *ClientConfig
+	client  *Client
+	baseURL string
 }
 
-func newFaxClient(config *ClientConfig) *faxClient {
-	return &faxClient{config}
+func newFaxClient(client *Client) *faxClient {
+	return &faxClient{
+		client:  client,
+		baseURL: client.config.Endpoints.endpoint(serviceFax) + "/v1",
+	}
 }
 
 func (c *faxClient) Onboard(ctx context.Context) (*FaxNumber, error) {
-	url := fmt.Sprintf("%s/onboard", faxBaseURL)
+	url := fmt.Sprintf("%s/onboard", c.baseURL)
 
-	responseBody, err := request(ctx, c.config, http.MethodPost, url, nil)
+	responseBody, err := c.client.request(ctx, http.MethodPost, url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create FaxNumber: %w", err)
 	}
@@ -45,9 +45,9 @@ func (c *faxClient) Onboard(ctx context.Context) (*FaxNumber, error) {
 }
 
 func (c *faxClient) GetFaxNumber(ctx context.Context, faxNumber string) (*FaxNumber, error) {
-	url := fmt.Sprintf("%s/config", faxBaseURL)
+	url := fmt.Sprintf("%s/config", c.baseURL)
 
-	responseBody, err := request(ctx, c.config, http.MethodGet, url, nil)
+	responseBody, err := c.client.request(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get FaxNumber: %w", err)
 	}
@@ -58,20 +58,20 @@ func (c *faxClient) GetFaxNumber(ctx context.Context, faxNumber string) (*FaxNum
 	}
 
 	if len(route.Numbers) == 0 {
-		return nil, fmt.Errorf("FaxNumber not found")
+		return nil, fmt.Errorf("FaxNumber %s: %w", faxNumber, ErrNotFound)
 	}
 	for _, r := range route.Numbers {
 		if r == faxNumber {
 			return &FaxNumber{Number: &r}, nil
 		}
 	}
-	return nil, fmt.Errorf("FaxNumber not found")
+	return nil, fmt.Errorf("FaxNumber %s: %w", faxNumber, ErrNotFound)
 }
 
 func (c *faxClient) Offboard(ctx context.Context) error {
-	url := fmt.Sprintf("%s/offboard", faxBaseURL)
+	url := fmt.Sprintf("%s/offboard", c.baseURL)
 
-	_, err := request(ctx, c.config, http.MethodPost, url, nil)
+	_, err := c.client.request(ctx, http.MethodPost, url, nil)
 	if err != nil {
 		return fmt.Errorf("failed to delete LabRoute: %w", err)
 	}
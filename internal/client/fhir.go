@@ -1,13 +1,19 @@
 package client
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"slices"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
@@ -15,149 +21,367 @@ import (
 )
 
 const (
-	fhirBaseURL  = "https://fhir-api.zapehr.com"
 	maxBatchSize = 100 // Maximum number of entries to process in a single batch
+	// DefaultFhirBatchWindow is how long entries are collected before a bundle
+	// is sent, so operations Terraform runs in parallel share one request.
+	DefaultFhirBatchWindow = 50 * time.Millisecond
+	// DefaultFhirBatchTimeout bounds sending a bundle, including retries.
+	DefaultFhirBatchTimeout = 30 * time.Second
 )
 
 type entryResult struct {
 	Resource any
+	Status   int
 	Error    error
 }
 
 type bundleEntry struct {
+	Ctx             context.Context
+	FullURL         string
 	Method          string
 	URL             string
 	IfMatch         string
+	IfNoneExist     string
 	Body            []byte
 	ResponseChannel chan entryResult
 }
 
+// fhirClient batches FHIR operations into bundles. A goroutine is started when
+// the first entry is queued and exits once the queue is drained, so an idle
+// client holds no resources.
 type fhirClient struct {
-	config     *ClientConfig
+	client  *Client
+	baseURL string
+	window  time.Duration
+	timeout time.Duration
+
+	entryMutex sync.Mutex
 	entries    []bundleEntry
-	entryMutex *sync.Mutex
+	running    bool
+	// full is signalled when a full batch is queued, to send it without waiting
+	// for the rest of the window.
+	full chan struct{}
+}
+
+func newFhirClient(client *Client) *fhirClient {
+	window := DefaultFhirBatchWindow
+	if client.config.FhirBatchWindow != nil {
+		window = *client.config.FhirBatchWindow
+	}
+	timeout := DefaultFhirBatchTimeout
+	if client.config.FhirBatchTimeout != nil {
+		timeout = *client.config.FhirBatchTimeout
+	}
+	return &fhirClient{
+		client:  client,
+		baseURL: client.config.Endpoints.endpoint(serviceFhir),
+		window:  window,
+		timeout: timeout,
+		full:    make(chan struct{}, 1),
+	}
+}
+
+func (c *fhirClient) enqueueBundleEntry(entry bundleEntry) {
+	c.entryMutex.Lock()
+	defer c.entryMutex.Unlock()
+	c.entries = append(c.entries, entry)
+	if !c.running {
+		c.running = true
+		go c.processBundleEntries()
+	}
+	if len(c.entries) >= maxBatchSize {
+		select {
+		case c.full <- struct{}{}:
+		default:
+		}
+	}
 }
 
-func newFhirClient(config *ClientConfig) *fhirClient {
-	c := &fhirClient{config, []bundleEntry{}, &sync.Mutex{}}
-	go c.processBundleEntries()
-	return c
+// submit queues an entry and waits for its result, or until ctx is done.
+func (c *fhirClient) submit(ctx context.Context, entry bundleEntry) entryResult {
+	if err := ctx.Err(); err != nil {
+		return entryResult{Error: err}
+	}
+	// Buffered so the batcher never blocks on a caller that stopped waiting.
+	// Every entry receives exactly one result.
+	responseChannel := make(chan entryResult, 1)
+	entry.Ctx = ctx
+	entry.ResponseChannel = responseChannel
+	c.enqueueBundleEntry(entry)
+	select {
+	case response := <-responseChannel:
+		return response
+	case <-ctx.Done():
+		c.removeBundleEntry(responseChannel)
+		return entryResult{Error: ctx.Err()}
+	}
 }
 
-func (c *fhirClient) enqueueBundleEntry(method, url string, body []byte, ifMatch string, channel chan entryResult) {
+// removeBundleEntry removes a cancelled entry from the queue, so it is never
+// sent. An entry already taken into a bundle is left to complete.
+func (c *fhirClient) removeBundleEntry(responseChannel chan entryResult) {
 	c.entryMutex.Lock()
 	defer c.entryMutex.Unlock()
-	c.entries = append(c.entries, bundleEntry{Method: method, URL: url, Body: body, IfMatch: ifMatch, ResponseChannel: channel})
+	c.entries = slices.DeleteFunc(c.entries, func(entry bundleEntry) bool {
+		return entry.ResponseChannel == responseChannel
+	})
 }
 
 func (c *fhirClient) processBundleEntries() {
 	for {
-		ctx := tflog.SetField(context.Background(), "oystehr_req_id", uuid.NewString())
+		timer := time.NewTimer(c.window)
+		select {
+		case <-timer.C:
+		case <-c.full:
+		}
+		timer.Stop()
+
 		c.entryMutex.Lock()
-		var entries []bundleEntry
-		if len(c.entries) > 0 {
-			if maxBatchSize <= 0 {
-				entries = slices.Clone(c.entries)
-				c.entries = []bundleEntry{}
-			} else if len(c.entries) <= maxBatchSize {
-				// If the number of entries is less than or equal to maxBatchSize, process all of them
-				entries = slices.Clone(c.entries)
-				c.entries = []bundleEntry{}
-			} else {
-				// If there are more entries than maxBatchSize, process only the first maxBatchSize entries
-				entries = slices.Clone(c.entries[:maxBatchSize])
-				c.entries = c.entries[maxBatchSize:]
-			}
+		entries := c.takeEntries()
+		if len(entries) == 0 {
+			c.running = false
+			c.entryMutex.Unlock()
+			return
 		}
 		c.entryMutex.Unlock()
 
-		if len(entries) == 0 {
-			tflog.Debug(ctx, "No entries to process")
+		c.sendBundle(entries)
+	}
+}
+
+// takeEntries dequeues up to maxBatchSize entries, dropping those whose
+// operation was cancelled while queued. The caller must hold entryMutex.
+func (c *fhirClient) takeEntries() []bundleEntry {
+	var entries []bundleEntry
+	for len(c.entries) > 0 && len(entries) < maxBatchSize {
+		entry := c.entries[0]
+		c.entries = c.entries[1:]
+		if err := entry.Ctx.Err(); err != nil {
+			entry.ResponseChannel <- entryResult{Error: err}
 			continue
 		}
-
-		tflog.Debug(ctx, "Assembling FHIR bundle", map[string]any{
-			"entry_count": len(entries),
-		})
-		// Assemble bundle from entries
-		bundle := map[string]any{
-			"resourceType": "Bundle",
-			"type":         "batch",
-			"entry":        make([]map[string]any, len(entries)),
+		entries = append(entries, entry)
+	}
+	if len(c.entries) >= maxBatchSize {
+		select {
+		case c.full <- struct{}{}:
+		default:
 		}
-		for i, entry := range entries {
-			bundle["entry"].([]map[string]any)[i] = map[string]any{
-				"request": map[string]any{
-					"method": entry.Method,
-					"url":    entry.URL,
-				},
-			}
-			if entry.Body != nil {
-				bundle["entry"].([]map[string]any)[i]["resource"] = json.RawMessage(entry.Body)
-			}
-			if entry.IfMatch != "" {
-				bundle["entry"].([]map[string]any)[i]["request"].(map[string]any)["ifMatch"] = entry.IfMatch
+	}
+	return entries
+}
+
+func (c *fhirClient) sendBundle(entries []bundleEntry) {
+	// Log with the first caller's logger, without tying the shared request to
+	// its cancellation
+	ctx := tflog.SetField(context.WithoutCancel(entries[0].Ctx), "oystehr_req_id", uuid.NewString())
+	tflog.Debug(ctx, "Assembling FHIR bundle", map[string]any{
+		"entry_count": len(entries),
+	})
+	bundle := newBundle("batch", entries)
+	tflog.Debug(ctx, "Assembled FHIR bundle", map[string]any{
+		"bundle": bundle,
+	})
+
+	// Marshal bundle to JSON
+	bundleJSON, err := json.Marshal(bundle)
+	if err != nil {
+		sendErrorToAllEntries(ctx, entries, fmt.Errorf("failed to marshal bundle: %w", err))
+		return
+	}
+
+	// Send bundle request, abandoning it once every caller has stopped waiting
+	requestCtx, cancel := context.WithTimeout(ctx, c.timeout)
+	var waiting atomic.Int32
+	waiting.Store(int32(len(entries)))
+	stops := make([]func() bool, len(entries))
+	for i, entry := range entries {
+		stops[i] = context.AfterFunc(entry.Ctx, func() {
+			if waiting.Add(-1) == 0 {
+				cancel()
 			}
-		}
-		tflog.Debug(ctx, "Assembled FHIR bundle", map[string]any{
-			"bundle": bundle,
 		})
+	}
+	responseBody, err := c.client.request(requestCtx, http.MethodPost, c.baseURL, bundleJSON)
+	cancel()
+	for _, stop := range stops {
+		stop()
+	}
+	if err != nil {
+		sendErrorToAllEntries(ctx, entries, fmt.Errorf("failed to send bundle request: %w", err))
+		return
+	}
 
-		// Marshal bundle to JSON
-		bundleJSON, err := json.Marshal(bundle)
-		if err != nil {
-			sendErrorToAllEntries(ctx, entries, fmt.Errorf("failed to marshal bundle: %w", err))
-			continue
-		}
+	// Process response
+	var bundleResponse map[string]any
+	if err := decodeJSON(responseBody, &bundleResponse); err != nil {
+		sendErrorToAllEntries(ctx, entries, fmt.Errorf("failed to decode bundle response: %w", err))
+		return
+	}
+	tflog.Debug(ctx, "Decoded bundle response", map[string]any{
+		"bundleResponse": bundleResponse,
+	})
+	if bundleResponse["entry"] == nil {
+		sendErrorToAllEntries(ctx, entries, fmt.Errorf("bundle response does not contain 'entry': %s", string(responseBody)))
+		return
+	}
+	entriesResponse, ok := bundleResponse["entry"].([]any)
+	if !ok || len(entriesResponse) != len(entries) {
+		sendErrorToAllEntries(ctx, entries, fmt.Errorf("bundle response entry count mismatch: expected %d, got %d", len(entries), len(entriesResponse)))
+		return
+	}
 
-		// Send bundle request
-		requestCtx, cancel := context.WithTimeout(ctx, time.Second*30)
-		responseBody, err := request(requestCtx, c.config, http.MethodPost, fhirBaseURL, bundleJSON)
-		cancel()
-		if err != nil {
-			sendErrorToAllEntries(ctx, entries, fmt.Errorf("failed to send bundle request: %w", err))
-			continue
+	// Fan out responses
+	for i, entry := range entries {
+		entryResponse, _ := entriesResponse[i].(map[string]any)
+		resource := entryResponse["resource"]
+		response, _ := entryResponse["response"].(map[string]any)
+		status, _ := response["status"].(string)
+		statusCode := parseEntryStatus(status)
+		switch {
+		case statusCode == 0:
+			entry.ResponseChannel <- entryResult{Error: fmt.Errorf("bundle response entry %d has no status", i)}
+		case statusCode < 200 || statusCode >= 300:
+			entry.ResponseChannel <- entryResult{Resource: resource, Status: statusCode, Error: newEntryError(entry, statusCode, response)}
+		default:
+			entry.ResponseChannel <- entryResult{Resource: resource, Status: statusCode, Error: nil}
 		}
+	}
+}
 
-		// Process response
-		var bundleResponse map[string]any
-		if err := json.Unmarshal(responseBody, &bundleResponse); err != nil {
-			sendErrorToAllEntries(ctx, entries, fmt.Errorf("failed to decode bundle response: %w", err))
-			continue
+// newBundle assembles a bundle of the given type from entries.
+func newBundle(bundleType string, entries []bundleEntry) map[string]any {
+	bundle := map[string]any{
+		"resourceType": "Bundle",
+		"type":         bundleType,
+		"entry":        make([]map[string]any, len(entries)),
+	}
+	for i, entry := range entries {
+		bundle["entry"].([]map[string]any)[i] = map[string]any{
+			"request": map[string]any{
+				"method": entry.Method,
+				"url":    entry.URL,
+			},
 		}
-		tflog.Debug(ctx, "Decoded bundle response", map[string]any{
-			"bundleResponse": bundleResponse,
-		})
-		if bundleResponse["entry"] == nil {
-			sendErrorToAllEntries(ctx, entries, fmt.Errorf("bundle response does not contain 'entry': %s", string(responseBody)))
-			continue
+		if entry.FullURL != "" {
+			bundle["entry"].([]map[string]any)[i]["fullUrl"] = entry.FullURL
 		}
-		entriesResponse := bundleResponse["entry"].([]any)
-		if len(entriesResponse) != len(entries) {
-			sendErrorToAllEntries(ctx, entries, fmt.Errorf("bundle response entry count mismatch: expected %d, got %d", len(entries), len(entriesResponse)))
-			continue
+		if entry.Body != nil {
+			bundle["entry"].([]map[string]any)[i]["resource"] = json.RawMessage(entry.Body)
+		}
+		if entry.IfMatch != "" {
+			bundle["entry"].([]map[string]any)[i]["request"].(map[string]any)["ifMatch"] = entry.IfMatch
 		}
+		if entry.IfNoneExist != "" {
+			bundle["entry"].([]map[string]any)[i]["request"].(map[string]any)["ifNoneExist"] = entry.IfNoneExist
+		}
+	}
+	return bundle
+}
 
-		// Fan out responses
-		for i, entry := range entries {
-			resource := entriesResponse[i].(map[string]any)["resource"]
-			response := entriesResponse[i].(map[string]any)["response"]
-			status := response.(map[string]any)["status"]
-			statusStr := status.(string)
-			statusCode, _ := strconv.Atoi(statusStr)
-			if statusCode < 200 || statusCode >= 300 {
-				select {
-				case entry.ResponseChannel <- entryResult{Resource: resource, Error: fmt.Errorf("unexpected status code: %d, response body: %+v", statusCode, response)}:
-				default:
-				}
-			} else {
-				select {
-				case entry.ResponseChannel <- entryResult{Resource: resource, Error: nil}:
-				default:
-				}
+// TransactionEntry is an operation of a FHIR transaction.
+type TransactionEntry struct {
+	// FullURL identifies the entry within the transaction, e.g. a
+	// "urn:uuid:..." placeholder that references of other entries resolve to
+	// the ID the server assigns.
+	FullURL  string
+	Method   string
+	URL      string
+	IfMatch  string
+	Resource map[string]any
+}
+
+// Transaction applies entries atomically as a FHIR transaction bundle: either
+// every entry succeeds or none do. Transactions are sent immediately rather
+// than batched. The resources in the response are returned in entry order,
+// with nil for entries that have none, such as deletes. When the server leaves
+// out a resource, the entry's response location and etag stand in for it.
+func (c *fhirClient) Transaction(ctx context.Context, entries []TransactionEntry) ([]map[string]any, error) {
+	bundleEntries := make([]bundleEntry, len(entries))
+	for i, entry := range entries {
+		bundleEntries[i] = bundleEntry{FullURL: entry.FullURL, Method: entry.Method, URL: entry.URL, IfMatch: entry.IfMatch}
+		if entry.Resource != nil {
+			body, err := json.Marshal(entry.Resource)
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal data: %w", err)
 			}
+			bundleEntries[i].Body = body
 		}
 	}
+	bundle := newBundle("transaction", bundleEntries)
+	tflog.Debug(ctx, "Assembled FHIR transaction", map[string]any{
+		"bundle": bundle,
+	})
+	bundleJSON, err := json.Marshal(bundle)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal bundle: %w", err)
+	}
+
+	requestCtx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	responseBody, err := c.client.request(requestCtx, http.MethodPost, c.baseURL, bundleJSON)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send transaction: %w", err)
+	}
+
+	var bundleResponse map[string]any
+	if err := decodeJSON(responseBody, &bundleResponse); err != nil {
+		return nil, fmt.Errorf("failed to decode transaction response: %w", err)
+	}
+	entriesResponse, ok := bundleResponse["entry"].([]any)
+	if !ok || len(entriesResponse) != len(entries) {
+		return nil, fmt.Errorf("transaction response entry count mismatch: expected %d, got %d", len(entries), len(entriesResponse))
+	}
+	resources := make([]map[string]any, len(entries))
+	for i, entry := range bundleEntries {
+		entryResponse, _ := entriesResponse[i].(map[string]any)
+		response, _ := entryResponse["response"].(map[string]any)
+		status, _ := response["status"].(string)
+		statusCode := parseEntryStatus(status)
+		if statusCode < 200 || statusCode >= 300 {
+			return nil, fmt.Errorf("transaction entry %d failed: %w", i, newEntryError(entry, statusCode, response))
+		}
+		resources[i], _ = entryResponse["resource"].(map[string]any)
+		if resources[i] == nil {
+			resources[i] = resourceFromEntryResponse(response)
+		}
+	}
+	return resources, nil
+}
+
+// resourceFromEntryResponse returns the type, id and version of the resource
+// a transaction response entry reports in its location and etag, or nil if it
+// has no location, as for deletes.
+func resourceFromEntryResponse(response map[string]any) map[string]any {
+	location, _ := response["location"].(string)
+	if parsed, err := url.Parse(location); err == nil {
+		location = parsed.Path
+	}
+	segments := strings.Split(strings.Trim(location, "/"), "/")
+	var versionID string
+	if n := len(segments); n >= 4 && segments[n-2] == "_history" {
+		versionID = segments[n-1]
+		segments = segments[:n-2]
+	}
+	if len(segments) < 2 || segments[len(segments)-1] == "" {
+		return nil
+	}
+	if etag, _ := response["etag"].(string); versionID == "" && etag != "" {
+		versionID = strings.Trim(strings.TrimPrefix(etag, "W/"), `"`)
+	}
+
+	meta := map[string]any{}
+	if versionID != "" {
+		meta["versionId"] = versionID
+	}
+	if lastModified, _ := response["lastModified"].(string); lastModified != "" {
+		meta["lastUpdated"] = lastModified
+	}
+	return map[string]any{
+		"resourceType": segments[len(segments)-2],
+		"id":           segments[len(segments)-1],
+		"meta":         meta,
+	}
 }
 
 func (c *fhirClient) CreateResource(ctx context.Context, resourceType string, data map[string]any) (map[string]interface{}, error) {
@@ -171,11 +395,7 @@ func (c *fhirClient) CreateResource(ctx context.Context, resourceType string, da
 		return nil, fmt.Errorf("failed to marshal data: %w", err)
 	}
 
-	responseChannel := make(chan entryResult, 1)
-	defer close(responseChannel)
-	c.enqueueBundleEntry(http.MethodPost, url, jsonData, "", responseChannel)
-
-	response := <-responseChannel
+	response := c.submit(ctx, bundleEntry{Method: http.MethodPost, URL: url, Body: jsonData})
 	if response.Error != nil {
 		return nil, fmt.Errorf("failed to create resource: %w", response.Error)
 	}
@@ -188,6 +408,36 @@ func (c *fhirClient) CreateResource(ctx context.Context, resourceType string, da
 	return result, nil
 }
 
+// ConditionalCreateResource creates a resource unless one already matches the
+// FHIR search query, e.g. "identifier=https://example.com|123", in which case
+// the match is returned instead. created reports whether the resource was
+// created. Several matches are an error.
+func (c *fhirClient) ConditionalCreateResource(ctx context.Context, resourceType, query string, data map[string]any) (resource map[string]any, created bool, err error) {
+	url := fmt.Sprintf("/%s", resourceType)
+
+	if data["resourceType"] == nil {
+		data["resourceType"] = resourceType
+	}
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to marshal data: %w", err)
+	}
+
+	response := c.submit(ctx, bundleEntry{Method: http.MethodPost, URL: url, Body: jsonData, IfNoneExist: query})
+	if response.Error != nil {
+		return nil, false, fmt.Errorf("failed to create resource: %w", response.Error)
+	}
+
+	result, ok := response.Resource.(map[string]any)
+	if !ok {
+		return nil, false, fmt.Errorf("failed to decode response")
+	}
+
+	return result, response.Status == http.StatusCreated, nil
+}
+
+// UpdateResource updates or creates the resource with an ID. The version check
+// is skipped when versionID is empty.
 func (c *fhirClient) UpdateResource(ctx context.Context, resourceType, resourceID string, versionID string, data map[string]interface{}) (map[string]interface{}, error) {
 	url := fmt.Sprintf("/%s/%s", resourceType, resourceID)
 
@@ -202,11 +452,41 @@ func (c *fhirClient) UpdateResource(ctx context.Context, resourceType, resourceI
 		return nil, fmt.Errorf("failed to marshal data: %w", err)
 	}
 
-	responseChannel := make(chan entryResult, 1)
-	defer close(responseChannel)
-	c.enqueueBundleEntry(http.MethodPut, url, jsonData, fmt.Sprintf(`W/"%s"`, versionID), responseChannel)
+	entry := bundleEntry{Method: http.MethodPut, URL: url, Body: jsonData}
+	if versionID != "" {
+		entry.IfMatch = fmt.Sprintf(`W/"%s"`, versionID)
+	}
+	response := c.submit(ctx, entry)
+	if response.Error != nil {
+		return nil, fmt.Errorf("failed to update resource: %w", response.Error)
+	}
 
-	response := <-responseChannel
+	result, ok := response.Resource.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("failed to decode response")
+	}
+
+	return result, nil
+}
+
+// ConditionalUpdateResource updates the single resource matching the FHIR
+// search query. The version check is skipped when versionID is empty.
+func (c *fhirClient) ConditionalUpdateResource(ctx context.Context, resourceType, query string, versionID string, data map[string]interface{}) (map[string]interface{}, error) {
+	url := fmt.Sprintf("/%s?%s", resourceType, query)
+
+	if data["resourceType"] == nil {
+		data["resourceType"] = resourceType
+	}
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal data: %w", err)
+	}
+
+	entry := bundleEntry{Method: http.MethodPut, URL: url, Body: jsonData}
+	if versionID != "" {
+		entry.IfMatch = fmt.Sprintf(`W/"%s"`, versionID)
+	}
+	response := c.submit(ctx, entry)
 	if response.Error != nil {
 		return nil, fmt.Errorf("failed to update resource: %w", response.Error)
 	}
@@ -219,14 +499,159 @@ func (c *fhirClient) UpdateResource(ctx context.Context, resourceType, resourceI
 	return result, nil
 }
 
+// PatchOperation is an RFC 6902 JSON Patch operation.
+type PatchOperation struct {
+	Op    string `json:"op"`
+	Path  string `json:"path"`
+	Value any    `json:"value"`
+}
+
+// MarshalJSON leaves value out of remove operations only, as add, replace and
+// test need it even when it is null, false, 0 or "".
+func (o PatchOperation) MarshalJSON() ([]byte, error) {
+	if o.Op == "remove" {
+		return json.Marshal(struct {
+			Op   string `json:"op"`
+			Path string `json:"path"`
+		}{o.Op, o.Path})
+	}
+	type operation PatchOperation
+	return json.Marshal(operation(o))
+}
+
+// PatchResource applies a JSON Patch to a resource. It carries no version
+// check, so concurrent changes to fields the patch does not touch are kept;
+// test operations can guard the values it changes.
+func (c *fhirClient) PatchResource(ctx context.Context, resourceType, resourceID string, patch []PatchOperation) (map[string]interface{}, error) {
+	return c.patchResource(ctx, fmt.Sprintf("/%s/%s", resourceType, resourceID), patch)
+}
+
+// ConditionalPatchResource applies a JSON Patch to the single resource
+// matching a search query.
+func (c *fhirClient) ConditionalPatchResource(ctx context.Context, resourceType, query string, patch []PatchOperation) (map[string]interface{}, error) {
+	return c.patchResource(ctx, fmt.Sprintf("/%s?%s", resourceType, query), patch)
+}
+
+func (c *fhirClient) patchResource(ctx context.Context, url string, patch []PatchOperation) (map[string]interface{}, error) {
+	patchData, err := json.Marshal(patch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal patch: %w", err)
+	}
+	// Bundle entries carry a patch as a Binary resource
+	jsonData, err := json.Marshal(map[string]any{
+		"resourceType": "Binary",
+		"contentType":  "application/json-patch+json",
+		"data":         base64.StdEncoding.EncodeToString(patchData),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal patch: %w", err)
+	}
+
+	response := c.submit(ctx, bundleEntry{Method: http.MethodPatch, URL: url, Body: jsonData})
+	if response.Error != nil {
+		return nil, fmt.Errorf("failed to patch resource: %w", response.Error)
+	}
+
+	result, ok := response.Resource.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("failed to decode response")
+	}
+
+	return result, nil
+}
+
+// ValidateResource checks a resource with the $validate operation, optionally
+// against a profile, returning the issues found. An invalid resource is not an
+// error.
+func (c *fhirClient) ValidateResource(ctx context.Context, resourceType string, data map[string]any, profile string) ([]OperationOutcomeIssue, error) {
+	validateURL := fmt.Sprintf("%s/%s/$validate", c.baseURL, resourceType)
+	if profile != "" {
+		validateURL += "?" + url.Values{"profile": {profile}}.Encode()
+	}
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal data: %w", err)
+	}
+
+	responseBody, err := c.client.request(ctx, http.MethodPost, validateURL, jsonData)
+	if err != nil {
+		// Servers may report an invalid resource with an error status
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.OperationOutcome != nil &&
+			(apiErr.StatusCode == http.StatusBadRequest || apiErr.StatusCode == http.StatusUnprocessableEntity) {
+			return apiErr.OperationOutcome.Issue, nil
+		}
+		return nil, fmt.Errorf("failed to validate resource: %w", err)
+	}
+
+	var outcome OperationOutcome
+	if err := decodeJSON(responseBody, &outcome); err != nil || outcome.ResourceType != "OperationOutcome" {
+		return nil, fmt.Errorf("failed to decode validation response: %s", string(responseBody))
+	}
+	return outcome.Issue, nil
+}
+
+// ExpandValueSet runs the $expand operation on a stored ValueSet, returning
+// the ValueSet with its expansion.
+func (c *fhirClient) ExpandValueSet(ctx context.Context, resourceID string) (map[string]any, error) {
+	expandURL := fmt.Sprintf("%s/ValueSet/%s/$expand", c.baseURL, resourceID)
+	responseBody, err := c.client.request(ctx, http.MethodGet, expandURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to expand value set: %w", err)
+	}
+
+	var result map[string]any
+	if err := decodeJSON(responseBody, &result); err != nil {
+		return nil, fmt.Errorf("failed to decode expansion: %s", string(responseBody))
+	}
+	return result, nil
+}
+
+// ValidateCode runs the $validate-code operation on a stored CodeSystem or
+// ValueSet, returning whether the code is valid and the server's message. The
+// system may be empty for a CodeSystem.
+func (c *fhirClient) ValidateCode(ctx context.Context, resourceType, resourceID, system, code string) (bool, string, error) {
+	params := url.Values{"code": {code}}
+	if system != "" {
+		params.Set("system", system)
+	}
+	validateURL := fmt.Sprintf("%s/%s/%s/$validate-code?%s", c.baseURL, resourceType, resourceID, params.Encode())
+	responseBody, err := c.client.request(ctx, http.MethodGet, validateURL, nil)
+	if err != nil {
+		return false, "", fmt.Errorf("failed to validate code: %w", err)
+	}
+
+	var parameters struct {
+		ResourceType string `json:"resourceType"`
+		Parameter    []struct {
+			Name         string `json:"name"`
+			ValueBoolean *bool  `json:"valueBoolean"`
+			ValueString  string `json:"valueString"`
+		} `json:"parameter"`
+	}
+	if err := decodeJSON(responseBody, &parameters); err != nil || parameters.ResourceType != "Parameters" {
+		return false, "", fmt.Errorf("failed to decode validation response: %s", string(responseBody))
+	}
+	var result *bool
+	var message string
+	for _, parameter := range parameters.Parameter {
+		switch parameter.Name {
+		case "result":
+			result = parameter.ValueBoolean
+		case "message":
+			message = parameter.ValueString
+		}
+	}
+	if result == nil {
+		return false, "", fmt.Errorf("validation response has no result: %s", string(responseBody))
+	}
+	return *result, message, nil
+}
+
 func (c *fhirClient) GetResource(ctx context.Context, resourceType, resourceID string) (map[string]interface{}, error) {
 	url := fmt.Sprintf("/%s/%s", resourceType, resourceID)
 
-	responseChannel := make(chan entryResult, 1)
-	defer close(responseChannel)
-	c.enqueueBundleEntry(http.MethodGet, url, nil, "", responseChannel)
-
-	response := <-responseChannel
+	response := c.submit(ctx, bundleEntry{Method: http.MethodGet, URL: url})
 	if response.Error != nil {
 		return nil, fmt.Errorf("failed to get resource: %w", response.Error)
 	}
@@ -239,14 +664,89 @@ func (c *fhirClient) GetResource(ctx context.Context, resourceType, resourceID s
 	return result, nil
 }
 
+// SearchResult is the outcome of a FHIR search.
+type SearchResult struct {
+	// Matches are the resources matching the search parameters.
+	Matches []map[string]any
+	// Included are the resources added by _include and _revinclude.
+	Included []map[string]any
+	// Truncated is set when pages remained after the page limit.
+	Truncated bool
+}
+
+// SearchResources runs a FHIR search, following the next links of the result
+// bundle for at most maxPages pages. Searches are sent directly rather than
+// batched, since result pages can only be fetched by their link.
+func (c *fhirClient) SearchResources(ctx context.Context, resourceType string, params url.Values, maxPages int) (*SearchResult, error) {
+	pageURL := fmt.Sprintf("%s/%s", c.baseURL, resourceType)
+	if len(params) > 0 {
+		pageURL += "?" + params.Encode()
+	}
+
+	result := &SearchResult{}
+	included := make(map[string]bool)
+	for page := 0; pageURL != ""; page++ {
+		if page == maxPages {
+			result.Truncated = true
+			break
+		}
+		responseBody, err := c.client.request(ctx, http.MethodGet, pageURL, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to search resources: %w", err)
+		}
+
+		var bundle struct {
+			Link []struct {
+				Relation string `json:"relation"`
+				URL      string `json:"url"`
+			} `json:"link"`
+			Entry []struct {
+				Resource map[string]any `json:"resource"`
+				Search   struct {
+					Mode string `json:"mode"`
+				} `json:"search"`
+			} `json:"entry"`
+		}
+		if err := decodeJSON(responseBody, &bundle); err != nil {
+			return nil, fmt.Errorf("failed to decode search response: %w", err)
+		}
+		for _, entry := range bundle.Entry {
+			if entry.Resource == nil {
+				continue
+			}
+			if entry.Search.Mode == "include" {
+				// Pages may include the same resource more than once
+				key := fmt.Sprintf("%v/%v", entry.Resource["resourceType"], entry.Resource["id"])
+				if !included[key] {
+					included[key] = true
+					result.Included = append(result.Included, entry.Resource)
+				}
+				continue
+			}
+			if entry.Search.Mode == "outcome" {
+				continue
+			}
+			result.Matches = append(result.Matches, entry.Resource)
+		}
+
+		pageURL = ""
+		for _, link := range bundle.Link {
+			if link.Relation == "next" {
+				// The access token is only ever sent to the FHIR API
+				if !strings.HasPrefix(link.URL, c.baseURL+"/") {
+					return nil, fmt.Errorf("search response next link %q is not a FHIR API URL", link.URL)
+				}
+				pageURL = link.URL
+			}
+		}
+	}
+	return result, nil
+}
+
 func (c *fhirClient) DeleteResource(ctx context.Context, resourceType, resourceID string) error {
 	url := fmt.Sprintf("/%s/%s", resourceType, resourceID)
 
-	responseChannel := make(chan entryResult, 1)
-	defer close(responseChannel)
-	c.enqueueBundleEntry(http.MethodDelete, url, nil, "", responseChannel)
-
-	response := <-responseChannel
+	response := c.submit(ctx, bundleEntry{Method: http.MethodDelete, URL: url})
 	if response.Error != nil {
 		return fmt.Errorf("failed to delete resource: %w", response.Error)
 	}
@@ -254,14 +754,37 @@ func (c *fhirClient) DeleteResource(ctx context.Context, resourceType, resourceI
 	return nil
 }
 
+// decodeJSON decodes FHIR data with numbers as json.Number, so decimals and
+// large integers keep the exact value the server returned rather than being
+// rounded to a float64.
+func decodeJSON(data []byte, v any) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
 func sendErrorToAllEntries(ctx context.Context, entries []bundleEntry, err error) {
 	tflog.Error(ctx, "Error processing FHIR bundle", map[string]any{
 		"error": err,
 	})
 	for _, entry := range entries {
-		select {
-		case entry.ResponseChannel <- entryResult{Resource: nil, Error: err}:
-		default:
-		}
+		entry.ResponseChannel <- entryResult{Resource: nil, Error: err}
+	}
+}
+
+// parseEntryStatus extracts the status code from a bundle entry response
+// status, which per the FHIR spec may be followed by the reason phrase, e.g.
+// "404 Not Found".
+func parseEntryStatus(status string) int {
+	code, _, _ := strings.Cut(strings.TrimSpace(status), " ")
+	statusCode, _ := strconv.Atoi(code)
+	return statusCode
+}
+
+func newEntryError(entry bundleEntry, statusCode int, response map[string]any) *APIError {
+	body, _ := json.Marshal(response)
+	if outcome, ok := response["outcome"]; ok {
+		body, _ = json.Marshal(outcome)
 	}
+	return newAPIError(entry.Method, entry.URL, statusCode, http.Header{}, body)
 }
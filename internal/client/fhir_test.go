@@ -0,0 +1,348 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fhirBatchHandler answers batch bundles with a 200 entry per request entry,
+// recording the size of each bundle.
+func fhirBatchHandler(t *testing.T, sizes chan<- int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var bundle struct {
+			Entry []map[string]any `json:"entry"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&bundle))
+		sizes <- len(bundle.Entry)
+		entries := make([]map[string]any, len(bundle.Entry))
+		for i := range bundle.Entry {
+			entries[i] = map[string]any{
+				"resource": map[string]any{"resourceType": "Patient", "id": fmt.Sprint(i)},
+				"response": map[string]any{"status": "200 OK"},
+			}
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"resourceType": "Bundle", "type": "batch-response", "entry": entries})
+	}
+}
+
+func TestFhirBatching(t *testing.T) {
+	tt := []struct {
+		name            string
+		operations      int
+		expectedBundles []int
+	}{
+		{
+			name:            "single operation",
+			operations:      1,
+			expectedBundles: []int{1},
+		},
+		{
+			name:            "concurrent operations share a bundle",
+			operations:      10,
+			expectedBundles: []int{10},
+		},
+		{
+			name:            "full batches are split",
+			operations:      maxBatchSize + 1,
+			expectedBundles: []int{maxBatchSize, 1},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			sizes := make(chan int, tc.operations)
+			c := newTestClient(t, fhirBatchHandler(t, sizes))
+			c.Fhir.window = 100 * time.Millisecond
+
+			var wg sync.WaitGroup
+			for range tc.operations {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_, err := c.Fhir.GetResource(t.Context(), "Patient", "1")
+					assert.NoError(t, err)
+				}()
+			}
+			wg.Wait()
+			close(sizes)
+
+			var bundles []int
+			for size := range sizes {
+				bundles = append(bundles, size)
+			}
+			assert.Equal(t, tc.expectedBundles, bundles)
+		})
+	}
+}
+
+func TestFhirBatcherStopsWhenIdle(t *testing.T) {
+	sizes := make(chan int, 1)
+	c := newTestClient(t, fhirBatchHandler(t, sizes))
+
+	c.Fhir.entryMutex.Lock()
+	assert.False(t, c.Fhir.running)
+	c.Fhir.entryMutex.Unlock()
+
+	_, err := c.Fhir.GetResource(t.Context(), "Patient", "1")
+	require.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		c.Fhir.entryMutex.Lock()
+		defer c.Fhir.entryMutex.Unlock()
+		return !c.Fhir.running
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestFhirCancelledEntry(t *testing.T) {
+	sizes := make(chan int, 1)
+	c := newTestClient(t, fhirBatchHandler(t, sizes))
+	c.Fhir.window = 100 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancelled := make(chan error, 1)
+	go func() {
+		_, err := c.Fhir.GetResource(ctx, "Patient", "cancelled")
+		cancelled <- err
+	}()
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+
+	_, err := c.Fhir.GetResource(t.Context(), "Patient", "1")
+	require.NoError(t, err)
+	assert.ErrorIs(t, <-cancelled, context.Canceled)
+	// The cancelled entry is not sent
+	assert.Equal(t, 1, <-sizes)
+}
+
+func TestFhirCancelledEntryLeavesQueue(t *testing.T) {
+	sizes := make(chan int, 1)
+	c := newTestClient(t, fhirBatchHandler(t, sizes))
+	c.Fhir.window = time.Hour
+
+	ctx, cancel := context.WithTimeout(t.Context(), 10*time.Millisecond)
+	defer cancel()
+	_, err := c.Fhir.GetResource(ctx, "Patient", "1")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	c.Fhir.entryMutex.Lock()
+	defer c.Fhir.entryMutex.Unlock()
+	assert.Empty(t, c.Fhir.entries)
+}
+
+func TestFhirBatchTimeout(t *testing.T) {
+	tt := []struct {
+		name    string
+		timeout time.Duration
+		ctx     func(t *testing.T) context.Context
+		err     error
+	}{
+		{
+			name:    "bundle times out",
+			timeout: 50 * time.Millisecond,
+			ctx:     func(t *testing.T) context.Context { return t.Context() },
+			err:     context.DeadlineExceeded,
+		},
+		{
+			name:    "caller deadline abandons the bundle",
+			timeout: time.Hour,
+			ctx: func(t *testing.T) context.Context {
+				ctx, cancel := context.WithTimeout(t.Context(), 50*time.Millisecond)
+				t.Cleanup(cancel)
+				return ctx
+			},
+			err: context.DeadlineExceeded,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			abandoned := make(chan struct{})
+			c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				// Hang until the client gives up on the request, which is only
+				// noticed once the body is read
+				_, _ = io.Copy(io.Discard, r.Body)
+				<-r.Context().Done()
+				close(abandoned)
+			})
+			c.Fhir.window = 0
+			c.Fhir.timeout = tc.timeout
+
+			_, err := c.Fhir.GetResource(tc.ctx(t), "Patient", "1")
+			assert.ErrorIs(t, err, tc.err)
+			select {
+			case <-abandoned:
+			case <-time.After(time.Second):
+				t.Fatal("bundle request was not abandoned")
+			}
+		})
+	}
+}
+
+func TestFhirMalformedBundleResponse(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"resourceType":"Bundle","entry":[{"resource":{"resourceType":"Patient"}}]}`))
+	})
+	c.Fhir.window = 0
+
+	_, err := c.Fhir.GetResource(t.Context(), "Patient", "1")
+	assert.ErrorContains(t, err, "has no status")
+}
+
+func TestFhirTransactionMinimalResponse(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"resourceType": "Bundle",
+			"type":         "transaction-response",
+			"entry": []any{
+				map[string]any{"response": map[string]any{"status": "201 Created", "location": "Patient/1/_history/1", "lastModified": "2024-01-01T00:00:00Z"}},
+				map[string]any{"response": map[string]any{"status": "200 OK", "location": "https://fhir.example.com/Organization/2", "etag": `W/"3"`}},
+				map[string]any{"response": map[string]any{"status": "204 No Content"}},
+			},
+		})
+	})
+
+	resources, err := c.Fhir.Transaction(t.Context(), []TransactionEntry{
+		{Method: http.MethodPost, URL: "/Patient", Resource: map[string]any{"resourceType": "Patient"}},
+		{Method: http.MethodPut, URL: "/Organization/2", Resource: map[string]any{"resourceType": "Organization", "id": "2"}},
+		{Method: http.MethodDelete, URL: "/Patient/3"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []map[string]any{
+		{"resourceType": "Patient", "id": "1", "meta": map[string]any{"versionId": "1", "lastUpdated": "2024-01-01T00:00:00Z"}},
+		{"resourceType": "Organization", "id": "2", "meta": map[string]any{"versionId": "3"}},
+		nil,
+	}, resources)
+}
+
+func TestFhirSearchResources(t *testing.T) {
+	tt := []struct {
+		name              string
+		next              func(baseURL string, page int) string
+		maxPages          int
+		expectedMatches   int
+		expectedIncluded  int
+		expectedTruncated bool
+		expectedError     string
+	}{
+		{
+			name:             "single page",
+			next:             func(string, int) string { return "" },
+			maxPages:         10,
+			expectedMatches:  1,
+			expectedIncluded: 1,
+		},
+		{
+			name: "pages are followed and includes deduplicated",
+			next: func(baseURL string, page int) string {
+				if page < 3 {
+					return fmt.Sprintf("%s/fhir/Organization?_offset=%d", baseURL, page)
+				}
+				return ""
+			},
+			maxPages:         10,
+			expectedMatches:  3,
+			expectedIncluded: 1,
+		},
+		{
+			name: "page limit truncates",
+			next: func(baseURL string, page int) string {
+				return fmt.Sprintf("%s/fhir/Organization?_offset=%d", baseURL, page)
+			},
+			maxPages:          2,
+			expectedMatches:   2,
+			expectedIncluded:  1,
+			expectedTruncated: true,
+		},
+		{
+			name:          "next link outside the FHIR API",
+			next:          func(string, int) string { return "https://example.com/fhir/Organization?_offset=1" },
+			maxPages:      10,
+			expectedError: "is not a FHIR API URL",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var baseURL string
+			page := 0
+			c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, http.MethodGet, r.Method)
+				page++
+				links := []any{}
+				if next := tc.next(baseURL, page); next != "" {
+					links = append(links, map[string]any{"relation": "next", "url": next})
+				}
+				_ = json.NewEncoder(w).Encode(map[string]any{
+					"resourceType": "Bundle",
+					"type":         "searchset",
+					"link":         links,
+					"entry": []any{
+						map[string]any{"resource": map[string]any{"resourceType": "Organization", "id": fmt.Sprint(page)}, "search": map[string]any{"mode": "match"}},
+						map[string]any{"resource": map[string]any{"resourceType": "Location", "id": "1"}, "search": map[string]any{"mode": "include"}},
+					},
+				})
+			})
+			baseURL = strings.TrimSuffix(c.Fhir.baseURL, "/fhir")
+
+			result, err := c.Fhir.SearchResources(t.Context(), "Organization", url.Values{"_include": {"Organization:partof"}}, tc.maxPages)
+			if tc.expectedError != "" {
+				assert.ErrorContains(t, err, tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Len(t, result.Matches, tc.expectedMatches)
+			assert.Len(t, result.Included, tc.expectedIncluded)
+			assert.Equal(t, tc.expectedTruncated, result.Truncated)
+		})
+	}
+}
+
+func TestPatchOperationJSON(t *testing.T) {
+	tt := []struct {
+		name      string
+		operation PatchOperation
+		expected  string
+	}{
+		{
+			name:      "replace with false",
+			operation: PatchOperation{Op: "replace", Path: "/active", Value: false},
+			expected:  `{"op":"replace","path":"/active","value":false}`,
+		},
+		{
+			name:      "test for null",
+			operation: PatchOperation{Op: "test", Path: "/birthDate", Value: nil},
+			expected:  `{"op":"test","path":"/birthDate","value":null}`,
+		},
+		{
+			name:      "add empty string",
+			operation: PatchOperation{Op: "add", Path: "/gender", Value: ""},
+			expected:  `{"op":"add","path":"/gender","value":""}`,
+		},
+		{
+			name:      "remove",
+			operation: PatchOperation{Op: "remove", Path: "/active"},
+			expected:  `{"op":"remove","path":"/active"}`,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			data, err := json.Marshal(tc.operation)
+			require.NoError(t, err)
+			assert.JSONEq(t, tc.expected, string(data))
+		})
+	}
+}
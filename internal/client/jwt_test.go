@@ -0,0 +1,150 @@
+package client
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func decodeSegment(t *testing.T, segment string) map[string]any {
+	t.Helper()
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	require.NoError(t, err)
+	var m map[string]any
+	require.NoError(t, json.Unmarshal(data, &m))
+	return m
+}
+
+func TestSignClientAssertion(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	now := time.Unix(1700000000, 0)
+
+	tt := []struct {
+		name   string
+		key    crypto.Signer
+		alg    string
+		verify func(digest, signature []byte) bool
+	}{
+		{
+			name: "RS256",
+			key:  rsaKey,
+			alg:  "RS256",
+			verify: func(digest, signature []byte) bool {
+				return rsa.VerifyPKCS1v15(&rsaKey.PublicKey, crypto.SHA256, digest, signature) == nil
+			},
+		},
+		{
+			name: "ES256",
+			key:  ecKey,
+			alg:  "ES256",
+			verify: func(digest, signature []byte) bool {
+				r := new(big.Int).SetBytes(signature[:32])
+				s := new(big.Int).SetBytes(signature[32:])
+				return len(signature) == 64 && ecdsa.Verify(&ecKey.PublicKey, digest, r, s)
+			},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			assertion, err := signClientAssertion(tc.key, "kid-1", "client-1", "https://auth.zapehr.com/oauth/token", now)
+			require.NoError(t, err)
+
+			parts := strings.Split(assertion, ".")
+			require.Len(t, parts, 3)
+			header := decodeSegment(t, parts[0])
+			assert.Equal(t, tc.alg, header["alg"])
+			assert.Equal(t, "kid-1", header["kid"])
+			claims := decodeSegment(t, parts[1])
+			assert.Equal(t, "client-1", claims["iss"])
+			assert.Equal(t, "client-1", claims["sub"])
+			assert.Equal(t, "https://auth.zapehr.com/oauth/token", claims["aud"])
+			assert.Equal(t, float64(now.Unix()), claims["iat"])
+			assert.Equal(t, float64(now.Add(clientAssertionLifetime).Unix()), claims["exp"])
+			assert.NotEmpty(t, claims["jti"])
+
+			signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+			require.NoError(t, err)
+			digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+			assert.True(t, tc.verify(digest[:], signature), "signature does not verify")
+		})
+	}
+}
+
+func TestParsePrivateKey(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	p384Key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(ecKey)
+	require.NoError(t, err)
+	sec1, err := x509.MarshalECPrivateKey(p384Key)
+	require.NoError(t, err)
+
+	key, err := ParsePrivateKey(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)}))
+	assert.NoError(t, err)
+	assert.IsType(t, &rsa.PrivateKey{}, key)
+
+	key, err = ParsePrivateKey(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8}))
+	assert.NoError(t, err)
+	assert.IsType(t, &ecdsa.PrivateKey{}, key)
+
+	_, err = ParsePrivateKey(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: sec1}))
+	assert.ErrorContains(t, err, "P-384")
+
+	_, err = ParsePrivateKey([]byte("not a key"))
+	assert.ErrorContains(t, err, "no PEM block")
+}
+
+func TestMintAccessTokenWithPrivateKey(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	var body map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		_, _ = w.Write([]byte(`{"access_token":"minted","expires_in":3600}`))
+	}))
+	t.Cleanup(server.Close)
+
+	projectID := "project"
+	clientID := "client"
+	keyID := "kid"
+	c := New(&ClientConfig{
+		ProjectID:  &projectID,
+		ClientID:   &clientID,
+		PrivateKey: ecKey,
+		KeyID:      &keyID,
+		Endpoints:  &Endpoints{Base: &server.URL},
+	})
+
+	token, expiresIn, err := c.mintAccessToken(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, "minted", token)
+	assert.Equal(t, time.Hour, expiresIn)
+	assert.Equal(t, "client_credentials", body["grant_type"])
+	assert.Equal(t, clientAssertionType, body["client_assertion_type"])
+	assert.NotEmpty(t, body["client_assertion"])
+	assert.NotContains(t, body, "client_secret")
+}
@@ -7,10 +7,6 @@ import (
 	"net/http"
 )
 
-const (
-	labBaseURL = "https://labs-api.zapehr.com/v1"
-)
-
 type LabRouteAddress struct {
 	Address1          *string `json:"address1"`
 	Address2          *string `json:"address2,omitempty"`
@@ -35,24 +31,28 @@ type LabRoute struct {
 }
 
 type labClient struct {
-	config *ClientConfig
+	client  *Client
+	baseURL string
 }
 
-func newLabClient(config *ClientConfig) *labClient {
-	return &labClient{config}
+func newLabClient(client *Client) *labClient {
+	return &labClient{
+		client:  client,
+		baseURL: client.config.Endpoints.endpoint(serviceLab) + "/v1",
+	}
 }
 
 func (c *labClient) CreateLabRoute(ctx context.Context, route *LabRoute) (*LabRoute, error) {
-	url := fmt.Sprintf("%s/route", labBaseURL)
+	url := fmt.Sprintf("%s/route", c.baseURL)
 
 	body, err := json.Marshal(route)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal LabRoute: %w", err)
 	}
 
-	responseBody, err := request(ctx, c.config, http.MethodPost, url, body)
+	responseBody, err := c.client.request(ctx, http.MethodPost, url, body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create LabRoute: %w; %+v, %s", err, route, body)
+		return nil, fmt.Errorf("failed to create LabRoute: %w", err)
 	}
 
 	var createdRouteOutput *struct {
@@ -71,9 +71,9 @@ func (c *labClient) CreateLabRoute(ctx context.Context, route *LabRoute) (*LabRo
 }
 
 func (c *labClient) GetLabRoute(ctx context.Context, routeGUID string) (*LabRoute, error) {
-	url := fmt.Sprintf("%s/route/%s", labBaseURL, routeGUID)
+	url := fmt.Sprintf("%s/route/%s", c.baseURL, routeGUID)
 
-	responseBody, err := request(ctx, c.config, http.MethodGet, url, nil)
+	responseBody, err := c.client.request(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get LabRoute: %w", err)
 	}
@@ -84,7 +84,7 @@ func (c *labClient) GetLabRoute(ctx context.Context, routeGUID string) (*LabRout
 	}
 
 	if route == nil {
-		return nil, fmt.Errorf("LabRoute not found")
+		return nil, fmt.Errorf("LabRoute %s: %w", routeGUID, ErrNotFound)
 	}
 
 	// Modify data because of bad API design
@@ -96,14 +96,14 @@ func (c *labClient) GetLabRoute(ctx context.Context, routeGUID string) (*LabRout
 }
 
 func (c *labClient) DeleteLabRoute(ctx context.Context, routeGUID string, labRoute *LabRoute) error {
-	url := fmt.Sprintf("%s/route/%s", labBaseURL, routeGUID)
+	url := fmt.Sprintf("%s/route/%s", c.baseURL, routeGUID)
 
 	body, err := json.Marshal(labRoute)
 	if err != nil {
 		return fmt.Errorf("failed to marshal LabRoute: %w", err)
 	}
 
-	_, err = request(ctx, c.config, http.MethodDelete, url, body)
+	_, err = c.client.request(ctx, http.MethodDelete, url, body)
 	if err != nil {
 		return fmt.Errorf("failed to delete LabRoute: %w", err)
 	}
@@ -0,0 +1,249 @@
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+const (
+	requestIDHeader = "x-request-id"
+	redacted        = "[REDACTED]"
+	// maxLoggedBodySize bounds the request and response bodies logged at trace
+	// level; larger bodies are only logged by size.
+	maxLoggedBodySize = 64 * 1024
+)
+
+// sensitiveHeaders are never logged.
+var sensitiveHeaders = map[string]bool{
+	"Authorization":        true,
+	"Cookie":               true,
+	"Set-Cookie":           true,
+	"X-Amz-Security-Token": true,
+}
+
+// sensitiveFields are JSON keys whose values are never logged, at any depth.
+var sensitiveFields = map[string]bool{
+	"access_token":     true,
+	"accessToken":      true,
+	"client_assertion": true,
+	"client_secret":    true,
+	"clientSecret":     true,
+	"password":         true,
+	"secret":           true,
+}
+
+// signedURLParams are query parameters that mark a presigned URL, whose query
+// string grants access on its own.
+var signedURLParams = []string{
+	"X-Amz-Signature",
+	"X-Amz-Credential",
+	"X-Amz-Security-Token",
+	"Signature",
+}
+
+// loggingTransport traces every request and response with tflog, tagging each
+// request with an x-request-id header that Oystehr support can correlate with
+// their logs. Credentials, secret values and signed URLs are redacted.
+type loggingTransport struct {
+	next http.RoundTripper
+}
+
+// withLogging returns a copy of client whose transport traces requests.
+func withLogging(client *http.Client) *http.Client {
+	next := client.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	logged := *client
+	logged.Transport = &loggingTransport{next: next}
+	return &logged
+}
+
+func (t *loggingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	requestID := req.Header.Get(requestIDHeader)
+	if requestID == "" {
+		requestID = uuid.NewString()
+		// A RoundTripper must not modify the caller's request
+		req = req.Clone(req.Context())
+		req.Header.Set(requestIDHeader, requestID)
+	}
+
+	ctx := tflog.SetField(req.Context(), "request_id", requestID)
+	ctx = tflog.SetField(ctx, "http_method", req.Method)
+	ctx = tflog.SetField(ctx, "http_url", redactURL(req.URL))
+	fieldsToRedact := sensitiveFieldsFor(req.URL)
+
+	tflog.Debug(ctx, "Sending API request", map[string]any{
+		"http_request_headers": redactHeaders(req.Header),
+	})
+	if req.GetBody != nil && req.ContentLength > 0 {
+		if body, err := req.GetBody(); err == nil {
+			data, _ := io.ReadAll(body)
+			body.Close()
+			if req.Header.Get("Content-Encoding") == "gzip" {
+				data = gunzipForLogging(data)
+			}
+			tflog.Trace(ctx, "API request body", map[string]any{
+				"http_request_body": redactBody(req.Header.Get("Content-Type"), data, fieldsToRedact),
+			})
+		}
+	}
+
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	latency := time.Since(start)
+	if err != nil {
+		tflog.Debug(ctx, "API request failed", map[string]any{
+			"error":           err.Error(),
+			"http_latency_ms": latency.Milliseconds(),
+		})
+		return resp, err
+	}
+
+	fields := map[string]any{
+		"http_status":     resp.StatusCode,
+		"http_latency_ms": latency.Milliseconds(),
+	}
+	for _, h := range requestIDHeaders {
+		if v := resp.Header.Get(h); v != "" && v != requestID {
+			fields["response_request_id"] = v
+			break
+		}
+	}
+	tflog.Debug(ctx, "Received API response", fields)
+
+	if isJSON(resp.Header.Get("Content-Type")) && resp.ContentLength <= maxLoggedBodySize {
+		data, readErr := io.ReadAll(io.LimitReader(resp.Body, maxLoggedBodySize+1))
+		// Hand the caller everything, including what was not read
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(data), resp.Body), resp.Body}
+		if readErr == nil {
+			tflog.Trace(ctx, "API response body", map[string]any{
+				"http_response_body": redactBody(resp.Header.Get("Content-Type"), data, fieldsToRedact),
+			})
+		}
+	}
+
+	return resp, nil
+}
+
+// gunzipForLogging decompresses a compressed request body, so that it is
+// logged like any other.
+func gunzipForLogging(data []byte) []byte {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return data
+	}
+	decompressed, err := io.ReadAll(io.LimitReader(reader, maxLoggedBodySize+1))
+	if err != nil {
+		return data
+	}
+	return decompressed
+}
+
+func sensitiveFieldsFor(u *url.URL) map[string]bool {
+	// Secrets are stored as {"name": ..., "value": ...}
+	if strings.Contains(u.Path, "/v1/secret") {
+		fields := map[string]bool{"value": true}
+		for k := range sensitiveFields {
+			fields[k] = true
+		}
+		return fields
+	}
+	return sensitiveFields
+}
+
+func redactHeaders(header http.Header) map[string]string {
+	headers := make(map[string]string, len(header))
+	for k, v := range header {
+		if sensitiveHeaders[http.CanonicalHeaderKey(k)] {
+			headers[k] = redacted
+			continue
+		}
+		headers[k] = strings.Join(v, ", ")
+	}
+	return headers
+}
+
+// redactURL drops the query string of presigned URLs.
+func redactURL(u *url.URL) string {
+	if !isSignedURL(u) {
+		return u.String()
+	}
+	stripped := *u
+	stripped.RawQuery = ""
+	return stripped.String() + "?" + redacted
+}
+
+func isSignedURL(u *url.URL) bool {
+	query := u.Query()
+	for _, param := range signedURLParams {
+		for k := range query {
+			if strings.EqualFold(k, param) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isJSON(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// redactBody returns a loggable form of a body. Only JSON bodies are logged, with
+// sensitive fields and signed URLs redacted.
+func redactBody(contentType string, data []byte, fields map[string]bool) any {
+	if len(data) > maxLoggedBodySize || !isJSON(contentType) {
+		return map[string]any{"size": len(data)}
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return map[string]any{"size": len(data)}
+	}
+	return redactValue(value, fields)
+}
+
+func redactValue(value any, fields map[string]bool) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for k, child := range v {
+			if fields[k] {
+				v[k] = redacted
+				continue
+			}
+			v[k] = redactValue(child, fields)
+		}
+		return v
+	case []any:
+		for i, child := range v {
+			v[i] = redactValue(child, fields)
+		}
+		return v
+	case string:
+		if u, err := url.Parse(v); err == nil && u.IsAbs() && isSignedURL(u) {
+			return redactURL(u)
+		}
+		return v
+	default:
+		return v
+	}
+}
@@ -0,0 +1,142 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-log/tflogtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedactURL(t *testing.T) {
+	tt := []struct {
+		name     string
+		url      string
+		expected string
+	}{
+		{
+			name:     "plain URL",
+			url:      "https://fhir-api.zapehr.com/Patient?name=test",
+			expected: "https://fhir-api.zapehr.com/Patient?name=test",
+		},
+		{
+			name:     "presigned URL",
+			url:      "https://bucket.s3.amazonaws.com/key?X-Amz-Credential=abc&X-Amz-Signature=def",
+			expected: "https://bucket.s3.amazonaws.com/key?[REDACTED]",
+		},
+		{
+			name:     "lower case signature parameter",
+			url:      "https://bucket.s3.amazonaws.com/key?x-amz-signature=def",
+			expected: "https://bucket.s3.amazonaws.com/key?[REDACTED]",
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			u, err := url.Parse(tc.url)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, redactURL(u))
+		})
+	}
+}
+
+func TestRedactBody(t *testing.T) {
+	secretPath, _ := url.Parse("https://zambda-api.zapehr.com/v1/secret")
+	fhirPath, _ := url.Parse("https://fhir-api.zapehr.com/Observation")
+
+	tt := []struct {
+		name        string
+		contentType string
+		body        string
+		fields      map[string]bool
+		expected    any
+	}{
+		{
+			name:        "client credentials",
+			contentType: "application/json",
+			body:        `{"client_id":"id","client_secret":"hunter2"}`,
+			fields:      sensitiveFields,
+			expected:    map[string]any{"client_id": "id", "client_secret": redacted},
+		},
+		{
+			name:        "secret value",
+			contentType: "application/json",
+			body:        `{"name":"API_KEY","value":"hunter2"}`,
+			fields:      sensitiveFieldsFor(secretPath),
+			expected:    map[string]any{"name": "API_KEY", "value": redacted},
+		},
+		{
+			name:        "FHIR value is kept",
+			contentType: "application/fhir+json",
+			body:        `{"valueQuantity":{"value":1.50}}`,
+			fields:      sensitiveFieldsFor(fhirPath),
+			expected:    map[string]any{"valueQuantity": map[string]any{"value": json.Number("1.50")}},
+		},
+		{
+			name:        "nested signed URL",
+			contentType: "application/json; charset=utf-8",
+			body:        `{"items":[{"signedUrl":"https://bucket.s3.amazonaws.com/key?X-Amz-Signature=def"}]}`,
+			fields:      sensitiveFields,
+			expected:    map[string]any{"items": []any{map[string]any{"signedUrl": "https://bucket.s3.amazonaws.com/key?[REDACTED]"}}},
+		},
+		{
+			name:        "non-JSON body",
+			contentType: "application/zip",
+			body:        "PK",
+			fields:      sensitiveFields,
+			expected:    map[string]any{"size": 2},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, redactBody(tc.contentType, []byte(tc.body), tc.fields))
+		})
+	}
+}
+
+func TestLoggingTransport(t *testing.T) {
+	var receivedRequestID string
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		receivedRequestID = r.Header.Get(requestIDHeader)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"name":"API_KEY","value":"response-secret"}`))
+	})
+
+	var output bytes.Buffer
+	ctx := tflogtest.RootLogger(t.Context(), &output)
+	secret, err := c.Secret.SetSecret(ctx, &Secret{Name: stringPtr("API_KEY"), Value: stringPtr("request-secret")})
+	require.NoError(t, err)
+	assert.Equal(t, "response-secret", *secret.Value)
+
+	logs := output.String()
+	assert.NotEmpty(t, receivedRequestID)
+	assert.Contains(t, logs, `"request_id":"`+receivedRequestID+`"`)
+	assert.Contains(t, logs, `"http_status":200`)
+	assert.Contains(t, logs, `"http_latency_ms":`)
+	assert.Contains(t, logs, `"Authorization":"[REDACTED]"`)
+	assert.Contains(t, logs, `"http_request_body":{"name":"API_KEY","value":"[REDACTED]"}`)
+	for _, leaked := range []string{"Bearer token", "request-secret", "response-secret"} {
+		assert.False(t, strings.Contains(logs, leaked), "logs contain %q", leaked)
+	}
+}
+
+func TestAPIErrorRequestID(t *testing.T) {
+	var receivedRequestID string
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		receivedRequestID = r.Header.Get(requestIDHeader)
+		w.WriteHeader(http.StatusBadRequest)
+	})
+
+	_, err := c.request(t.Context(), http.MethodPost, c.Project.baseURL, []byte(`{}`))
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, receivedRequestID, apiErr.RequestID)
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
@@ -7,10 +7,6 @@ import (
 	"net/http"
 )
 
-const (
-	m2mBaseURL = "https://iam-api.zapehr.com/v1/m2m"
-)
-
 // M2M corresponds to create and update input, and matches the output format for terraform.
 type M2M struct {
 	ID           *string       `json:"id"`
@@ -65,24 +61,28 @@ func m2mOutputToM2M(m2mOutput *M2MOutput) *M2M {
 }
 
 type m2mClient struct {
-	config *ClientConfig
+	client  *Client
+	baseURL string
 }
 
-func newM2MClient(config *ClientConfig) *m2mClient {
-	return &m2mClient{config}
+func newM2MClient(client *Client) *m2mClient {
+	return &m2mClient{
+		client:  client,
+		baseURL: client.config.Endpoints.endpoint(serviceIAM) + "/v1/m2m",
+	}
 }
 
 func (c *m2mClient) CreateM2M(ctx context.Context, m2m *M2M) (*M2M, error) {
-	url := m2mBaseURL
+	url := c.baseURL
 
 	body, err := json.Marshal(m2m)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal M2M: %w", err)
 	}
 
-	responseBody, err := request(ctx, c.config, http.MethodPost, url, body)
+	responseBody, err := c.client.request(ctx, http.MethodPost, url, body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create M2M: %w; %+v, %s", err, m2m, body)
+		return nil, fmt.Errorf("failed to create M2M: %w", err)
 	}
 
 	var createdM2MOutput M2MOutput
@@ -94,9 +94,9 @@ func (c *m2mClient) CreateM2M(ctx context.Context, m2m *M2M) (*M2M, error) {
 }
 
 func (c *m2mClient) GetM2M(ctx context.Context, id string) (*M2M, error) {
-	url := fmt.Sprintf("%s/%s", m2mBaseURL, id)
+	url := fmt.Sprintf("%s/%s", c.baseURL, id)
 
-	responseBody, err := request(ctx, c.config, http.MethodGet, url, nil)
+	responseBody, err := c.client.request(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get M2M: %w", err)
 	}
@@ -110,13 +110,13 @@ func (c *m2mClient) GetM2M(ctx context.Context, id string) (*M2M, error) {
 }
 
 func (c *m2mClient) UpdateM2M(ctx context.Context, id string, m2m *M2M) (*M2M, error) {
-	url := fmt.Sprintf("%s/%s", m2mBaseURL, id)
+	url := fmt.Sprintf("%s/%s", c.baseURL, id)
 	body, err := json.Marshal(m2m)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal M2M: %w", err)
 	}
 
-	responseBody, err := request(ctx, c.config, http.MethodPatch, url, body)
+	responseBody, err := c.client.request(ctx, http.MethodPatch, url, body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to update M2M: %w", err)
 	}
@@ -130,9 +130,9 @@ func (c *m2mClient) UpdateM2M(ctx context.Context, id string, m2m *M2M) (*M2M, e
 }
 
 func (c *m2mClient) DeleteM2M(ctx context.Context, id string) error {
-	url := fmt.Sprintf("%s/%s", m2mBaseURL, id)
+	url := fmt.Sprintf("%s/%s", c.baseURL, id)
 
-	_, err := request(ctx, c.config, http.MethodDelete, url, nil)
+	_, err := c.client.request(ctx, http.MethodDelete, url, nil)
 	if err != nil {
 		return fmt.Errorf("failed to delete M2M: %w", err)
 	}
@@ -141,9 +141,9 @@ func (c *m2mClient) DeleteM2M(ctx context.Context, id string) error {
 }
 
 func (c *m2mClient) RotateM2MSecret(ctx context.Context, id string) (*string, error) {
-	url := fmt.Sprintf("%s/%s/rotate-secret", m2mBaseURL, id)
+	url := fmt.Sprintf("%s/%s/rotate-secret", c.baseURL, id)
 
-	responseBody, err := request(ctx, c.config, http.MethodPost, url, nil)
+	responseBody, err := c.client.request(ctx, http.MethodPost, url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to rotate M2M secret: %w", err)
 	}
@@ -9,10 +9,6 @@ import (
 	"github.com/hashicorp/terraform-plugin-log/tflog"
 )
 
-const (
-	projectBaseURL = "https://project-api.zapehr.com/v1/project"
-)
-
 type Project struct {
 	ID                 *string `json:"id,omitempty"`
 	Name               *string `json:"name,omitempty"`
@@ -33,19 +29,21 @@ type ProjectUpdateParams struct {
 	DefaultPatientRoleId *string `json:"defaultPatientRoleId,omitempty"`
 }
 type projectClient struct {
-	config *ClientConfig
+	client  *Client
+	baseURL string
 }
 
-func newProjectClient(config *ClientConfig) *projectClient {
+func newProjectClient(client *Client) *projectClient {
 	return &projectClient{
-		config,
+		client:  client,
+		baseURL: client.config.Endpoints.endpoint(serviceProject) + "/v1/project",
 	}
 }
 
 func (c *projectClient) GetProject(ctx context.Context) (*Project, error) {
-	url := projectBaseURL
+	url := c.baseURL
 
-	responseBody, err := request(ctx, c.config, http.MethodGet, url, nil)
+	responseBody, err := c.client.request(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get project: %w", err)
 	}
@@ -59,7 +57,7 @@ func (c *projectClient) GetProject(ctx context.Context) (*Project, error) {
 }
 
 func (c *projectClient) UpdateProject(ctx context.Context, project *ProjectUpdateParams) (*Project, error) {
-	url := projectBaseURL
+	url := c.baseURL
 	body, err := json.Marshal(project)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal project: %w", err)
@@ -69,7 +67,7 @@ func (c *projectClient) UpdateProject(ctx context.Context, project *ProjectUpdat
 		"body": string(body),
 	})
 
-	responseBody, err := request(ctx, c.config, http.MethodPatch, url, body)
+	responseBody, err := c.client.request(ctx, http.MethodPatch, url, body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to update project: %w", err)
 	}
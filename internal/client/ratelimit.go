@@ -0,0 +1,166 @@
+package client
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// DefaultRateLimit applies to each rate limited service without an override.
+// Terraform runs up to 10 operations in parallel by default, so a full round of
+// operations can start at once.
+var DefaultRateLimit = RateLimit{RequestsPerSecond: 10, Burst: 10}
+
+// RateLimit configures a token bucket allowing RequestsPerSecond requests on
+// average, in bursts of up to Burst. A RequestsPerSecond of zero disables
+// limiting.
+type RateLimit struct {
+	RequestsPerSecond float64
+	Burst             int
+}
+
+// RateLimits overrides the client-side rate limit of each service. Services
+// left nil use DefaultRateLimit.
+type RateLimits struct {
+	Fhir   *RateLimit
+	IAM    *RateLimit
+	Z3     *RateLimit
+	Zambda *RateLimit
+}
+
+func (r *RateLimits) limit(s service) RateLimit {
+	var limit *RateLimit
+	if r != nil {
+		switch s {
+		case serviceFhir:
+			limit = r.Fhir
+		case serviceIAM:
+			limit = r.IAM
+		case serviceZ3:
+			limit = r.Z3
+		case serviceZambda:
+			limit = r.Zambda
+		}
+	}
+	if limit == nil {
+		return DefaultRateLimit
+	}
+	return *limit
+}
+
+// rateLimitedServices share one limiter per service across all subclients,
+// e.g. zambdas and secrets both draw from the Zambda limiter.
+var rateLimitedServices = []service{serviceFhir, serviceIAM, serviceZ3, serviceZambda}
+
+type serviceLimiter struct {
+	service service
+	prefix  string
+	limiter *rateLimiter
+}
+
+func newServiceLimiters(config *ClientConfig) []serviceLimiter {
+	var limiters []serviceLimiter
+	for _, s := range rateLimitedServices {
+		limit := config.RateLimits.limit(s)
+		if limit.RequestsPerSecond <= 0 {
+			continue
+		}
+		limiters = append(limiters, serviceLimiter{
+			service: s,
+			prefix:  config.Endpoints.endpoint(s) + "/",
+			limiter: newRateLimiter(limit, time.Now),
+		})
+	}
+	return limiters
+}
+
+// waitForRateLimit blocks until the limiter of the service serving url admits
+// a request.
+func (c *Client) waitForRateLimit(ctx context.Context, url string) error {
+	// Endpoints may share a host, so prefer the most specific match
+	var match *serviceLimiter
+	for i, l := range c.limiters {
+		if (strings.HasPrefix(url, l.prefix) || url+"/" == l.prefix) && (match == nil || len(l.prefix) > len(match.prefix)) {
+			match = &c.limiters[i]
+		}
+	}
+	if match == nil {
+		return nil
+	}
+
+	waited, err := match.limiter.Wait(ctx)
+	if waited > 0 {
+		tflog.Debug(ctx, "Waited for client-side rate limit", map[string]any{
+			"service": string(match.service),
+			"wait_ms": waited.Milliseconds(),
+		})
+	}
+	return err
+}
+
+// rateLimiter is a token bucket. Tokens may go negative, in which case each
+// caller waits until its token has been refilled, which serves callers in the
+// order they arrived.
+type rateLimiter struct {
+	rate  float64
+	burst float64
+	now   func() time.Time
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(limit RateLimit, now func() time.Time) *rateLimiter {
+	burst := float64(max(limit.Burst, 1))
+	return &rateLimiter{
+		rate:   limit.RequestsPerSecond,
+		burst:  burst,
+		now:    now,
+		tokens: burst,
+		last:   now(),
+	}
+}
+
+// reserve takes a token and returns how long the caller must wait before using
+// it.
+func (l *rateLimiter) reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
+
+// release returns a reserved token that was not used.
+func (l *rateLimiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.tokens = min(l.burst, l.tokens+1)
+}
+
+// Wait blocks until a request may be made and returns how long it waited.
+func (l *rateLimiter) Wait(ctx context.Context) (time.Duration, error) {
+	delay := l.reserve()
+	if delay <= 0 {
+		return 0, nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return delay, nil
+	case <-ctx.Done():
+		l.release()
+		return 0, ctx.Err()
+	}
+}
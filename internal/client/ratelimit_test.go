@@ -0,0 +1,72 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiterReserve(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := newRateLimiter(RateLimit{RequestsPerSecond: 2, Burst: 2}, func() time.Time { return now })
+
+	// The burst is available immediately
+	assert.Equal(t, time.Duration(0), l.reserve())
+	assert.Equal(t, time.Duration(0), l.reserve())
+	// Then callers queue behind each other at the refill rate
+	assert.Equal(t, 500*time.Millisecond, l.reserve())
+	assert.Equal(t, time.Second, l.reserve())
+
+	// After the queue has drained the bucket refills up to the burst only
+	now = now.Add(time.Hour)
+	assert.Equal(t, time.Duration(0), l.reserve())
+	assert.Equal(t, time.Duration(0), l.reserve())
+	assert.Equal(t, 500*time.Millisecond, l.reserve())
+}
+
+func TestRateLimiterWaitCancelled(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := newRateLimiter(RateLimit{RequestsPerSecond: 1, Burst: 1}, func() time.Time { return now })
+	assert.Equal(t, time.Duration(0), l.reserve())
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	waited, err := l.Wait(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, time.Duration(0), waited)
+
+	// The cancelled caller's token was returned, so the next caller does not
+	// queue behind it
+	assert.Equal(t, time.Second, l.reserve())
+}
+
+func TestWaitForRateLimit(t *testing.T) {
+	base := "http://localhost:8080"
+	zambdaLimit := RateLimit{RequestsPerSecond: 1, Burst: 1}
+	c := New(&ClientConfig{
+		Endpoints: &Endpoints{Base: &base},
+		RateLimits: &RateLimits{
+			Fhir:   &RateLimit{RequestsPerSecond: 0},
+			Zambda: &zambdaLimit,
+		},
+	})
+
+	services := make([]service, len(c.limiters))
+	for i, l := range c.limiters {
+		services[i] = l.service
+	}
+	assert.Equal(t, []service{serviceIAM, serviceZ3, serviceZambda}, services)
+
+	ctx, cancel := context.WithTimeout(t.Context(), 100*time.Millisecond)
+	defer cancel()
+	// Zambdas and secrets share the Zambda limiter, so the second request waits
+	assert.NoError(t, c.waitForRateLimit(ctx, c.Zambda.baseURL))
+	assert.ErrorIs(t, c.waitForRateLimit(ctx, c.Secret.baseURL), context.DeadlineExceeded)
+	// FHIR is unlimited, as are services without a limiter
+	assert.NoError(t, c.waitForRateLimit(ctx, c.Fhir.baseURL+"/Patient"))
+	assert.NoError(t, c.waitForRateLimit(ctx, c.Fhir.baseURL+"/Patient"))
+	assert.NoError(t, c.waitForRateLimit(ctx, c.Project.baseURL))
+	assert.NoError(t, c.waitForRateLimit(ctx, c.Project.baseURL))
+}
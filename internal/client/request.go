@@ -2,48 +2,196 @@ package client
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"maps"
 	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/masslight/terraform-provider-oystehr/internal/retry"
+)
+
+const (
+	idempotencyKeyHeader = "Idempotency-Key"
+	// minCompressedBodySize is the smallest request body that is compressed
+	// when compression is enabled; smaller bodies gain too little.
+	minCompressedBodySize = 1024
 )
 
-func request(ctx context.Context, config *ClientConfig, method, url string, body []byte) ([]byte, error) {
-	return requestWithHeaders(ctx, config, method, url, body, nil)
+func (c *Client) request(ctx context.Context, method, url string, body []byte) ([]byte, error) {
+	return c.requestWithHeaders(ctx, method, url, body, nil)
+}
+
+// requestWithHeaders sends an authenticated API request, retrying throttled
+// and transiently failing attempts. Transient failures are only retried for
+// idempotent methods, or when the caller supplies an idempotency key.
+func (c *Client) requestWithHeaders(ctx context.Context, method, url string, body []byte, headers map[string]string) ([]byte, error) {
+	_, hasIdempotencyKey := headers[idempotencyKeyHeader]
+	idempotent := isIdempotent(method) || hasIdempotencyKey
+	// Compress once rather than for each attempt
+	if c.config.CompressRequests && len(body) >= minCompressedBodySize {
+		compressed, err := gzipBody(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compress request body: %w", err)
+		}
+		body = compressed
+		headers = maps.Clone(headers)
+		if headers == nil {
+			headers = map[string]string{}
+		}
+		headers["Content-Encoding"] = "gzip"
+	}
+	return retry.RetryWithBackoff(ctx, func() ([]byte, error) {
+		accessToken, err := c.tokens.Token(ctx)
+		if err != nil {
+			return nil, tokenError(fmt.Errorf("failed to get access token: %w", err))
+		}
+		responseBody, err := c.doRequest(ctx, method, url, body, headers, accessToken, idempotent)
+		// The token may have been revoked or expired early; retry once with a
+		// freshly minted one.
+		if isUnauthorized(err) && c.tokens.Invalidate(accessToken) {
+			accessToken, err = c.tokens.Token(ctx)
+			if err != nil {
+				return nil, tokenError(fmt.Errorf("failed to refresh access token: %w", err))
+			}
+			responseBody, err = c.doRequest(ctx, method, url, body, headers, accessToken, idempotent)
+		}
+		return responseBody, err
+	}, c.retryConfig)
 }
 
-func requestWithHeaders(ctx context.Context, config *ClientConfig, method, url string, body []byte, headers map[string]string) ([]byte, error) {
-	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewBuffer(body))
+func (c *Client) doRequest(ctx context.Context, method, url string, body []byte, headers map[string]string, accessToken string, idempotent bool) ([]byte, error) {
+	// Wait before starting the attempt timeout, which only bounds the request
+	if err := c.waitForRateLimit(ctx, url); err != nil {
+		return nil, retry.Permanent(fmt.Errorf("failed waiting for rate limit: %w", err))
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.requestTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, retry.Permanent(fmt.Errorf("failed to create request: %w", err))
 	}
 	req.Header.Set("Content-Type", "application/json")
 	for k, v := range headers {
 		req.Header.Set(k, v)
 	}
 
-	accessToken, err := getAccessToken(ctx, config)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get access token: %w", err)
-	}
 	req.Header.Set("Authorization", "Bearer "+accessToken)
-	req.Header.Set("x-oystehr-project-id", *config.ProjectID)
+	req.Header.Set("x-oystehr-project-id", *c.config.ProjectID)
+	requestID := uuid.NewString()
+	req.Header.Set(requestIDHeader, requestID)
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+		err = fmt.Errorf("failed to send request: %w", err)
+		if idempotent && isConnectionReset(err) {
+			return nil, err
+		}
+		return nil, retry.Permanent(err)
 	}
 	defer resp.Body.Close()
 
 	responseBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
+		err = fmt.Errorf("failed to read response body: %w", err)
+		if idempotent && isConnectionReset(err) {
+			return nil, err
+		}
+		return nil, retry.Permanent(err)
 	}
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("unexpected status code: %d, response body: %s", resp.StatusCode, string(responseBody))
+		apiErr := newAPIError(method, url, resp.StatusCode, resp.Header, responseBody)
+		if apiErr.RequestID == "" {
+			apiErr.RequestID = requestID
+		}
+		// A throttled request was rejected before being processed, so it is
+		// safe to retry regardless of method.
+		if resp.StatusCode == http.StatusTooManyRequests || (idempotent && isTransientStatus(resp.StatusCode)) {
+			if delay, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+				return nil, retry.After(apiErr, delay)
+			}
+			return nil, apiErr
+		}
+		return nil, retry.Permanent(apiErr)
 	}
 
 	return responseBody, nil
 }
+
+// tokenError marks a failure to get an access token as permanent unless the
+// token endpoint was throttled, failed with a server error or reset the
+// connection. Rejected credentials and other client errors are not retried.
+func tokenError(err error) error {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		if apiErr.StatusCode != http.StatusTooManyRequests && apiErr.StatusCode < 500 {
+			return retry.Permanent(err)
+		}
+		if delay, ok := parseRetryAfter(apiErr.Header.Get("Retry-After")); ok {
+			return retry.After(err, delay)
+		}
+		return err
+	}
+	if isConnectionReset(err) {
+		return err
+	}
+	return retry.Permanent(err)
+}
+
+func gzipBody(body []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(body); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+func isTransientStatus(statusCode int) bool {
+	switch statusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+func isConnectionReset(err error) bool {
+	return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF)
+}
+
+// parseRetryAfter parses a Retry-After header given either in seconds or as an
+// HTTP date.
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0), true
+	}
+	return 0, false
+}
@@ -0,0 +1,179 @@
+package client
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/masslight/terraform-provider-oystehr/internal/retry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestClient(t *testing.T, handler http.HandlerFunc) *Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	projectID := "project"
+	accessToken := "token"
+	return New(&ClientConfig{
+		ProjectID:   &projectID,
+		AccessToken: &accessToken,
+		Endpoints:   &Endpoints{Base: &server.URL},
+		Retry: &retry.RetryConfig{
+			BaseBackoff: time.Millisecond,
+			MaxBackoff:  time.Millisecond,
+			MaxDuration: retry.MaxDurationDefault,
+			MaxAttempts: retry.MaxAttemptsDefault,
+		},
+	})
+}
+
+func TestRequestRetry(t *testing.T) {
+	tt := []struct {
+		name             string
+		method           string
+		headers          map[string]string
+		status           int
+		expectedAttempts int32
+	}{
+		{
+			name:             "idempotent method retries transient status",
+			method:           http.MethodGet,
+			status:           http.StatusServiceUnavailable,
+			expectedAttempts: 3,
+		},
+		{
+			name:             "non-idempotent method does not retry transient status",
+			method:           http.MethodPost,
+			status:           http.StatusBadGateway,
+			expectedAttempts: 1,
+		},
+		{
+			name:             "idempotency key allows retrying non-idempotent method",
+			method:           http.MethodPost,
+			headers:          map[string]string{idempotencyKeyHeader: "key"},
+			status:           http.StatusGatewayTimeout,
+			expectedAttempts: 3,
+		},
+		{
+			name:             "throttling retries any method",
+			method:           http.MethodPost,
+			status:           http.StatusTooManyRequests,
+			expectedAttempts: 3,
+		},
+		{
+			name:             "client errors are not retried",
+			method:           http.MethodGet,
+			status:           http.StatusBadRequest,
+			expectedAttempts: 1,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				attempts.Add(1)
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(tc.status)
+			})
+			_, err := c.requestWithHeaders(t.Context(), tc.method, c.config.Endpoints.endpoint(serviceZambda), nil, tc.headers)
+			assert.Error(t, err)
+			assert.Equal(t, tc.expectedAttempts, attempts.Load())
+			var apiErr *APIError
+			if assert.ErrorAs(t, err, &apiErr) {
+				assert.Equal(t, tc.status, apiErr.StatusCode)
+			}
+		})
+	}
+}
+
+func TestRequestRetrySucceeds(t *testing.T) {
+	var attempts atomic.Int32
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		assert.Equal(t, "project", r.Header.Get("x-oystehr-project-id"))
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	})
+	body, err := c.request(t.Context(), http.MethodPost, c.config.Endpoints.endpoint(serviceZambda), []byte(`{}`))
+	assert.NoError(t, err)
+	assert.Equal(t, `{"ok":true}`, string(body))
+	assert.Equal(t, int32(2), attempts.Load())
+}
+
+func TestRequestCompression(t *testing.T) {
+	large := `{"resourceType":"Bundle","entry":[` + strings.Repeat(`{"resource":{"resourceType":"Patient"}},`, 50) + `{}]}`
+	tt := []struct {
+		name               string
+		compress           bool
+		body               string
+		expectedCompressed bool
+	}{
+		{
+			name:               "large body is compressed",
+			compress:           true,
+			body:               large,
+			expectedCompressed: true,
+		},
+		{
+			name:     "small body is sent as is",
+			compress: true,
+			body:     `{}`,
+		},
+		{
+			name: "compression is off by default",
+			body: large,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var bodies []string
+			c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				var reader io.Reader = r.Body
+				if tc.expectedCompressed {
+					assert.Equal(t, "gzip", r.Header.Get("Content-Encoding"))
+					gzipReader, err := gzip.NewReader(r.Body)
+					require.NoError(t, err)
+					reader = gzipReader
+				} else {
+					assert.Empty(t, r.Header.Get("Content-Encoding"))
+				}
+				body, err := io.ReadAll(reader)
+				require.NoError(t, err)
+				bodies = append(bodies, string(body))
+				if len(bodies) == 1 {
+					w.WriteHeader(http.StatusServiceUnavailable)
+				}
+			})
+			c.config.CompressRequests = tc.compress
+			_, err := c.request(t.Context(), http.MethodPut, c.config.Endpoints.endpoint(serviceZambda), []byte(tc.body))
+			require.NoError(t, err)
+			// The retry sends the same body
+			assert.Equal(t, []string{tc.body, tc.body}, bodies)
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	delay, ok := parseRetryAfter("3")
+	assert.True(t, ok)
+	assert.Equal(t, 3*time.Second, delay)
+
+	delay, ok = parseRetryAfter(time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat))
+	assert.True(t, ok)
+	assert.Equal(t, time.Duration(0), delay)
+
+	_, ok = parseRetryAfter("")
+	assert.False(t, ok)
+	_, ok = parseRetryAfter("soon")
+	assert.False(t, ok)
+}
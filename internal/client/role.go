@@ -12,7 +12,6 @@ type Effect string
 const (
 	EffectAllow Effect = "Allow"
 	EffectDeny  Effect = "Deny"
-	roleBaseURL        = "https://iam-api.zapehr.com/v1/iam/role"
 )
 
 type Rule struct {
@@ -34,21 +33,25 @@ type Role struct {
 }
 
 type roleClient struct {
-	config *ClientConfig
+	client  *Client
+	baseURL string
 }
 
-func newRoleClient(config *ClientConfig) *roleClient {
-	return &roleClient{config}
+func newRoleClient(client *Client) *roleClient {
+	return &roleClient{
+		client:  client,
+		baseURL: client.config.Endpoints.endpoint(serviceIAM) + "/v1/iam/role",
+	}
 }
 
 func (c *roleClient) CreateRole(ctx context.Context, role *Role) (*Role, error) {
-	url := roleBaseURL
+	url := c.baseURL
 	body, err := json.Marshal(role)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal role: %w", err)
 	}
 
-	responseBody, err := request(ctx, c.config, http.MethodPost, url, body)
+	responseBody, err := c.client.request(ctx, http.MethodPost, url, body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create role: %w", err)
 	}
@@ -62,9 +65,9 @@ func (c *roleClient) CreateRole(ctx context.Context, role *Role) (*Role, error)
 }
 
 func (c *roleClient) GetRole(ctx context.Context, id string) (*Role, error) {
-	url := fmt.Sprintf("%s/%s", roleBaseURL, id)
+	url := fmt.Sprintf("%s/%s", c.baseURL, id)
 
-	responseBody, err := request(ctx, c.config, http.MethodGet, url, nil)
+	responseBody, err := c.client.request(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get role: %w", err)
 	}
@@ -78,13 +81,13 @@ func (c *roleClient) GetRole(ctx context.Context, id string) (*Role, error) {
 }
 
 func (c *roleClient) UpdateRole(ctx context.Context, id string, role *Role) (*Role, error) {
-	url := fmt.Sprintf("%s/%s", roleBaseURL, id)
+	url := fmt.Sprintf("%s/%s", c.baseURL, id)
 	body, err := json.Marshal(role)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal role: %w", err)
 	}
 
-	responseBody, err := request(ctx, c.config, http.MethodPatch, url, body)
+	responseBody, err := c.client.request(ctx, http.MethodPatch, url, body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to update role: %w", err)
 	}
@@ -98,9 +101,9 @@ func (c *roleClient) UpdateRole(ctx context.Context, id string, role *Role) (*Ro
 }
 
 func (c *roleClient) DeleteRole(ctx context.Context, id string) error {
-	url := fmt.Sprintf("%s/%s", roleBaseURL, id)
+	url := fmt.Sprintf("%s/%s", c.baseURL, id)
 
-	_, err := request(ctx, c.config, http.MethodDelete, url, nil)
+	_, err := c.client.request(ctx, http.MethodDelete, url, nil)
 	if err != nil {
 		return fmt.Errorf("failed to delete role: %w", err)
 	}
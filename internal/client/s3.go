@@ -1,7 +1,6 @@
 package client
 
 import (
-	"bytes"
 	"context"
 	"fmt"
 	"net/http"
@@ -11,21 +10,43 @@ import (
 	"github.com/masslight/terraform-provider-oystehr/internal/retry"
 )
 
-func uploadToS3(ctx context.Context, url string, source string) error {
-	_, err := retry.RetryWithBackoff(ctx, func() (bool, error) {
-		var body bytes.Buffer
-		data, err := os.ReadFile(fs.CleanPath(source))
+// maxSinglePutSize is the largest object S3 accepts in a single PUT. The APIs
+// only hand out presigned single PUT URLs, so larger files cannot be uploaded.
+// Uploading large Z3 objects in parts needs a Z3 action that signs multipart
+// upload parts, which the API does not offer yet.
+const maxSinglePutSize = 5 << 30
+
+func (c *Client) uploadToS3(ctx context.Context, url string, source string) error {
+	path := fs.CleanPath(source)
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to read source file: %w", err)
+	}
+	if info.Size() > maxSinglePutSize {
+		return fmt.Errorf("source file %s is %d bytes, which exceeds the upload limit of %d bytes", source, info.Size(), int64(maxSinglePutSize))
+	}
+
+	_, err = retry.RetryWithBackoff(ctx, func() (bool, error) {
+		// Stream the file from disk, reopening it for each attempt, rather than
+		// holding it in memory
+		file, err := os.Open(path)
 		if err != nil {
-			return false, fmt.Errorf("failed to read source file: %w", err)
+			return false, retry.Permanent(fmt.Errorf("failed to read source file: %w", err))
 		}
-		body.Write(data)
-		req, err := http.NewRequestWithContext(ctx, http.MethodPut, url, &body)
+		defer file.Close()
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPut, url, file)
 		if err != nil {
-			return false, fmt.Errorf("failed to create request: %w", err)
+			return false, retry.Permanent(fmt.Errorf("failed to create request: %w", err))
+		}
+		// Presigned URLs reject chunked uploads, so the length must be known
+		req.ContentLength = info.Size()
+		if info.Size() == 0 {
+			req.Body = http.NoBody
 		}
 		req.Header.Set("Content-Type", "application/zip")
 
-		resp, err := http.DefaultClient.Do(req)
+		resp, err := c.httpClient.Do(req)
 		if err != nil {
 			return false, fmt.Errorf("failed to upload source code: %w", err)
 		}
@@ -36,6 +57,6 @@ func uploadToS3(ctx context.Context, url string, source string) error {
 		}
 
 		return true, nil
-	}, retry.DefaultRetryConfig)
+	}, c.retryConfig)
 	return err
 }
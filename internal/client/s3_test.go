@@ -0,0 +1,46 @@
+package client
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUploadToS3(t *testing.T) {
+	source := filepath.Join(t.TempDir(), "source.zip")
+	content := strings.Repeat("source", 1024)
+	require.NoError(t, os.WriteFile(source, []byte(content), 0o600))
+
+	var bodies []string
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, int64(len(content)), r.ContentLength)
+		assert.Empty(t, r.TransferEncoding)
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		bodies = append(bodies, string(body))
+		if len(bodies) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	})
+
+	require.NoError(t, c.uploadToS3(t.Context(), c.config.Endpoints.endpoint(serviceZ3)+"/upload", source))
+	// The retry streams the whole file again
+	assert.Equal(t, []string{content, content}, bodies)
+}
+
+func TestUploadToS3MissingFile(t *testing.T) {
+	attempts := 0
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+	})
+
+	err := c.uploadToS3(t.Context(), c.config.Endpoints.endpoint(serviceZ3)+"/upload", filepath.Join(t.TempDir(), "missing.zip"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	assert.Equal(t, 0, attempts)
+}
@@ -7,32 +7,32 @@ import (
 	"net/http"
 )
 
-const (
-	secretBaseURL = "https://zambda-api.zapehr.com/v1/secret"
-)
-
 type Secret struct {
 	Name  *string `json:"name"`
 	Value *string `json:"value"`
 }
 
 type secretClient struct {
-	config *ClientConfig
+	client  *Client
+	baseURL string
 }
 
-func newSecretClient(config *ClientConfig) *secretClient {
-	return &secretClient{config}
+func newSecretClient(client *Client) *secretClient {
+	return &secretClient{
+		client:  client,
+		baseURL: client.config.Endpoints.endpoint(serviceZambda) + "/v1/secret",
+	}
 }
 
 func (c *secretClient) SetSecret(ctx context.Context, secret *Secret) (*Secret, error) {
-	url := secretBaseURL
+	url := c.baseURL
 
 	body, err := json.Marshal(secret)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal secret: %w", err)
 	}
 
-	responseBody, err := request(ctx, c.config, http.MethodPost, url, body)
+	responseBody, err := c.client.request(ctx, http.MethodPost, url, body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create secret: %w", err)
 	}
@@ -46,9 +46,9 @@ func (c *secretClient) SetSecret(ctx context.Context, secret *Secret) (*Secret,
 }
 
 func (c *secretClient) GetSecret(ctx context.Context, name string) (*Secret, error) {
-	url := fmt.Sprintf("%s/%s", secretBaseURL, name)
+	url := fmt.Sprintf("%s/%s", c.baseURL, name)
 
-	responseBody, err := request(ctx, c.config, http.MethodGet, url, nil)
+	responseBody, err := c.client.request(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get secret: %w", err)
 	}
@@ -62,9 +62,9 @@ func (c *secretClient) GetSecret(ctx context.Context, name string) (*Secret, err
 }
 
 func (c *secretClient) DeleteSecret(ctx context.Context, name string) error {
-	url := fmt.Sprintf("%s/%s", secretBaseURL, name)
+	url := fmt.Sprintf("%s/%s", c.baseURL, name)
 
-	_, err := request(ctx, c.config, http.MethodDelete, url, nil)
+	_, err := c.client.request(ctx, http.MethodDelete, url, nil)
 	if err != nil {
 		return fmt.Errorf("failed to delete secret: %w", err)
 	}
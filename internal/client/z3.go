@@ -19,22 +19,22 @@ type Object struct {
 	LastModified *string `json:"lastModified"`
 }
 
-const (
-	z3BaseURL = "https://z3-api.zapehr.com/v1"
-)
-
 type z3Client struct {
-	config *ClientConfig
+	client  *Client
+	baseURL string
 }
 
-func newZ3Client(config *ClientConfig) *z3Client {
-	return &z3Client{config}
+func newZ3Client(client *Client) *z3Client {
+	return &z3Client{
+		client:  client,
+		baseURL: client.config.Endpoints.endpoint(serviceZ3) + "/v1",
+	}
 }
 
 func (c *z3Client) CreateBucket(ctx context.Context, bucket *Bucket) (*Bucket, error) {
-	url := fmt.Sprintf("%s/%s", z3BaseURL, *bucket.Name)
+	url := fmt.Sprintf("%s/%s", c.baseURL, *bucket.Name)
 
-	responseBody, err := request(ctx, c.config, http.MethodPut, url, nil)
+	responseBody, err := c.client.request(ctx, http.MethodPut, url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Bucket: %w", err)
 	}
@@ -48,9 +48,9 @@ func (c *z3Client) CreateBucket(ctx context.Context, bucket *Bucket) (*Bucket, e
 }
 
 func (c *z3Client) GetBucket(ctx context.Context, bucketName string) (*Bucket, error) {
-	url := z3BaseURL
+	url := c.baseURL
 
-	responseBody, err := request(ctx, c.config, http.MethodGet, url, nil)
+	responseBody, err := c.client.request(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get Bucket: %w", err)
 	}
@@ -66,13 +66,13 @@ func (c *z3Client) GetBucket(ctx context.Context, bucketName string) (*Bucket, e
 		}
 	}
 
-	return nil, fmt.Errorf("bucket not found: %s", bucketName)
+	return nil, fmt.Errorf("bucket %s: %w", bucketName, ErrNotFound)
 }
 
 func (c *z3Client) DeleteBucket(ctx context.Context, bucketName string) error {
-	url := fmt.Sprintf("%s/%s", z3BaseURL, bucketName)
+	url := fmt.Sprintf("%s/%s", c.baseURL, bucketName)
 
-	responseBody, err := request(ctx, c.config, http.MethodDelete, url, nil)
+	responseBody, err := c.client.request(ctx, http.MethodDelete, url, nil)
 	if err != nil {
 		return fmt.Errorf("failed to delete Bucket: %w", err)
 	}
@@ -85,9 +85,9 @@ func (c *z3Client) DeleteBucket(ctx context.Context, bucketName string) error {
 }
 
 func (c *z3Client) ListObject(ctx context.Context, bucketName, objectKey string) (*Object, error) {
-	url := fmt.Sprintf("%s/%s/%s", z3BaseURL, bucketName, objectKey)
+	url := fmt.Sprintf("%s/%s/%s", c.baseURL, bucketName, objectKey)
 
-	responseBody, err := request(ctx, c.config, http.MethodGet, url, nil)
+	responseBody, err := c.client.request(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get Object: %w", err)
 	}
@@ -98,7 +98,7 @@ func (c *z3Client) ListObject(ctx context.Context, bucketName, objectKey string)
 	}
 
 	if len(objects) == 0 {
-		return nil, fmt.Errorf("no objects found in bucket %s matching key %s", bucketName, objectKey)
+		return nil, fmt.Errorf("no objects in bucket %s matching key %s: %w", bucketName, objectKey, ErrNotFound)
 	}
 
 	// API returns a list of objects, we assume the first one is the desired object
@@ -115,9 +115,9 @@ func (c *z3Client) ListObject(ctx context.Context, bucketName, objectKey string)
 }
 
 func (c *z3Client) DeleteObject(ctx context.Context, bucketName, objectKey string) error {
-	url := fmt.Sprintf("%s/%s/%s", z3BaseURL, bucketName, objectKey)
+	url := fmt.Sprintf("%s/%s/%s", c.baseURL, bucketName, objectKey)
 
-	_, err := request(ctx, c.config, http.MethodDelete, url, nil)
+	_, err := c.client.request(ctx, http.MethodDelete, url, nil)
 	if err != nil {
 		return fmt.Errorf("failed to delete Object: %w", err)
 	}
@@ -126,13 +126,13 @@ func (c *z3Client) DeleteObject(ctx context.Context, bucketName, objectKey strin
 }
 
 func (c *z3Client) UploadObject(ctx context.Context, bucketName, objectKey, source string) error {
-	url := fmt.Sprintf("%s/%s/%s", z3BaseURL, bucketName, objectKey)
+	url := fmt.Sprintf("%s/%s/%s", c.baseURL, bucketName, objectKey)
 
 	body, err := json.Marshal(map[string]string{"action": "upload"})
 	if err != nil {
 		return fmt.Errorf("failed to marshal upload request: %w", err)
 	}
-	responseBody, err := request(ctx, c.config, http.MethodPost, url, body)
+	responseBody, err := c.client.request(ctx, http.MethodPost, url, body)
 	if err != nil {
 		return fmt.Errorf("failed to upload Object: %w", err)
 	}
@@ -144,5 +144,5 @@ func (c *z3Client) UploadObject(ctx context.Context, bucketName, objectKey, sour
 		return fmt.Errorf("failed to decode response: %w", err)
 	}
 
-	return uploadToS3(ctx, uploadInfo.SignedUrl, source)
+	return c.client.uploadToS3(ctx, uploadInfo.SignedUrl, source)
 }
@@ -72,27 +72,27 @@ type FileInfo struct {
 	Checksum     *string `json:"checksum,omitempty"`
 }
 
-const (
-	zambdaBaseURL = "https://zambda-api.zapehr.com/v1/zambda"
-)
-
 type zambdaClient struct {
-	config *ClientConfig
+	client  *Client
+	baseURL string
 }
 
-func newZambdaClient(config *ClientConfig) *zambdaClient {
-	return &zambdaClient{config}
+func newZambdaClient(client *Client) *zambdaClient {
+	return &zambdaClient{
+		client:  client,
+		baseURL: client.config.Endpoints.endpoint(serviceZambda) + "/v1/zambda",
+	}
 }
 
 func (c *zambdaClient) CreateZambda(ctx context.Context, zambda *ZambdaFunction) (*ZambdaFunction, error) {
-	url := zambdaBaseURL
+	url := c.baseURL
 
 	body, err := json.Marshal(zambda)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal ZambdaFunction: %w", err)
 	}
 
-	responseBody, err := request(ctx, c.config, http.MethodPost, url, body)
+	responseBody, err := c.client.request(ctx, http.MethodPost, url, body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create ZambdaFunction: %w", err)
 	}
@@ -106,9 +106,9 @@ func (c *zambdaClient) CreateZambda(ctx context.Context, zambda *ZambdaFunction)
 }
 
 func (c *zambdaClient) GetZambda(ctx context.Context, id string) (*ZambdaFunction, error) {
-	url := fmt.Sprintf("%s/%s", zambdaBaseURL, id)
+	url := fmt.Sprintf("%s/%s", c.baseURL, id)
 
-	responseBody, err := request(ctx, c.config, http.MethodGet, url, nil)
+	responseBody, err := c.client.request(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get ZambdaFunction: %w", err)
 	}
@@ -122,14 +122,14 @@ func (c *zambdaClient) GetZambda(ctx context.Context, id string) (*ZambdaFunctio
 }
 
 func (c *zambdaClient) UpdateZambda(ctx context.Context, id string, zambda *ZambdaFunction) (*ZambdaFunction, error) {
-	url := fmt.Sprintf("%s/%s", zambdaBaseURL, id)
+	url := fmt.Sprintf("%s/%s", c.baseURL, id)
 
 	body, err := json.Marshal(zambda)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal ZambdaFunction: %w", err)
 	}
 
-	responseBody, err := request(ctx, c.config, http.MethodPatch, url, body)
+	responseBody, err := c.client.request(ctx, http.MethodPatch, url, body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to update ZambdaFunction: %w", err)
 	}
@@ -143,9 +143,9 @@ func (c *zambdaClient) UpdateZambda(ctx context.Context, id string, zambda *Zamb
 }
 
 func (c *zambdaClient) DeleteZambda(ctx context.Context, id string) error {
-	url := fmt.Sprintf("%s/%s", zambdaBaseURL, id)
+	url := fmt.Sprintf("%s/%s", c.baseURL, id)
 
-	_, err := request(ctx, c.config, http.MethodDelete, url, nil)
+	_, err := c.client.request(ctx, http.MethodDelete, url, nil)
 	if err != nil {
 		return fmt.Errorf("failed to delete ZambdaFunction: %w", err)
 	}
@@ -154,7 +154,7 @@ func (c *zambdaClient) DeleteZambda(ctx context.Context, id string) error {
 }
 
 func (c *zambdaClient) UploadZambdaSource(ctx context.Context, id string, source string) error {
-	url := fmt.Sprintf("%s/%s/s3-upload", zambdaBaseURL, id)
+	url := fmt.Sprintf("%s/%s/s3-upload", c.baseURL, id)
 
 	filename := path.Base(source)
 
@@ -163,7 +163,7 @@ func (c *zambdaClient) UploadZambdaSource(ctx context.Context, id string, source
 		return fmt.Errorf("failed to marshal upload request: %w", err)
 	}
 
-	responseBody, err := request(ctx, c.config, http.MethodPost, url, body)
+	responseBody, err := c.client.request(ctx, http.MethodPost, url, body)
 	if err != nil {
 		return fmt.Errorf("failed to initiate Zambda source upload: %w", err)
 	}
@@ -175,5 +175,5 @@ func (c *zambdaClient) UploadZambdaSource(ctx context.Context, id string, source
 		return fmt.Errorf("failed to decode response: %w", err)
 	}
 
-	return uploadToS3(ctx, uploadInfo.SignedUrl, source)
+	return c.client.uploadToS3(ctx, uploadInfo.SignedUrl, source)
 }
@@ -0,0 +1,219 @@
+package credentials
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/masslight/terraform-provider-oystehr/internal/fs"
+)
+
+const (
+	DefaultProfile = "default"
+	DefaultFile    = "~/.oystehr/credentials"
+
+	EnvProjectID       = "OYSTEHR_PROJECT_ID"
+	EnvAccessToken     = "OYSTEHR_ACCESS_TOKEN"
+	EnvClientID        = "OYSTEHR_CLIENT_ID"
+	EnvClientSecret    = "OYSTEHR_CLIENT_SECRET"
+	EnvPrivateKey      = "OYSTEHR_PRIVATE_KEY"
+	EnvPrivateKeyFile  = "OYSTEHR_PRIVATE_KEY_FILE"
+	EnvKeyID           = "OYSTEHR_KEY_ID"
+	EnvProfile         = "OYSTEHR_PROFILE"
+	EnvCredentialsFile = "OYSTEHR_CREDENTIALS_FILE"
+)
+
+// Credentials holds the settings a source can provide. Empty strings are unset.
+type Credentials struct {
+	ProjectID    string
+	AccessToken  string
+	ClientID     string
+	ClientSecret string
+	// PrivateKey is an inline PEM encoded key, PrivateKeyFile a path to one.
+	PrivateKey     string
+	PrivateKeyFile string
+	KeyID          string
+}
+
+func (c Credentials) hasAuth() bool {
+	return c.AccessToken != "" || c.ClientID != "" || c.ClientSecret != "" || c.hasPrivateKey()
+}
+
+func (c Credentials) hasPrivateKey() bool {
+	return c.PrivateKey != "" || c.PrivateKeyFile != ""
+}
+
+// Source is a named set of credentials, e.g. the provider configuration, the
+// environment or a credentials file profile.
+type Source struct {
+	Name string
+	Credentials
+}
+
+// FromEnvironment reads credentials from the OYSTEHR_* environment variables.
+func FromEnvironment(getenv func(string) string) Source {
+	return Source{
+		Name: "environment variables",
+		Credentials: Credentials{
+			ProjectID:      getenv(EnvProjectID),
+			AccessToken:    getenv(EnvAccessToken),
+			ClientID:       getenv(EnvClientID),
+			ClientSecret:   getenv(EnvClientSecret),
+			PrivateKey:     getenv(EnvPrivateKey),
+			PrivateKeyFile: getenv(EnvPrivateKeyFile),
+			KeyID:          getenv(EnvKeyID),
+		},
+	}
+}
+
+// LoadProfile reads a profile from an INI style credentials file:
+//
+//	[default]
+//	project_id    = ...
+//	client_id     = ...
+//	client_secret = ...
+//
+// Private key authentication uses private_key_file and key_id in place of
+// client_secret.
+//
+// A missing file or profile is only an error when required is set, i.e. when
+// the user asked for the file or profile explicitly.
+func LoadProfile(path, profile string, required bool) (Source, error) {
+	source := Source{Name: fmt.Sprintf("profile %q in %s", profile, path)}
+	f, err := os.Open(fs.CleanPath(path))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) && !required {
+			return source, nil
+		}
+		return source, fmt.Errorf("failed to open credentials file: %w", err)
+	}
+	defer f.Close()
+
+	profiles, err := parse(f)
+	if err != nil {
+		return source, fmt.Errorf("failed to parse credentials file %s: %w", path, err)
+	}
+	values, ok := profiles[profile]
+	if !ok {
+		if required {
+			return source, fmt.Errorf("profile %q not found in credentials file %s", profile, path)
+		}
+		return source, nil
+	}
+	source.Credentials = Credentials{
+		ProjectID:      values["project_id"],
+		AccessToken:    values["access_token"],
+		ClientID:       values["client_id"],
+		ClientSecret:   values["client_secret"],
+		PrivateKeyFile: values["private_key_file"],
+		KeyID:          values["key_id"],
+	}
+	return source, nil
+}
+
+func parse(r io.Reader) (map[string]map[string]string, error) {
+	profiles := map[string]map[string]string{}
+	var current map[string]string
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			name := strings.TrimSpace(line[1 : len(line)-1])
+			if _, ok := profiles[name]; !ok {
+				profiles[name] = map[string]string{}
+			}
+			current = profiles[name]
+			continue
+		}
+		key, value, found := strings.Cut(line, "=")
+		if !found {
+			return nil, fmt.Errorf("line %d: expected key = value", lineNumber)
+		}
+		if current == nil {
+			return nil, fmt.Errorf("line %d: key outside of a [profile] section", lineNumber)
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' && value[len(value)-1] == '"' || value[0] == '\'' && value[len(value)-1] == '\'') {
+			value = value[1 : len(value)-1]
+		}
+		current[strings.TrimSpace(key)] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return profiles, nil
+}
+
+// Resolved is the outcome of Resolve, recording which source supplied each
+// setting.
+type Resolved struct {
+	Credentials
+	ProjectIDSource string
+	AuthSource      string
+	// Ignored lists lower precedence sources whose credentials were not used.
+	Ignored []string
+}
+
+// Resolve merges sources, given from highest to lowest precedence. The project
+// ID is taken from the first source that sets it. Authentication settings are
+// taken as a unit from the first source that sets any of them, so that an
+// access token and client credentials from different sources are never mixed.
+func Resolve(sources ...Source) (Resolved, error) {
+	var resolved Resolved
+	for _, source := range sources {
+		if resolved.ProjectID == "" && source.ProjectID != "" {
+			resolved.ProjectID = source.ProjectID
+			resolved.ProjectIDSource = source.Name
+		}
+		if !source.hasAuth() {
+			continue
+		}
+		if resolved.AuthSource != "" {
+			resolved.Ignored = append(resolved.Ignored, source.Name)
+			continue
+		}
+		resolved.AccessToken = source.AccessToken
+		resolved.ClientID = source.ClientID
+		resolved.ClientSecret = source.ClientSecret
+		resolved.PrivateKey = source.PrivateKey
+		resolved.PrivateKeyFile = source.PrivateKeyFile
+		resolved.KeyID = source.KeyID
+		resolved.AuthSource = source.Name
+	}
+
+	if resolved.ProjectID == "" {
+		return resolved, fmt.Errorf("project ID is not set in %s", sourceNames(sources))
+	}
+	if resolved.AuthSource == "" {
+		return resolved, fmt.Errorf("no access token or client credentials are set in %s", sourceNames(sources))
+	}
+	if resolved.PrivateKey != "" && resolved.PrivateKeyFile != "" {
+		return resolved, fmt.Errorf("%s must not set both a private key and a private key file", resolved.AuthSource)
+	}
+	if resolved.ClientSecret != "" && resolved.hasPrivateKey() {
+		return resolved, fmt.Errorf("%s must not set both a client secret and a private key", resolved.AuthSource)
+	}
+	hasAccessToken := resolved.AccessToken != ""
+	hasClientCreds := resolved.ClientID != "" && (resolved.ClientSecret != "" || resolved.hasPrivateKey())
+	// Either neither present or both present
+	if hasAccessToken == hasClientCreds {
+		return resolved, fmt.Errorf("%s must set either an access token or a client ID with a client secret or private key", resolved.AuthSource)
+	}
+	return resolved, nil
+}
+
+func sourceNames(sources []Source) string {
+	names := make([]string, len(sources))
+	for i, source := range sources {
+		names[i] = source.Name
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,158 @@
+package credentials
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testFile = `
+# Oystehr credentials
+[default]
+project_id    = default-project
+client_id     = default-client
+client_secret = "default-secret"
+
+[staging]
+project_id   = staging-project
+access_token = 'staging-token'
+
+[jwt]
+project_id       = jwt-project
+client_id        = jwt-client
+private_key_file = ~/.oystehr/jwt.pem
+key_id           = jwt-key
+`
+
+func writeTestFile(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "credentials")
+	assert.NoError(t, os.WriteFile(path, []byte(testFile), 0600))
+	return path
+}
+
+func TestLoadProfile(t *testing.T) {
+	path := writeTestFile(t)
+
+	source, err := LoadProfile(path, "default", false)
+	assert.NoError(t, err)
+	assert.Equal(t, Credentials{ProjectID: "default-project", ClientID: "default-client", ClientSecret: "default-secret"}, source.Credentials)
+
+	source, err = LoadProfile(path, "staging", true)
+	assert.NoError(t, err)
+	assert.Equal(t, Credentials{ProjectID: "staging-project", AccessToken: "staging-token"}, source.Credentials)
+
+	source, err = LoadProfile(path, "jwt", true)
+	assert.NoError(t, err)
+	assert.Equal(t, Credentials{ProjectID: "jwt-project", ClientID: "jwt-client", PrivateKeyFile: "~/.oystehr/jwt.pem", KeyID: "jwt-key"}, source.Credentials)
+
+	_, err = LoadProfile(path, "missing", true)
+	assert.ErrorContains(t, err, `profile "missing" not found`)
+
+	source, err = LoadProfile(path, "missing", false)
+	assert.NoError(t, err)
+	assert.Equal(t, Credentials{}, source.Credentials)
+}
+
+func TestLoadProfileMissingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing")
+
+	source, err := LoadProfile(path, DefaultProfile, false)
+	assert.NoError(t, err)
+	assert.Equal(t, Credentials{}, source.Credentials)
+
+	_, err = LoadProfile(path, DefaultProfile, true)
+	assert.Error(t, err)
+}
+
+func TestParseErrors(t *testing.T) {
+	_, err := parse(strings.NewReader("client_id = outside\n"))
+	assert.ErrorContains(t, err, "line 1")
+
+	_, err = parse(strings.NewReader("[default]\nnot a pair\n"))
+	assert.ErrorContains(t, err, "line 2")
+}
+
+func TestResolve(t *testing.T) {
+	config := Source{Name: "config"}
+	env := Source{Name: "env", Credentials: Credentials{ProjectID: "env-project", ClientID: "env-client", ClientSecret: "env-secret"}}
+	profile := Source{Name: "profile", Credentials: Credentials{ProjectID: "profile-project", AccessToken: "profile-token"}}
+
+	tt := []struct {
+		name        string
+		sources     []Source
+		expected    Credentials
+		authSource  string
+		ignored     []string
+		expectedErr string
+	}{
+		{
+			name:       "environment wins over profile",
+			sources:    []Source{config, env, profile},
+			expected:   Credentials{ProjectID: "env-project", ClientID: "env-client", ClientSecret: "env-secret"},
+			authSource: "env",
+			ignored:    []string{"profile"},
+		},
+		{
+			name:       "config project with profile credentials",
+			sources:    []Source{{Name: "config", Credentials: Credentials{ProjectID: "config-project"}}, profile},
+			expected:   Credentials{ProjectID: "config-project", AccessToken: "profile-token"},
+			authSource: "profile",
+		},
+		{
+			name:       "config credentials are not mixed with environment credentials",
+			sources:    []Source{{Name: "config", Credentials: Credentials{AccessToken: "config-token"}}, env},
+			expected:   Credentials{ProjectID: "env-project", AccessToken: "config-token"},
+			authSource: "config",
+			ignored:    []string{"env"},
+		},
+		{
+			name:        "missing project",
+			sources:     []Source{{Name: "config", Credentials: Credentials{AccessToken: "token"}}},
+			expectedErr: "project ID is not set in config",
+		},
+		{
+			name:        "missing credentials",
+			sources:     []Source{{Name: "config", Credentials: Credentials{ProjectID: "project"}}, {Name: "env"}},
+			expectedErr: "no access token or client credentials are set in config, env",
+		},
+		{
+			name:        "incomplete client credentials",
+			sources:     []Source{{Name: "config", Credentials: Credentials{ProjectID: "project", ClientID: "client"}}, env},
+			expectedErr: "config must set either an access token or a client ID with a client secret or private key",
+		},
+		{
+			name:       "private key credentials",
+			sources:    []Source{{Name: "config", Credentials: Credentials{ProjectID: "project", ClientID: "client", PrivateKeyFile: "key.pem", KeyID: "kid"}}, env},
+			expected:   Credentials{ProjectID: "project", ClientID: "client", PrivateKeyFile: "key.pem", KeyID: "kid"},
+			authSource: "config",
+			ignored:    []string{"env"},
+		},
+		{
+			name:        "client secret and private key",
+			sources:     []Source{{Name: "config", Credentials: Credentials{ProjectID: "project", ClientID: "client", ClientSecret: "secret", PrivateKey: "pem"}}},
+			expectedErr: "config must not set both a client secret and a private key",
+		},
+		{
+			name:        "inline and file private key",
+			sources:     []Source{{Name: "config", Credentials: Credentials{ProjectID: "project", ClientID: "client", PrivateKey: "pem", PrivateKeyFile: "key.pem"}}},
+			expectedErr: "config must not set both a private key and a private key file",
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			resolved, err := Resolve(tc.sources...)
+			if tc.expectedErr != "" {
+				assert.EqualError(t, err, tc.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, resolved.Credentials)
+			assert.Equal(t, tc.authSource, resolved.AuthSource)
+			assert.Equal(t, tc.ignored, resolved.Ignored)
+		})
+	}
+}
@@ -3,6 +3,7 @@ package fs
 import (
 	"crypto/sha256"
 	"fmt"
+	"io"
 	"os"
 	"path"
 	"strings"
@@ -20,19 +21,18 @@ func CleanPath(p string) string {
 	return path.Clean(ret)
 }
 
-func sha256Hash(data []byte) (string, error) {
-	var hasher = sha256.New()
-	_, err := hasher.Write(data)
+// Sha256HashFile returns the hex encoded SHA-256 of a file, streaming it from
+// disk so large files are never held in memory.
+func Sha256HashFile(path string) (string, error) {
+	file, err := os.Open(CleanPath(path))
 	if err != nil {
-		return "", fmt.Errorf("failed to write data to hasher: %w", err)
+		return "", fmt.Errorf("failed to read source file: %w", err)
 	}
-	return fmt.Sprintf("%x", hasher.Sum(nil)), nil
-}
+	defer file.Close()
 
-func Sha256HashFile(path string) (string, error) {
-	data, err := os.ReadFile(CleanPath(path))
-	if err != nil {
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, file); err != nil {
 		return "", fmt.Errorf("failed to read source file: %w", err)
 	}
-	return sha256Hash(data)
+	return fmt.Sprintf("%x", hasher.Sum(nil)), nil
 }
@@ -0,0 +1,22 @@
+package fs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSha256HashFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "source.zip")
+	require.NoError(t, os.WriteFile(path, []byte("hello"), 0o600))
+
+	hash, err := Sha256HashFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824", hash)
+
+	_, err = Sha256HashFile(filepath.Join(t.TempDir(), "missing.zip"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
@@ -2,7 +2,6 @@ package provider
 
 import (
 	"context"
-	"strings"
 
 	"github.com/hashicorp/terraform-plugin-framework/attr"
 	"github.com/hashicorp/terraform-plugin-framework/path"
@@ -267,7 +266,7 @@ func (r *ApplicationResource) Read(ctx context.Context, req resource.ReadRequest
 
 	app, err := r.client.Application.GetApplication(ctx, id)
 	if err != nil {
-		if strings.Contains(err.Error(), "unexpected status code: 404") {
+		if client.IsNotFound(err) {
 			resp.State.RemoveResource(ctx)
 			return
 		}
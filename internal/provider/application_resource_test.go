@@ -0,0 +1,55 @@
+package provider
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+
+	"github.com/masslight/terraform-provider-oystehr/internal/testing/fakeoystehr"
+)
+
+func TestAccApplicationResource(t *testing.T) {
+	server := newTestAccServer(t)
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		CheckDestroy:             testAccCheckDestroyed(server, "oystehr_application", fakeoystehr.CollectionApplications, "id"),
+		Steps: []resource.TestStep{
+			{
+				Config: server.ProviderConfig() + testAccApplicationResourceConfig(true),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttrSet("oystehr_application.test", "id"),
+					resource.TestCheckResourceAttrSet("oystehr_application.test", "client_id"),
+					resource.TestCheckResourceAttr("oystehr_application.test", "connection_name", "Username-Password-Authentication"),
+					resource.TestCheckResourceAttr("oystehr_application.test", "allowed_callback_urls.0", "https://example.com/callback"),
+				),
+			},
+			{
+				ResourceName:      "oystehr_application.test",
+				ImportState:       true,
+				ImportStateVerify: true,
+			},
+			{
+				// The connection changes with the login method
+				Config: server.ProviderConfig() + testAccApplicationResourceConfig(false),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("oystehr_application.test", "login_with_email_enabled", "false"),
+					resource.TestCheckResourceAttr("oystehr_application.test", "connection_name", "sms"),
+				),
+			},
+		},
+	})
+}
+
+func testAccApplicationResourceConfig(loginWithEmail bool) string {
+	return fmt.Sprintf(`
+resource "oystehr_application" "test" {
+  name                     = "Acceptance test application"
+  login_redirect_uri       = "https://example.com/login"
+  allowed_callback_urls    = ["https://example.com/callback"]
+  login_with_email_enabled = %t
+  passwordless_sms         = %t
+}
+`, loginWithEmail, !loginWithEmail)
+}
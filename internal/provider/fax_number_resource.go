@@ -2,7 +2,6 @@ package provider
 
 import (
 	"context"
-	"strings"
 
 	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
@@ -130,7 +129,7 @@ func (r *FaxNumberResource) Read(ctx context.Context, req resource.ReadRequest,
 
 	route, err := r.client.Fax.GetFaxNumber(ctx, faxNumber)
 	if err != nil {
-		if strings.Contains(err.Error(), "unexpected status code: 404") {
+		if client.IsNotFound(err) {
 			resp.State.RemoveResource(ctx)
 			return
 		}
@@ -0,0 +1,33 @@
+package provider
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+
+	"github.com/masslight/terraform-provider-oystehr/internal/testing/fakeoystehr"
+)
+
+func TestAccFaxNumberResource(t *testing.T) {
+	server := newTestAccServer(t)
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		CheckDestroy:             testAccCheckDestroyed(server, "oystehr_fax_number", fakeoystehr.CollectionFaxNumbers, "number"),
+		Steps: []resource.TestStep{
+			{
+				Config: server.ProviderConfig() + `
+resource "oystehr_fax_number" "test" {}
+`,
+				Check: resource.TestCheckResourceAttr("oystehr_fax_number.test", "number", fakeoystehr.FaxNumber),
+			},
+			{
+				ResourceName:                         "oystehr_fax_number.test",
+				ImportState:                          true,
+				ImportStateId:                        fakeoystehr.FaxNumber,
+				ImportStateVerify:                    true,
+				ImportStateVerifyIdentifierAttribute: "number",
+			},
+		},
+	})
+}
@@ -0,0 +1,565 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringdefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/masslight/terraform-provider-oystehr/internal/client"
+)
+
+type FhirBundleData struct {
+	ID            types.String  `tfsdk:"id"`
+	Entries       types.Dynamic `tfsdk:"entries"`
+	Resources     types.Map     `tfsdk:"resources"`
+	RemovalPolicy types.String  `tfsdk:"removal_policy"`
+}
+
+type FhirBundleResourceData struct {
+	ID          types.String `tfsdk:"id"`
+	Type        types.String `tfsdk:"type"`
+	VersionID   types.String `tfsdk:"version_id"`
+	LastUpdated types.String `tfsdk:"last_updated"`
+}
+
+var (
+	fhirBundleResourceAttributesType = map[string]attr.Type{
+		"id":           types.StringType,
+		"type":         types.StringType,
+		"version_id":   types.StringType,
+		"last_updated": types.StringType,
+	}
+	fhirBundleResourceType = types.ObjectType{
+		AttrTypes: fhirBundleResourceAttributesType,
+	}
+)
+
+// fhirBundleEntry is an element of the entries attribute. Entries are keyed by
+// their full URL, a "urn:uuid:" placeholder that other entries reference.
+type fhirBundleEntry struct {
+	FullURL string
+	Type    string
+	Data    map[string]any
+}
+
+func convertDynamicToFhirBundleEntries(ctx context.Context, entries types.Dynamic) ([]fhirBundleEntry, diag.Diagnostics) {
+	value, diags := terraformValueToValue(ctx, entries.UnderlyingValue())
+	if diags.HasError() {
+		return nil, diags
+	}
+	elements, ok := value.([]any)
+	if !ok {
+		return nil, diag.Diagnostics{diag.NewErrorDiagnostic(
+			"Invalid Entries Type",
+			"Expected a list of entries for the FHIR bundle.",
+		)}
+	}
+
+	result := make([]fhirBundleEntry, len(elements))
+	seen := make(map[string]bool, len(elements))
+	for i, element := range elements {
+		raw, ok := element.(map[string]any)
+		if !ok {
+			return nil, diag.Diagnostics{diag.NewErrorDiagnostic(
+				"Invalid Entry",
+				fmt.Sprintf("Expected entry %d to be an object with full_url, type and data.", i),
+			)}
+		}
+		for k := range raw {
+			if k != "full_url" && k != "type" && k != "data" {
+				return nil, diag.Diagnostics{diag.NewErrorDiagnostic(
+					"Invalid Entry",
+					fmt.Sprintf("Unexpected attribute %q in entry %d. Entries have full_url, type and data.", k, i),
+				)}
+			}
+		}
+		fullURL, _ := raw["full_url"].(string)
+		if !strings.HasPrefix(fullURL, "urn:uuid:") {
+			return nil, diag.Diagnostics{diag.NewErrorDiagnostic(
+				"Invalid Entry",
+				fmt.Sprintf("Expected the full_url of entry %d to be a urn:uuid: placeholder, but got: %q", i, fullURL),
+			)}
+		}
+		if seen[fullURL] {
+			return nil, diag.Diagnostics{diag.NewErrorDiagnostic(
+				"Invalid Entry",
+				fmt.Sprintf("The full_url %s is used by more than one entry.", fullURL),
+			)}
+		}
+		seen[fullURL] = true
+		resourceType, _ := raw["type"].(string)
+		if resourceType == "" {
+			return nil, diag.Diagnostics{diag.NewErrorDiagnostic(
+				"Invalid Entry",
+				fmt.Sprintf("Expected entry %s to have a type.", fullURL),
+			)}
+		}
+		data, _ := raw["data"].(map[string]any)
+		if data == nil {
+			data = make(map[string]any)
+		}
+		result[i] = fhirBundleEntry{FullURL: fullURL, Type: resourceType, Data: data}
+	}
+	return result, nil
+}
+
+func convertFhirBundleEntriesToDynamic(ctx context.Context, entries []fhirBundleEntry) (types.Dynamic, diag.Diagnostics) {
+	elementTypes := make([]attr.Type, len(entries))
+	elements := make([]attr.Value, len(entries))
+	for i, entry := range entries {
+		data, diags := mapToTerraformObject(ctx, entry.Data)
+		if diags.HasError() {
+			return types.DynamicNull(), diags
+		}
+		attributeTypes := map[string]attr.Type{
+			"full_url": types.StringType,
+			"type":     types.StringType,
+			"data":     types.ObjectType{AttrTypes: data.AttributeTypes(ctx)},
+		}
+		element, diags := types.ObjectValue(attributeTypes, map[string]attr.Value{
+			"full_url": types.StringValue(entry.FullURL),
+			"type":     types.StringValue(entry.Type),
+			"data":     data,
+		})
+		if diags.HasError() {
+			return types.DynamicNull(), diags
+		}
+		elementTypes[i] = types.ObjectType{AttrTypes: attributeTypes}
+		elements[i] = element
+	}
+	tuple, diags := types.TupleValue(elementTypes, elements)
+	if diags.HasError() {
+		return types.DynamicNull(), diags
+	}
+	return types.DynamicValue(tuple), nil
+}
+
+func convertRawResourceToFhirBundleResource(rawResource map[string]any) (FhirBundleResourceData, error) {
+	id, _ := rawResource["id"].(string)
+	resourceType, _ := rawResource["resourceType"].(string)
+	meta, _ := rawResource["meta"].(map[string]any)
+	versionID, _ := meta["versionId"].(string)
+	lastUpdated, _ := meta["lastUpdated"].(string)
+	if id == "" || resourceType == "" {
+		return FhirBundleResourceData{}, fmt.Errorf("response resource is missing its id or resourceType")
+	}
+	return FhirBundleResourceData{
+		ID:          types.StringValue(id),
+		Type:        types.StringValue(resourceType),
+		VersionID:   types.StringValue(versionID),
+		LastUpdated: types.StringValue(lastUpdated),
+	}, nil
+}
+
+// replaceReferences rewrites the Reference.reference values of FHIR data that
+// appear in replacements, in place.
+func replaceReferences(value any, replacements map[string]string) {
+	switch v := value.(type) {
+	case map[string]any:
+		for k, elem := range v {
+			if reference, ok := elem.(string); ok && k == "reference" {
+				if replacement, ok := replacements[reference]; ok {
+					v[k] = replacement
+				}
+				continue
+			}
+			replaceReferences(elem, replacements)
+		}
+	case []any:
+		for _, elem := range v {
+			replaceReferences(elem, replacements)
+		}
+	}
+}
+
+var _ resource.Resource = &FhirBundleResource{}
+var _ resource.ResourceWithConfigure = &FhirBundleResource{}
+
+type FhirBundleResource struct {
+	client *client.Client
+}
+
+func NewFhirBundleResource() resource.Resource {
+	return &FhirBundleResource{}
+}
+
+func (r *FhirBundleResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = "oystehr_fhir_bundle"
+}
+
+func (r *FhirBundleResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Attributes: map[string]schema.Attribute{
+			"id": schema.StringAttribute{
+				Computed:    true,
+				Description: "The ID of the bundle, generated by the provider.",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"entries": schema.DynamicAttribute{
+				Required:    true,
+				Description: "The FHIR resources to manage together, as a list of objects with `full_url`, `type` and `data` attributes. Each `full_url` is a unique `urn:uuid:` placeholder, which references in the `data` of other entries may use to refer to the entry's resource. Resources are created, updated and deleted in a single FHIR transaction, so either every change is applied or none are.",
+			},
+			"resources": schema.MapNestedAttribute{
+				Computed:    true,
+				Description: "The resources created for the entries, keyed by `full_url`.",
+				NestedObject: schema.NestedAttributeObject{
+					Attributes: map[string]schema.Attribute{
+						"id": schema.StringAttribute{
+							Computed:    true,
+							Description: "The ID of the FHIR resource.",
+						},
+						"type": schema.StringAttribute{
+							Computed:    true,
+							Description: "The FHIR resource type.",
+						},
+						"version_id": schema.StringAttribute{
+							Computed:    true,
+							Description: "The version ID of the FHIR resource.",
+						},
+						"last_updated": schema.StringAttribute{
+							Computed:    true,
+							Description: "The last updated timestamp of the FHIR resource.",
+						},
+					},
+				},
+			},
+			"removal_policy": schema.StringAttribute{
+				Optional:    true,
+				Computed:    true,
+				Description: "The removal policy for the FHIR resources. Valid values are 'delete' and 'retain'. Defaults to 'delete'.",
+				Default:     stringdefault.StaticString("delete"),
+			},
+		},
+	}
+}
+
+func (r *FhirBundleResource) Configure(_ context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+
+	client, ok := req.ProviderData.(*client.Client)
+	if !ok {
+		resp.Diagnostics.AddError(
+			"Unexpected Provider Data Type",
+			"Expected *sdk.Client but got a different type.",
+		)
+		return
+	}
+
+	r.client = client
+}
+
+func (r *FhirBundleResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	var plan FhirBundleData
+
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	entries, diags := convertDynamicToFhirBundleEntries(ctx, plan.Entries)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	transaction := make([]client.TransactionEntry, len(entries))
+	fullURLs := make([]string, len(entries))
+	for i, entry := range entries {
+		transaction[i] = newFhirBundleCreateEntry(entry)
+		fullURLs[i] = entry.FullURL
+	}
+	created, err := r.client.Fhir.Transaction(ctx, transaction)
+	if err != nil {
+		resp.Diagnostics.AddError("Error Creating FHIR Bundle", err.Error())
+		return
+	}
+
+	// The transaction has been applied, so the resources that could be
+	// recorded are saved even if others could not
+	resources := make(map[string]FhirBundleResourceData, len(entries))
+	resp.Diagnostics.Append(addFhirBundleResources(resources, fullURLs, created)...)
+	plan.ID = types.StringValue(uuid.NewString())
+	plan.Resources, diags = types.MapValueFrom(ctx, fhirBundleResourceType, resources)
+	resp.Diagnostics.Append(diags...)
+	if diags.HasError() {
+		return
+	}
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, plan)...)
+}
+
+func (r *FhirBundleResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	var state FhirBundleData
+
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	entries, diags := convertDynamicToFhirBundleEntries(ctx, state.Entries)
+	resp.Diagnostics.Append(diags...)
+	var resources map[string]FhirBundleResourceData
+	resp.Diagnostics.Append(state.Resources.ElementsAs(ctx, &resources, false)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// Fetch the resources concurrently, so they share a batch bundle
+	fetched := make([]map[string]any, len(entries))
+	errs := make([]error, len(entries))
+	references := make(map[string]string, len(entries))
+	var wg sync.WaitGroup
+	for i, entry := range entries {
+		resource, ok := resources[entry.FullURL]
+		if !ok {
+			continue
+		}
+		references[resource.Type.ValueString()+"/"+resource.ID.ValueString()] = entry.FullURL
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fetched[i], errs[i] = r.client.Fhir.GetResource(ctx, resource.Type.ValueString(), resource.ID.ValueString())
+		}()
+	}
+	wg.Wait()
+
+	// Resources deleted outside of Terraform are dropped from the state, so
+	// they are recreated
+	var refreshed []fhirBundleEntry
+	refreshedResources := make(map[string]FhirBundleResourceData, len(entries))
+	for i, entry := range entries {
+		if errs[i] != nil {
+			if client.IsGone(errs[i]) || client.IsNotFound(errs[i]) {
+				continue
+			}
+			resp.Diagnostics.AddError("Error Reading FHIR Bundle", errs[i].Error())
+			return
+		}
+		if fetched[i] == nil {
+			continue
+		}
+		resource, err := convertRawResourceToFhirBundleResource(fetched[i])
+		if err != nil {
+			resp.Diagnostics.AddError("Error Reading FHIR Bundle", err.Error())
+			return
+		}
+		refreshedResources[entry.FullURL] = resource
+
+		data := fetched[i]
+		delete(data, "id")
+		if meta, ok := data["meta"].(map[string]any); ok {
+			delete(meta, "lastUpdated")
+			delete(meta, "versionId")
+			if len(meta) == 0 {
+				delete(data, "meta")
+			}
+		}
+		replaceReferences(data, references)
+		refreshed = append(refreshed, fhirBundleEntry{FullURL: entry.FullURL, Type: entry.Type, Data: data})
+	}
+	if len(refreshed) == 0 && len(entries) > 0 {
+		resp.State.RemoveResource(ctx)
+		return
+	}
+
+	state.Entries, diags = convertFhirBundleEntriesToDynamic(ctx, refreshed)
+	resp.Diagnostics.Append(diags...)
+	state.Resources, diags = types.MapValueFrom(ctx, fhirBundleResourceType, refreshedResources)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, state)...)
+}
+
+func (r *FhirBundleResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	var plan FhirBundleData
+	var state FhirBundleData
+
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	planEntries, diags := convertDynamicToFhirBundleEntries(ctx, plan.Entries)
+	resp.Diagnostics.Append(diags...)
+	stateEntries, diags := convertDynamicToFhirBundleEntries(ctx, state.Entries)
+	resp.Diagnostics.Append(diags...)
+	var resources map[string]FhirBundleResourceData
+	resp.Diagnostics.Append(state.Resources.ElementsAs(ctx, &resources, false)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// Entries keep their resource unless their type changed. References to
+	// kept resources are resolved here, while the server resolves references
+	// to resources created in the transaction.
+	stateData := make(map[string]map[string]any, len(stateEntries))
+	for _, entry := range stateEntries {
+		stateData[entry.FullURL] = entry.Data
+	}
+	planned := make(map[string]bool, len(planEntries))
+	references := make(map[string]string, len(planEntries))
+	for _, entry := range planEntries {
+		planned[entry.FullURL] = true
+		if resource, ok := resources[entry.FullURL]; ok && resource.Type.ValueString() == entry.Type {
+			references[entry.FullURL] = entry.Type + "/" + resource.ID.ValueString()
+		}
+	}
+
+	// The diff is applied as one transaction
+	var transaction []client.TransactionEntry
+	var fullURLs []string
+	for _, entry := range stateEntries {
+		resource, ok := resources[entry.FullURL]
+		if !ok || references[entry.FullURL] != "" {
+			continue
+		}
+		delete(resources, entry.FullURL)
+		if plan.RemovalPolicy.ValueString() == "delete" {
+			transaction = append(transaction, client.TransactionEntry{
+				Method: http.MethodDelete,
+				URL:    fmt.Sprintf("/%s/%s", resource.Type.ValueString(), resource.ID.ValueString()),
+			})
+			fullURLs = append(fullURLs, "")
+		}
+	}
+	for _, entry := range planEntries {
+		_, kept := references[entry.FullURL]
+		if !kept {
+			replaceReferences(entry.Data, references)
+			transaction = append(transaction, newFhirBundleCreateEntry(entry))
+			fullURLs = append(fullURLs, entry.FullURL)
+			continue
+		}
+		if reflect.DeepEqual(entry.Data, stateData[entry.FullURL]) {
+			continue
+		}
+		replaceReferences(entry.Data, references)
+		transaction = append(transaction, newFhirBundleUpdateEntry(entry, resources[entry.FullURL]))
+		fullURLs = append(fullURLs, entry.FullURL)
+	}
+
+	if len(transaction) > 0 {
+		results, err := r.client.Fhir.Transaction(ctx, transaction)
+		if err != nil {
+			resp.Diagnostics.AddError("Error Updating FHIR Bundle", err.Error())
+			return
+		}
+		// The transaction has been applied, so the resources that could be
+		// recorded are saved even if others could not
+		resp.Diagnostics.Append(addFhirBundleResources(resources, fullURLs, results)...)
+	}
+	for fullURL := range resources {
+		if !planned[fullURL] {
+			delete(resources, fullURL)
+		}
+	}
+
+	plan.ID = state.ID
+	plan.Resources, diags = types.MapValueFrom(ctx, fhirBundleResourceType, resources)
+	resp.Diagnostics.Append(diags...)
+	if diags.HasError() {
+		return
+	}
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, plan)...)
+}
+
+func (r *FhirBundleResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	var state FhirBundleData
+
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if state.RemovalPolicy.ValueString() != "delete" {
+		return
+	}
+
+	var resources map[string]FhirBundleResourceData
+	resp.Diagnostics.Append(state.Resources.ElementsAs(ctx, &resources, false)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	transaction := make([]client.TransactionEntry, 0, len(resources))
+	for _, resource := range resources {
+		transaction = append(transaction, client.TransactionEntry{
+			Method: http.MethodDelete,
+			URL:    fmt.Sprintf("/%s/%s", resource.Type.ValueString(), resource.ID.ValueString()),
+		})
+	}
+	if len(transaction) == 0 {
+		return
+	}
+	if _, err := r.client.Fhir.Transaction(ctx, transaction); err != nil {
+		resp.Diagnostics.AddError("Error Deleting FHIR Bundle", err.Error())
+		return
+	}
+}
+
+func newFhirBundleCreateEntry(entry fhirBundleEntry) client.TransactionEntry {
+	entry.Data["resourceType"] = entry.Type
+	delete(entry.Data, "id")
+	return client.TransactionEntry{
+		FullURL:  entry.FullURL,
+		Method:   http.MethodPost,
+		URL:      "/" + entry.Type,
+		Resource: entry.Data,
+	}
+}
+
+// newFhirBundleUpdateEntry replaces the resource of an entry, guarded by its
+// version when it is known.
+func newFhirBundleUpdateEntry(entry fhirBundleEntry, resource FhirBundleResourceData) client.TransactionEntry {
+	entry.Data["resourceType"] = entry.Type
+	entry.Data["id"] = resource.ID.ValueString()
+	transactionEntry := client.TransactionEntry{
+		FullURL:  entry.FullURL,
+		Method:   http.MethodPut,
+		URL:      fmt.Sprintf("/%s/%s", entry.Type, resource.ID.ValueString()),
+		Resource: entry.Data,
+	}
+	// An empty If-Match would fail the whole transaction
+	if versionID := resource.VersionID.ValueString(); versionID != "" {
+		transactionEntry.IfMatch = fmt.Sprintf(`W/"%s"`, versionID)
+	}
+	return transactionEntry
+}
+
+// addFhirBundleResources records the resources a transaction returned for the
+// entries with a full URL, reporting the entries it could not record.
+func addFhirBundleResources(resources map[string]FhirBundleResourceData, fullURLs []string, results []map[string]any) diag.Diagnostics {
+	var diags diag.Diagnostics
+	for i, fullURL := range fullURLs {
+		if fullURL == "" {
+			continue
+		}
+		resource, err := convertRawResourceToFhirBundleResource(results[i])
+		if err != nil {
+			diags.AddError("Error Decoding FHIR Bundle Response", fmt.Sprintf("entry %s: %s", fullURL, err))
+			continue
+		}
+		resources[fullURL] = resource
+	}
+	return diags
+}
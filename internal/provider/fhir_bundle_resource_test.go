@@ -0,0 +1,170 @@
+package provider
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/terraform"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/masslight/terraform-provider-oystehr/internal/testing/fakeoystehr"
+)
+
+const (
+	testAccOrganizationURL = "urn:uuid:9c3ad5a4-0d0e-4b8e-9a39-5f64c2e0f3a1"
+	testAccLocationURL     = "urn:uuid:1f0c6f0e-8f8b-4c4b-a0a8-3a1c4f6d2b7e"
+	testAccServiceURL      = "urn:uuid:6b2d9e3f-4c1a-4e7d-8f5b-2a9c0d1e3f4b"
+)
+
+func TestAccFhirBundleResource(t *testing.T) {
+	server := newTestAccServer(t)
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		CheckDestroy:             testAccCheckFhirBundleDestroyed(server),
+		Steps: []resource.TestStep{
+			{
+				Config: server.ProviderConfig() + testAccFhirBundleResourceConfig("Main Street", false),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttrSet("oystehr_fhir_bundle.test", "id"),
+					resource.TestCheckResourceAttr("oystehr_fhir_bundle.test", "resources.%", "2"),
+					resource.TestCheckResourceAttr("oystehr_fhir_bundle.test", "resources."+testAccLocationURL+".type", "Location"),
+					resource.TestCheckResourceAttr("oystehr_fhir_bundle.test", "resources."+testAccLocationURL+".version_id", "1"),
+					testAccCheckFhirBundleReference(server, testAccLocationURL, "managingOrganization", testAccOrganizationURL),
+				),
+			},
+			{
+				Config: server.ProviderConfig() + testAccFhirBundleResourceConfig("High Street", true),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("oystehr_fhir_bundle.test", "resources.%", "3"),
+					resource.TestCheckResourceAttr("oystehr_fhir_bundle.test", "resources."+testAccOrganizationURL+".version_id", "1"),
+					resource.TestCheckResourceAttr("oystehr_fhir_bundle.test", "resources."+testAccLocationURL+".version_id", "2"),
+					testAccCheckFhirBundleReference(server, testAccLocationURL, "managingOrganization", testAccOrganizationURL),
+					testAccCheckFhirBundleReference(server, testAccServiceURL, "providedBy", testAccOrganizationURL),
+				),
+			},
+			{
+				Config: server.ProviderConfig() + testAccFhirBundleResourceConfig("High Street", false),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("oystehr_fhir_bundle.test", "resources.%", "2"),
+					resource.TestCheckNoResourceAttr("oystehr_fhir_bundle.test", "resources."+testAccServiceURL+".id"),
+				),
+			},
+		},
+	})
+}
+
+func testAccFhirBundleResourceConfig(street string, withService bool) string {
+	service := ""
+	if withService {
+		service = fmt.Sprintf(`
+    {
+      full_url = %q
+      type     = "HealthcareService"
+      data = {
+        resourceType = "HealthcareService"
+        name         = "Primary care"
+        providedBy   = { reference = %q }
+      }
+    },`, testAccServiceURL, testAccOrganizationURL)
+	}
+	return fmt.Sprintf(`
+resource "oystehr_fhir_bundle" "test" {
+  entries = [
+    {
+      full_url = %q
+      type     = "Organization"
+      data = {
+        resourceType = "Organization"
+        name         = "Clinic"
+      }
+    },
+    {
+      full_url = %q
+      type     = "Location"
+      data = {
+        resourceType         = "Location"
+        name                 = "Clinic"
+        address              = { line = [%q] }
+        managingOrganization = { reference = %q }
+      }
+    },%s
+  ]
+}
+`, testAccOrganizationURL, testAccLocationURL, street, testAccOrganizationURL, service)
+}
+
+// testAccCheckFhirBundleReference checks that a reference of an entry's
+// resource was resolved to the resource of another entry.
+func testAccCheckFhirBundleReference(server *fakeoystehr.Server, fullURL, field, targetURL string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		attributes := s.RootModule().Resources["oystehr_fhir_bundle.test"].Primary.Attributes
+		resourceType := attributes["resources."+fullURL+".type"]
+		stored, ok := server.FhirResource(resourceType, attributes["resources."+fullURL+".id"])
+		if !ok {
+			return fmt.Errorf("%s %s does not exist", resourceType, fullURL)
+		}
+		reference, _ := stored[field].(map[string]any)
+		expected := attributes["resources."+targetURL+".type"] + "/" + attributes["resources."+targetURL+".id"]
+		if reference["reference"] != expected {
+			return fmt.Errorf("expected %s.%s to reference %s, got %v", resourceType, field, expected, reference["reference"])
+		}
+		return nil
+	}
+}
+
+func testAccCheckFhirBundleDestroyed(server *fakeoystehr.Server) func(*terraform.State) error {
+	return func(s *terraform.State) error {
+		for _, rs := range s.RootModule().Resources {
+			if rs.Type != "oystehr_fhir_bundle" {
+				continue
+			}
+			for _, fullURL := range []string{testAccOrganizationURL, testAccLocationURL, testAccServiceURL} {
+				resourceType := rs.Primary.Attributes["resources."+fullURL+".type"]
+				id := rs.Primary.Attributes["resources."+fullURL+".id"]
+				if _, ok := server.FhirResource(resourceType, id); ok {
+					return fmt.Errorf("%s/%s still exists", resourceType, id)
+				}
+			}
+		}
+		return nil
+	}
+}
+
+func TestNewFhirBundleUpdateEntry(t *testing.T) {
+	tt := []struct {
+		name            string
+		versionID       types.String
+		expectedIfMatch string
+	}{
+		{
+			name:            "known version",
+			versionID:       types.StringValue("3"),
+			expectedIfMatch: `W/"3"`,
+		},
+		{
+			name:      "empty version",
+			versionID: types.StringValue(""),
+		},
+		{
+			name:      "null version",
+			versionID: types.StringNull(),
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			entry := newFhirBundleUpdateEntry(
+				fhirBundleEntry{FullURL: "urn:uuid:1", Type: "Patient", Data: map[string]any{"active": true}},
+				FhirBundleResourceData{ID: types.StringValue("1"), Type: types.StringValue("Patient"), VersionID: tc.versionID},
+			)
+			assert.Equal(t, http.MethodPut, entry.Method)
+			assert.Equal(t, "/Patient/1", entry.URL)
+			assert.Equal(t, tc.expectedIfMatch, entry.IfMatch)
+			assert.Equal(t, map[string]any{"resourceType": "Patient", "id": "1", "active": true}, entry.Resource)
+		})
+	}
+}
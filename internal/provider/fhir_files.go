@@ -0,0 +1,333 @@
+package provider
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	iofs "io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/masslight/terraform-provider-oystehr/internal/client"
+	"github.com/masslight/terraform-provider-oystehr/internal/fs"
+)
+
+// fhirFileExtensions are the extensions of the files read from a source
+// directory.
+var fhirFileExtensions = []string{".json", ".ndjson", ".yaml", ".yml"}
+
+// fhirFileResource is a FHIR resource read from a file. Resources with an ID
+// are keyed by "resourceType/id", others by their canonical URL, with the
+// version appended after a "|" when they have one.
+type fhirFileResource struct {
+	Key  string
+	File string
+	Type string
+	ID   string
+	// Query is the search for the resource by its canonical URL, used to
+	// create or adopt resources without an ID
+	Query string
+	Data  map[string]any
+	Hash  string
+}
+
+// loadFhirFiles reads the resources of the files of a source, which is either a
+// directory, walked recursively for FHIR files, or a glob pattern.
+func loadFhirFiles(source string) ([]fhirFileResource, error) {
+	files, dir, err := fhirSourceFiles(source)
+	if err != nil {
+		return nil, err
+	}
+
+	var resources []fhirFileResource
+	keyFiles := make(map[string]string)
+	for _, file := range files {
+		name := file
+		if dir != "" {
+			if rel, err := filepath.Rel(dir, file); err == nil {
+				name = rel
+			}
+		}
+		contents, err := readFhirFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		for _, data := range contents {
+			resource, err := newFhirFileResource(name, data)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			if other, ok := keyFiles[resource.Key]; ok {
+				return nil, fmt.Errorf("%s is defined in both %s and %s", resource.Key, other, name)
+			}
+			keyFiles[resource.Key] = name
+			resources = append(resources, resource)
+		}
+	}
+	return resources, nil
+}
+
+// fhirSourceFiles resolves a source to its files, returning the directory the
+// file names are relative to for a directory source.
+func fhirSourceFiles(source string) ([]string, string, error) {
+	source = fs.CleanPath(source)
+	if info, err := os.Stat(source); err == nil && info.IsDir() {
+		var files []string
+		err := filepath.WalkDir(source, func(path string, entry iofs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !entry.IsDir() && slices.Contains(fhirFileExtensions, strings.ToLower(filepath.Ext(path))) {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to read source directory: %w", err)
+		}
+		if len(files) == 0 {
+			return nil, "", fmt.Errorf("no FHIR resource files found in %s", source)
+		}
+		return files, source, nil
+	}
+
+	matches, err := filepath.Glob(source)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid glob pattern %q: %w", source, err)
+	}
+	var files []string
+	for _, match := range matches {
+		if info, err := os.Stat(match); err == nil && !info.IsDir() {
+			files = append(files, match)
+		}
+	}
+	if len(files) == 0 {
+		return nil, "", fmt.Errorf("no files match %s", source)
+	}
+	return files, "", nil
+}
+
+// readFhirFile reads the resources of a file: a resource for .json, one per
+// line for .ndjson and one per document for .yaml and .yml.
+func readFhirFile(file string) ([]map[string]any, error) {
+	contents, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	var resources []map[string]any
+	switch ext := strings.ToLower(filepath.Ext(file)); ext {
+	case ".json":
+		var resource map[string]any
+		if err := decodeJSON(contents, &resource); err != nil {
+			return nil, fmt.Errorf("failed to decode JSON: %w", err)
+		}
+		resources = append(resources, resource)
+	case ".ndjson":
+		scanner := bufio.NewScanner(bytes.NewReader(contents))
+		scanner.Buffer(nil, len(contents)+1)
+		for line := 1; scanner.Scan(); line++ {
+			if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+				continue
+			}
+			var resource map[string]any
+			if err := decodeJSON(scanner.Bytes(), &resource); err != nil {
+				return nil, fmt.Errorf("line %d: failed to decode JSON: %w", line, err)
+			}
+			resources = append(resources, resource)
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read file: %w", err)
+		}
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(contents))
+		for document := 1; ; document++ {
+			var node yaml.Node
+			if err := decoder.Decode(&node); errors.Is(err, io.EOF) {
+				break
+			} else if err != nil {
+				return nil, fmt.Errorf("failed to decode YAML: %w", err)
+			}
+			value, err := yamlValue(&node)
+			if err != nil {
+				return nil, fmt.Errorf("document %d: %w", document, err)
+			}
+			if value == nil {
+				continue
+			}
+			// Round trip through JSON, so the values have the same types as
+			// those of JSON files
+			encoded, err := json.Marshal(value)
+			if err != nil {
+				return nil, fmt.Errorf("document %d: %w", document, err)
+			}
+			var resource map[string]any
+			if err := decodeJSON(encoded, &resource); err != nil {
+				return nil, fmt.Errorf("document %d: expected an object", document)
+			}
+			resources = append(resources, resource)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported file extension %q, expected one of %s", ext, strings.Join(fhirFileExtensions, ", "))
+	}
+	return resources, nil
+}
+
+// jsonNumberPattern matches the numbers of JSON.
+var jsonNumberPattern = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]+)?$`)
+
+// yamlValue decodes a YAML node as yaml.v3 does, except that numbers written
+// as in JSON become json.Number, so they are read exactly rather than rounded
+// to a float64, and timestamps, such as a FHIR date, are kept as written.
+func yamlValue(node *yaml.Node) (any, error) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) == 0 {
+			return nil, nil
+		}
+		return yamlValue(node.Content[0])
+	case yaml.AliasNode:
+		return yamlValue(node.Alias)
+	case yaml.MappingNode:
+		object := make(map[string]any, len(node.Content)/2)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].ShortTag() == "!!merge" {
+				return nil, fmt.Errorf("line %d: merge keys are not supported", node.Content[i].Line)
+			}
+			var key string
+			if err := node.Content[i].Decode(&key); err != nil {
+				return nil, err
+			}
+			value, err := yamlValue(node.Content[i+1])
+			if err != nil {
+				return nil, err
+			}
+			object[key] = value
+		}
+		return object, nil
+	case yaml.SequenceNode:
+		array := make([]any, len(node.Content))
+		for i, child := range node.Content {
+			value, err := yamlValue(child)
+			if err != nil {
+				return nil, err
+			}
+			array[i] = value
+		}
+		return array, nil
+	}
+
+	switch tag := node.ShortTag(); {
+	case tag == "!!timestamp":
+		return node.Value, nil
+	case (tag == "!!int" || tag == "!!float") && jsonNumberPattern.MatchString(node.Value):
+		return json.Number(node.Value), nil
+	}
+	var value any
+	err := node.Decode(&value)
+	return value, err
+}
+
+func newFhirFileResource(file string, data map[string]any) (fhirFileResource, error) {
+	resourceType, _ := data["resourceType"].(string)
+	if resourceType == "" {
+		return fhirFileResource{}, fmt.Errorf("resource has no resourceType")
+	}
+	hash, err := fhirResourceHash(data)
+	if err != nil {
+		return fhirFileResource{}, err
+	}
+	resource := fhirFileResource{
+		File: file,
+		Type: resourceType,
+		Data: data,
+		Hash: hash,
+	}
+
+	if id, _ := data["id"].(string); id != "" {
+		resource.Key = resourceType + "/" + id
+		resource.ID = id
+		return resource, nil
+	}
+	canonicalURL, _ := data["url"].(string)
+	if canonicalURL == "" {
+		return fhirFileResource{}, fmt.Errorf("%s has neither an id nor a canonical url to key it by", resourceType)
+	}
+	query := url.Values{"url": {canonicalURL}}
+	resource.Key = canonicalURL
+	if version, _ := data["version"].(string); version != "" {
+		query.Set("version", version)
+		resource.Key += "|" + version
+	}
+	resource.Query = query.Encode()
+	return resource, nil
+}
+
+// putFhirFileResource writes the resource of a file. The resource with id is
+// updated when it is set, checking its version when versionID is set too.
+// Otherwise the resource is created or updated with its own ID, or by a
+// conditional update on its canonical URL.
+func putFhirFileResource(ctx context.Context, c *client.Client, resource fhirFileResource, id, versionID string) (map[string]any, error) {
+	switch {
+	case id != "":
+		resource.Data["id"] = id
+		return c.Fhir.UpdateResource(ctx, resource.Type, id, versionID, resource.Data)
+	case resource.ID != "":
+		return c.Fhir.UpdateResource(ctx, resource.Type, resource.ID, "", resource.Data)
+	default:
+		return c.Fhir.ConditionalUpdateResource(ctx, resource.Type, resource.Query, "", resource.Data)
+	}
+}
+
+// canonicalNumbers replaces the numbers in a copied resource with their
+// shortest form, so that 1.50 and 1.5 are equal.
+func canonicalNumbers(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for k, elem := range v {
+			v[k] = canonicalNumbers(elem)
+		}
+	case []any:
+		for i, elem := range v {
+			v[i] = canonicalNumbers(elem)
+		}
+	case json.Number:
+		if bf, err := parseNumber(v); err == nil {
+			return formatNumber(bf)
+		}
+	}
+	return value
+}
+
+// fhirResourceHash returns the hex encoded SHA-256 of the content of a
+// resource, leaving out the fields the server assigns: id, meta.versionId and
+// meta.lastUpdated.
+func fhirResourceHash(resource map[string]any) (string, error) {
+	content := deepCopy(resource).(map[string]any)
+	delete(content, "id")
+	if meta, ok := content["meta"].(map[string]any); ok {
+		delete(meta, "versionId")
+		delete(meta, "lastUpdated")
+		if len(meta) == 0 {
+			delete(content, "meta")
+		}
+	}
+	// Maps are encoded with sorted keys and numbers in their shortest form, so
+	// equal content hashes the same
+	encoded, err := json.Marshal(canonicalNumbers(content))
+	if err != nil {
+		return "", fmt.Errorf("failed to encode resource: %w", err)
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(encoded)), nil
+}
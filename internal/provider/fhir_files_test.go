@@ -0,0 +1,233 @@
+package provider
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadFhirFile(t *testing.T) {
+	tt := []struct {
+		name     string
+		file     string
+		content  string
+		expected []map[string]any
+		err      string
+	}{
+		{
+			name:     "json",
+			file:     "patient.json",
+			content:  `{"resourceType": "Patient", "id": "1", "multipleBirthInteger": 2}`,
+			expected: []map[string]any{{"resourceType": "Patient", "id": "1", "multipleBirthInteger": json.Number("2")}},
+		},
+		{
+			name:    "ndjson",
+			file:    "patients.ndjson",
+			content: "{\"resourceType\": \"Patient\", \"id\": \"1\"}\n\n{\"resourceType\": \"Patient\", \"id\": \"2\"}\n",
+			expected: []map[string]any{
+				{"resourceType": "Patient", "id": "1"},
+				{"resourceType": "Patient", "id": "2"},
+			},
+		},
+		{
+			name:    "yaml",
+			file:    "value-sets.yaml",
+			content: "resourceType: ValueSet\nurl: https://example.com/a\ndate: 2024-01-01\n---\nresourceType: ValueSet\nurl: https://example.com/b\nextension:\n  - url: https://example.com/ext\n    valueInteger: 2\n",
+			expected: []map[string]any{
+				{"resourceType": "ValueSet", "url": "https://example.com/a", "date": "2024-01-01"},
+				{"resourceType": "ValueSet", "url": "https://example.com/b", "extension": []any{
+					map[string]any{"url": "https://example.com/ext", "valueInteger": json.Number("2")},
+				}},
+			},
+		},
+		{
+			name:    "exact json numbers",
+			file:    "observation.json",
+			content: `{"resourceType": "Observation", "valueQuantity": {"value": 0.1000000000000000055511151231257827}, "extension": [{"valueInteger64": 9007199254740993}]}`,
+			expected: []map[string]any{{
+				"resourceType":  "Observation",
+				"valueQuantity": map[string]any{"value": json.Number("0.1000000000000000055511151231257827")},
+				"extension":     []any{map[string]any{"valueInteger64": json.Number("9007199254740993")}},
+			}},
+		},
+		{
+			name:    "exact yaml numbers",
+			file:    "observation.yaml",
+			content: "resourceType: Observation\nvalueQuantity:\n  value: 1.50\nextension:\n  - valueInteger64: 9007199254740993\n    valueString: \"2\"\n    valueDecimal: .5\n",
+			expected: []map[string]any{{
+				"resourceType":  "Observation",
+				"valueQuantity": map[string]any{"value": json.Number("1.50")},
+				"extension": []any{map[string]any{
+					"valueInteger64": json.Number("9007199254740993"),
+					"valueString":    "2",
+					"valueDecimal":   json.Number("0.5"),
+				}},
+			}},
+		},
+		{
+			name:    "trailing json data",
+			file:    "patient.json",
+			content: `{"resourceType": "Patient"} {}`,
+			err:     "failed to decode JSON: invalid data after top-level value",
+		},
+		{
+			name:    "invalid ndjson line",
+			file:    "patients.ndjson",
+			content: "{\"resourceType\": \"Patient\"}\n{\n",
+			err:     "line 2: failed to decode JSON: unexpected EOF",
+		},
+		{
+			name:    "yaml document that is not an object",
+			file:    "list.yml",
+			content: "- a\n- b\n",
+			err:     "document 1: expected an object",
+		},
+		{
+			name:    "unsupported extension",
+			file:    "patient.xml",
+			content: "<Patient/>",
+			err:     `unsupported file extension ".xml", expected one of .json, .ndjson, .yaml, .yml`,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			resources, err := readFhirFile(writeTestFile(t, tc.file, tc.content))
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, resources)
+		})
+	}
+}
+
+func TestNewFhirFileResource(t *testing.T) {
+	tt := []struct {
+		name  string
+		data  map[string]any
+		key   string
+		query string
+		err   string
+	}{
+		{
+			name: "id",
+			data: map[string]any{"resourceType": "Questionnaire", "id": "intake", "url": "https://example.com/intake"},
+			key:  "Questionnaire/intake",
+		},
+		{
+			name:  "canonical url",
+			data:  map[string]any{"resourceType": "ValueSet", "url": "https://example.com/vs"},
+			key:   "https://example.com/vs",
+			query: "url=https%3A%2F%2Fexample.com%2Fvs",
+		},
+		{
+			name:  "versioned canonical url",
+			data:  map[string]any{"resourceType": "ValueSet", "url": "https://example.com/vs", "version": "2.0"},
+			key:   "https://example.com/vs|2.0",
+			query: "url=https%3A%2F%2Fexample.com%2Fvs&version=2.0",
+		},
+		{
+			name: "no key",
+			data: map[string]any{"resourceType": "Patient"},
+			err:  "Patient has neither an id nor a canonical url to key it by",
+		},
+		{
+			name: "no resourceType",
+			data: map[string]any{"id": "1"},
+			err:  "resource has no resourceType",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			resource, err := newFhirFileResource("file.json", tc.data)
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.key, resource.Key)
+			assert.Equal(t, tc.query, resource.Query)
+		})
+	}
+}
+
+func TestFhirResourceHash(t *testing.T) {
+	file, err := fhirResourceHash(map[string]any{
+		"resourceType": "ValueSet",
+		"url":          "https://example.com/vs",
+		"meta":         map[string]any{"profile": []any{"https://example.com/profile"}},
+	})
+	require.NoError(t, err)
+
+	stored := map[string]any{
+		"resourceType": "ValueSet",
+		"id":           "1",
+		"url":          "https://example.com/vs",
+		"meta": map[string]any{
+			"versionId":   "3",
+			"lastUpdated": "2024-01-01T00:00:00Z",
+			"profile":     []any{"https://example.com/profile"},
+		},
+	}
+	hash, err := fhirResourceHash(stored)
+	require.NoError(t, err)
+	assert.Equal(t, file, hash, "server assigned fields must not change the hash")
+	assert.Equal(t, "3", stored["meta"].(map[string]any)["versionId"], "the resource must not be modified")
+
+	stored["version"] = json.Number("1.50")
+	decimal, err := fhirResourceHash(stored)
+	require.NoError(t, err)
+	stored["version"] = json.Number("1.5")
+	hash, err = fhirResourceHash(stored)
+	require.NoError(t, err)
+	assert.Equal(t, decimal, hash, "numbers must hash by value")
+	delete(stored, "version")
+
+	stored["status"] = "active"
+	hash, err = fhirResourceHash(stored)
+	require.NoError(t, err)
+	assert.NotEqual(t, file, hash)
+}
+
+func TestLoadFhirFiles(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "value-sets"), 0o700))
+	files := map[string]string{
+		"intake.json":            `{"resourceType": "Questionnaire", "id": "intake"}`,
+		"value-sets/colors.yaml": "resourceType: ValueSet\nurl: https://example.com/colors\n",
+		"README.md":              "not a resource",
+	}
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600))
+	}
+
+	resources, err := loadFhirFiles(dir)
+	require.NoError(t, err)
+	keys := make(map[string]string)
+	for _, resource := range resources {
+		keys[resource.Key] = resource.File
+	}
+	assert.Equal(t, map[string]string{
+		"Questionnaire/intake":       "intake.json",
+		"https://example.com/colors": filepath.Join("value-sets", "colors.yaml"),
+	}, keys)
+
+	resources, err = loadFhirFiles(filepath.Join(dir, "*.json"))
+	require.NoError(t, err)
+	require.Len(t, resources, 1)
+	assert.Equal(t, "Questionnaire/intake", resources[0].Key)
+
+	_, err = loadFhirFiles(filepath.Join(dir, "*.ndjson"))
+	assert.ErrorContains(t, err, "no files match")
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "copy.ndjson"), []byte(files["intake.json"]), 0o600))
+	_, err = loadFhirFiles(dir)
+	assert.EqualError(t, err, "Questionnaire/intake is defined in both copy.ndjson and intake.json")
+}
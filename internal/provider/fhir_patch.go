@@ -0,0 +1,114 @@
+package provider
+
+import (
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/masslight/terraform-provider-oystehr/internal/client"
+)
+
+// fhirPatch returns a JSON Patch that changes a resource from the prior data
+// to data. Test operations guard the values it replaces or removes, so the
+// patch fails instead of overwriting concurrent changes to them. With managed
+// paths, only those fields are compared. It returns false when the change
+// cannot be expressed as a patch, because the positions of the managed
+// elements of a shared array or the parent object of a new field are unknown.
+func fhirPatch(prior, data map[string]any, paths []managedPath) ([]client.PatchOperation, bool) {
+	var patch []client.PatchOperation
+	if len(paths) == 0 {
+		for _, field := range sortedKeys(prior, data) {
+			if field == "resourceType" || field == "id" {
+				continue
+			}
+			priorValue, inPrior := prior[field]
+			value, inData := data[field]
+			patch = diffPatch(patch, jsonPointer([]string{field}), priorValue, inPrior, value, inData)
+		}
+		return patch, true
+	}
+
+	for _, path := range paths {
+		last := path.last()
+		priorParent := lookupObject(prior, path.parents(), false)
+		priorValue, inPrior := priorParent[last.Name]
+		value, inData := lookupObject(data, path.parents(), false)[last.Name]
+		if inPrior == inData && reflect.DeepEqual(priorValue, value) {
+			continue
+		}
+		if selectElements(path) != nil {
+			return nil, false
+		}
+		// Every stored resource has meta
+		if priorParent == nil && len(path) > 1 && !(len(path) == 2 && path[0].Name == "meta") {
+			return nil, false
+		}
+		patch = diffPatch(patch, jsonPointer(append(path.parents(), last.Name)), priorValue, inPrior, value, inData)
+	}
+	return patch, true
+}
+
+// diffPatch appends the operations changing the value at pointer from prior to
+// value. Objects and arrays of the same length are compared element by element.
+func diffPatch(patch []client.PatchOperation, pointer string, prior any, inPrior bool, value any, inData bool) []client.PatchOperation {
+	switch {
+	case !inPrior && !inData:
+		return patch
+	case !inPrior:
+		return append(patch, client.PatchOperation{Op: "add", Path: pointer, Value: value})
+	case !inData:
+		return append(patch,
+			client.PatchOperation{Op: "test", Path: pointer, Value: prior},
+			client.PatchOperation{Op: "remove", Path: pointer},
+		)
+	}
+
+	priorObject, priorIsObject := prior.(map[string]any)
+	object, isObject := value.(map[string]any)
+	if priorIsObject && isObject {
+		for _, field := range sortedKeys(priorObject, object) {
+			priorValue, inPrior := priorObject[field]
+			value, inData := object[field]
+			patch = diffPatch(patch, pointer+jsonPointer([]string{field}), priorValue, inPrior, value, inData)
+		}
+		return patch
+	}
+	priorArray, priorIsArray := prior.([]any)
+	array, isArray := value.([]any)
+	if priorIsArray && isArray && len(priorArray) == len(array) {
+		for i := range array {
+			patch = diffPatch(patch, pointer+"/"+strconv.Itoa(i), priorArray[i], true, array[i], true)
+		}
+		return patch
+	}
+	if reflect.DeepEqual(prior, value) {
+		return patch
+	}
+	return append(patch,
+		client.PatchOperation{Op: "test", Path: pointer, Value: prior},
+		client.PatchOperation{Op: "replace", Path: pointer, Value: value},
+	)
+}
+
+// jsonPointer returns the RFC 6901 JSON Pointer of a path of field names.
+func jsonPointer(names []string) string {
+	var pointer strings.Builder
+	for _, name := range names {
+		pointer.WriteString("/" + strings.ReplaceAll(strings.ReplaceAll(name, "~", "~0"), "/", "~1"))
+	}
+	return pointer.String()
+}
+
+func sortedKeys(objects ...map[string]any) []string {
+	var keys []string
+	for _, object := range objects {
+		for key := range object {
+			if !slices.Contains(keys, key) {
+				keys = append(keys, key)
+			}
+		}
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,144 @@
+package provider
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/masslight/terraform-provider-oystehr/internal/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFhirPatch(t *testing.T) {
+	prior := map[string]any{
+		"resourceType": "Patient",
+		"id":           "1",
+		"active":       true,
+		"name":         []any{map[string]any{"family": "Smith", "given": []any{"Jane"}}},
+		"extension":    []any{map[string]any{"url": "https://example.com/ext", "valueString": "a"}},
+		"meta":         map[string]any{"tag": []any{map[string]any{"system": "s", "code": "a"}}},
+	}
+
+	tt := []struct {
+		name     string
+		prior    map[string]any
+		fields   []string
+		data     map[string]any
+		expected []client.PatchOperation
+		ok       bool
+	}{
+		{
+			name: "nested change",
+			data: map[string]any{
+				"active":    true,
+				"name":      []any{map[string]any{"family": "Jones", "given": []any{"Jane"}}},
+				"extension": prior["extension"],
+				"meta":      prior["meta"],
+			},
+			expected: []client.PatchOperation{
+				{Op: "test", Path: "/name/0/family", Value: "Smith"},
+				{Op: "replace", Path: "/name/0/family", Value: "Jones"},
+			},
+			ok: true,
+		},
+		{
+			name: "added and removed fields",
+			data: map[string]any{
+				"gender":    "female",
+				"name":      []any{map[string]any{"family": "Smith", "given": []any{"Jane", "Ann"}}},
+				"extension": prior["extension"],
+				"meta":      prior["meta"],
+			},
+			expected: []client.PatchOperation{
+				{Op: "test", Path: "/active", Value: true},
+				{Op: "remove", Path: "/active"},
+				{Op: "add", Path: "/gender", Value: "female"},
+				{Op: "test", Path: "/name/0/given", Value: []any{"Jane"}},
+				{Op: "replace", Path: "/name/0/given", Value: []any{"Jane", "Ann"}},
+			},
+			ok: true,
+		},
+		{
+			name:   "managed field",
+			fields: []string{"name", "active"},
+			data:   map[string]any{"active": false, "name": prior["name"]},
+			expected: []client.PatchOperation{
+				{Op: "test", Path: "/active", Value: true},
+				{Op: "replace", Path: "/active", Value: false},
+			},
+			ok: true,
+		},
+		{
+			name:   "boolean from false to true",
+			prior:  map[string]any{"resourceType": "Patient", "id": "1", "active": false},
+			fields: []string{"active"},
+			data:   map[string]any{"active": true},
+			expected: []client.PatchOperation{
+				{Op: "test", Path: "/active", Value: false},
+				{Op: "replace", Path: "/active", Value: true},
+			},
+			ok: true,
+		},
+		{
+			name:   "new field of meta",
+			fields: []string{"meta.source"},
+			data:   map[string]any{"meta": map[string]any{"source": "x"}},
+			expected: []client.PatchOperation{
+				{Op: "add", Path: "/meta/source", Value: "x"},
+			},
+			ok: true,
+		},
+		{
+			name:   "unchanged keyed array",
+			fields: []string{"extension", "active"},
+			data:   map[string]any{"extension": prior["extension"]},
+			expected: []client.PatchOperation{
+				{Op: "test", Path: "/active", Value: true},
+				{Op: "remove", Path: "/active"},
+			},
+			ok: true,
+		},
+		{
+			name:   "changed keyed array",
+			fields: []string{"extension"},
+			data:   map[string]any{"extension": []any{map[string]any{"url": "https://example.com/ext", "valueString": "b"}}},
+		},
+		{
+			name:   "changed selected elements",
+			fields: []string{"telecom[system=email]"},
+			data:   map[string]any{"telecom": []any{map[string]any{"system": "email", "value": "jane@example.com"}}},
+		},
+		{
+			name:   "new field of an object that may not exist",
+			fields: []string{"contact.name"},
+			data:   map[string]any{"contact": map[string]any{"name": "x"}},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			paths, err := parseManagedPaths(tc.fields)
+			require.NoError(t, err)
+			data := map[string]any{"resourceType": "Patient", "id": "1"}
+			for k, v := range tc.data {
+				data[k] = v
+			}
+			base := prior
+			if tc.prior != nil {
+				base = tc.prior
+			}
+			patch, ok := fhirPatch(base, data, paths)
+			assert.Equal(t, tc.ok, ok)
+			assert.Equal(t, tc.expected, patch)
+			// Falsy values must survive encoding, or the server rejects the patch
+			encoded, err := json.Marshal(patch)
+			require.NoError(t, err)
+			var decoded []map[string]any
+			require.NoError(t, json.Unmarshal(encoded, &decoded))
+			for i, op := range decoded {
+				_, hasValue := op["value"]
+				assert.Equal(t, patch[i].Op != "remove", hasValue, "operation %d", i)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-framework/function"
+)
+
+var _ function.Function = &FhirReferenceFunction{}
+
+// FhirReferenceFunction builds the relative reference to a FHIR resource, so
+// configurations need not assemble "Type/id" strings by hand.
+type FhirReferenceFunction struct{}
+
+func NewFhirReferenceFunction() function.Function {
+	return &FhirReferenceFunction{}
+}
+
+func (f *FhirReferenceFunction) Metadata(_ context.Context, req function.MetadataRequest, resp *function.MetadataResponse) {
+	resp.Name = "fhir_reference"
+}
+
+func (f *FhirReferenceFunction) Definition(_ context.Context, _ function.DefinitionRequest, resp *function.DefinitionResponse) {
+	resp.Definition = function.Definition{
+		Summary:     "Build a FHIR reference",
+		Description: "Returns the relative reference to a FHIR resource, 'Type/id', for the 'reference' field of a FHIR Reference, checking that the type and ID are well formed.",
+		Parameters: []function.Parameter{
+			function.StringParameter{
+				Name:        "type",
+				Description: "The FHIR resource type, e.g. 'Organization'.",
+			},
+			function.StringParameter{
+				Name:        "id",
+				Description: "The ID of the FHIR resource.",
+			},
+		},
+		Return: function.StringReturn{},
+	}
+}
+
+func (f *FhirReferenceFunction) Run(ctx context.Context, req function.RunRequest, resp *function.RunResponse) {
+	var resourceType, id string
+
+	resp.Error = function.ConcatFuncErrors(resp.Error, req.Arguments.Get(ctx, &resourceType, &id))
+	if resp.Error != nil {
+		return
+	}
+
+	if !fhirResourceTypePattern.MatchString(resourceType) {
+		resp.Error = function.NewArgumentFuncError(0, fmt.Sprintf("Expected a FHIR resource type such as 'Organization', got: %q", resourceType))
+		return
+	}
+	if !fhirIDPattern.MatchString(id) {
+		resp.Error = function.NewArgumentFuncError(1, fmt.Sprintf("Expected a FHIR resource ID of 1 to 64 letters, digits, '-' and '.', got: %q", id))
+		return
+	}
+
+	resp.Error = function.ConcatFuncErrors(resp.Error, resp.Result.Set(ctx, resourceType+"/"+id))
+}
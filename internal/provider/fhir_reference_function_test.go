@@ -0,0 +1,46 @@
+package provider
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/knownvalue"
+	"github.com/hashicorp/terraform-plugin-testing/statecheck"
+)
+
+func TestAccFhirReferenceFunction(t *testing.T) {
+	server := newTestAccServer(t)
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: server.ProviderConfig() + `
+output "test" {
+  value = provider::oystehr::fhir_reference("Organization", "abc-123")
+}
+`,
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownOutputValue("test", knownvalue.StringExact("Organization/abc-123")),
+				},
+			},
+			{
+				Config: server.ProviderConfig() + `
+output "test" {
+  value = provider::oystehr::fhir_reference("organization", "abc-123")
+}
+`,
+				ExpectError: regexp.MustCompile(`Expected a FHIR resource type such as\s+'Organization'`),
+			},
+			{
+				Config: server.ProviderConfig() + `
+output "test" {
+  value = provider::oystehr::fhir_reference("Organization", "Organization/abc-123")
+}
+`,
+				ExpectError: regexp.MustCompile(`Expected a FHIR resource ID`),
+			},
+		},
+	})
+}
@@ -0,0 +1,155 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net/url"
+	"regexp"
+	"slices"
+	"sync"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/masslight/terraform-provider-oystehr/internal/client"
+)
+
+var (
+	fhirResourceTypePattern = regexp.MustCompile(`^[A-Z][A-Za-z]*$`)
+	fhirIDPattern           = regexp.MustCompile(`^[A-Za-z0-9\-.]{1,64}$`)
+	// fhirRelativeReference matches "Type/id", optionally followed by
+	// "/_history/versionId"
+	fhirRelativeReference = regexp.MustCompile(`^([A-Z][A-Za-z]*)/([A-Za-z0-9\-.]{1,64})(?:/_history/[A-Za-z0-9\-.]{1,64})?$`)
+	// fhirConditionalReference matches "Type?query", which transactions
+	// resolve to the single resource the search finds
+	fhirConditionalReference = regexp.MustCompile(`^([A-Z][A-Za-z]*)\?(.+)$`)
+	fhirUUIDReference        = regexp.MustCompile(`^urn:uuid:[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	fhirOIDReference         = regexp.MustCompile(`^urn:oid:[0-2](\.(0|[1-9][0-9]*))+$`)
+	fhirContainedRef         = regexp.MustCompile(`^#[A-Za-z0-9\-.]{0,64}$`)
+)
+
+// fhirReference is a Reference.reference value of FHIR data, with the
+// Reference.type beside it, if any.
+type fhirReference struct {
+	Path      path.Path
+	Reference string
+	Type      string
+}
+
+// collectFhirReferences walks a value of data for the known reference fields
+// of objects. Unknown values are skipped, since they are only known after
+// apply.
+func collectFhirReferences(value attr.Value, attributePath path.Path) []fhirReference {
+	if value == nil || value.IsNull() || value.IsUnknown() {
+		return nil
+	}
+
+	var references []fhirReference
+	switch v := value.(type) {
+	case types.Dynamic:
+		return collectFhirReferences(v.UnderlyingValue(), attributePath)
+	case types.Object:
+		attributes := v.Attributes()
+		if reference, ok := attributes["reference"].(types.String); ok && !reference.IsNull() && !reference.IsUnknown() {
+			var referenceType string
+			if t, ok := attributes["type"].(types.String); ok {
+				referenceType = t.ValueString()
+			}
+			references = append(references, fhirReference{
+				Path:      attributePath.AtName("reference"),
+				Reference: reference.ValueString(),
+				Type:      referenceType,
+			})
+		}
+		for _, name := range slices.Sorted(maps.Keys(attributes)) {
+			references = append(references, collectFhirReferences(attributes[name], attributePath.AtName(name))...)
+		}
+	case types.Map:
+		elements := v.Elements()
+		for _, key := range slices.Sorted(maps.Keys(elements)) {
+			references = append(references, collectFhirReferences(elements[key], attributePath.AtMapKey(key))...)
+		}
+	case types.List:
+		for i, elem := range v.Elements() {
+			references = append(references, collectFhirReferences(elem, attributePath.AtListIndex(i))...)
+		}
+	case types.Tuple:
+		for i, elem := range v.Elements() {
+			references = append(references, collectFhirReferences(elem, attributePath.AtListIndex(i))...)
+		}
+	}
+	return references
+}
+
+// checkFhirReference returns why a reference is invalid, or an empty string
+// for a valid one. References may be relative, "Type/id", conditional,
+// "Type?query", absolute URLs, "urn:uuid:" and "urn:oid:" URIs, or references
+// to contained resources.
+func checkFhirReference(reference fhirReference) string {
+	match := fhirRelativeReference.FindStringSubmatch(reference.Reference)
+	if match == nil {
+		match = fhirConditionalReference.FindStringSubmatch(reference.Reference)
+	}
+	if match != nil {
+		if reference.Type != "" && reference.Type != match[1] {
+			return fmt.Sprintf("The reference %q does not match its type %q.", reference.Reference, reference.Type)
+		}
+		return ""
+	}
+	if fhirUUIDReference.MatchString(reference.Reference) || fhirOIDReference.MatchString(reference.Reference) || fhirContainedRef.MatchString(reference.Reference) {
+		return ""
+	}
+	if parsed, err := url.Parse(reference.Reference); err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != "" {
+		return ""
+	}
+	return fmt.Sprintf("Expected a reference of the form 'Type/id' or 'Type?query', an absolute URL, a 'urn:uuid:' or 'urn:oid:' URI or a '#' reference to a contained resource, got: %q. Use provider::oystehr::fhir_reference(type, id) to build references to other resources.", reference.Reference)
+}
+
+// addFhirReferenceDiagnostics reports the invalid references of data on the
+// fields they are in, as errors when strict and as warnings otherwise.
+func addFhirReferenceDiagnostics(diags *diag.Diagnostics, references []fhirReference, strict bool) {
+	for _, reference := range references {
+		problem := checkFhirReference(reference)
+		switch {
+		case problem == "":
+		case strict:
+			diags.AddAttributeError(reference.Path, "Invalid FHIR Reference", problem)
+		default:
+			diags.AddAttributeWarning(reference.Path, "Invalid FHIR Reference", problem)
+		}
+	}
+}
+
+// verifyFhirReferences checks that the targets of the relative references of
+// data exist. The targets are fetched concurrently, so they share a batch
+// bundle.
+func verifyFhirReferences(ctx context.Context, c *client.Client, references []fhirReference) diag.Diagnostics {
+	var diags diag.Diagnostics
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, reference := range references {
+		match := fhirRelativeReference.FindStringSubmatch(reference.Reference)
+		if match == nil {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := c.Fhir.GetResource(ctx, match[1], match[2])
+			if err == nil {
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if client.IsNotFound(err) || client.IsGone(err) {
+				diags.AddAttributeError(reference.Path, "FHIR Reference Not Found", fmt.Sprintf("The target of the reference %q does not exist.", reference.Reference))
+				return
+			}
+			diags.AddAttributeError(reference.Path, "Error Verifying FHIR Reference", err.Error())
+		}()
+	}
+	wg.Wait()
+	return diags
+}
@@ -0,0 +1,110 @@
+package provider
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckFhirReference(t *testing.T) {
+	tt := []struct {
+		name      string
+		reference string
+		refType   string
+		problem   string
+	}{
+		{name: "relative", reference: "Organization/123"},
+		{name: "relative with type", reference: "Organization/123", refType: "Organization"},
+		{name: "version", reference: "Patient/a.b-c/_history/2"},
+		{name: "absolute", reference: "https://example.com/fhir/Patient/1"},
+		{name: "uuid", reference: "urn:uuid:9c3ad5a4-0d0e-4b8e-9a39-5f64c2e0f3a1"},
+		{name: "oid", reference: "urn:oid:1.2.840.113619"},
+		{name: "contained", reference: "#practitioner"},
+		{
+			name:      "mismatched type",
+			reference: "Organization/123",
+			refType:   "Patient",
+			problem:   `The reference "Organization/123" does not match its type "Patient".`,
+		},
+		{name: "lowercase type", reference: "organization/123", problem: "Expected a reference"},
+		{name: "missing id", reference: "Organization/", problem: "Expected a reference"},
+		{name: "invalid id", reference: "Organization/a b", problem: "Expected a reference"},
+		{name: "id only", reference: "123", problem: "Expected a reference"},
+		{name: "conditional", reference: "Patient?identifier=https://example.com/mrn|123", refType: "Patient"},
+		{
+			name:      "conditional with mismatched type",
+			reference: "Patient?identifier=123",
+			refType:   "Organization",
+			problem:   `The reference "Patient?identifier=123" does not match its type "Organization".`,
+		},
+		{name: "conditional without query", reference: "Patient?", problem: "Expected a reference"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			problem := checkFhirReference(fhirReference{Reference: tc.reference, Type: tc.refType})
+			if tc.problem == "" {
+				assert.Empty(t, problem)
+				return
+			}
+			assert.Contains(t, problem, tc.problem)
+		})
+	}
+}
+
+func TestAddFhirReferenceDiagnostics(t *testing.T) {
+	references := []fhirReference{
+		{Path: path.Root("data").AtName("subject").AtName("reference"), Reference: "Patient/1"},
+		{Path: path.Root("data").AtName("managingOrganization").AtName("reference"), Reference: "Organization 1"},
+	}
+
+	var diags diag.Diagnostics
+	addFhirReferenceDiagnostics(&diags, references, false)
+	assert.False(t, diags.HasError())
+	assert.Equal(t, 1, diags.WarningsCount())
+
+	diags = nil
+	addFhirReferenceDiagnostics(&diags, references, true)
+	assert.Equal(t, 1, diags.ErrorsCount())
+	assert.Equal(t, 0, diags.WarningsCount())
+}
+
+func TestCollectFhirReferences(t *testing.T) {
+	reference := func(value types.String, extra map[string]attr.Value) types.Object {
+		attributes := map[string]attr.Value{"reference": value}
+		attributeTypes := map[string]attr.Type{"reference": types.StringType}
+		for k, v := range extra {
+			attributes[k] = v
+			attributeTypes[k] = v.Type(t.Context())
+		}
+		return types.ObjectValueMust(attributeTypes, attributes)
+	}
+	organization := reference(types.StringValue("Organization/1"), map[string]attr.Value{"type": types.StringValue("Organization")})
+	unknown := reference(types.StringUnknown(), nil)
+	partOf := reference(types.StringValue("Location/2"), nil)
+	endpoints := types.TupleValueMust(
+		[]attr.Type{unknown.Type(t.Context()), partOf.Type(t.Context())},
+		[]attr.Value{unknown, partOf},
+	)
+	data := types.DynamicValue(types.ObjectValueMust(
+		map[string]attr.Type{
+			"managingOrganization": organization.Type(t.Context()),
+			"endpoint":             endpoints.Type(t.Context()),
+			"name":                 types.StringType,
+		},
+		map[string]attr.Value{
+			"managingOrganization": organization,
+			"endpoint":             endpoints,
+			"name":                 types.StringValue("Clinic"),
+		},
+	))
+
+	assert.Equal(t, []fhirReference{
+		{Path: path.Root("data").AtName("endpoint").AtListIndex(1).AtName("reference"), Reference: "Location/2"},
+		{Path: path.Root("data").AtName("managingOrganization").AtName("reference"), Reference: "Organization/1", Type: "Organization"},
+	}, collectFhirReferences(data, path.Root("data")))
+}
@@ -2,7 +2,8 @@ package provider
 
 import (
 	"context"
-	"slices"
+	"errors"
+	"fmt"
 	"strings"
 
 	"github.com/hashicorp/terraform-plugin-framework/attr"
@@ -17,6 +18,7 @@ import (
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
 	"github.com/hashicorp/terraform-plugin-framework/types"
 	"github.com/hashicorp/terraform-plugin-framework/types/basetypes"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
 	"github.com/masslight/terraform-provider-oystehr/internal/client"
 )
 
@@ -26,12 +28,19 @@ type FhirResourceIdentityModel struct {
 }
 
 type FhirResourceData struct {
-	ID            types.String  `tfsdk:"id"`
-	Type          types.String  `tfsdk:"type"`
-	Data          types.Dynamic `tfsdk:"data"`
-	Meta          types.Object  `tfsdk:"meta"`
-	RemovalPolicy types.String  `tfsdk:"removal_policy"`
-	ManagedFields types.Set     `tfsdk:"managed_fields"`
+	ID                types.String  `tfsdk:"id"`
+	Type              types.String  `tfsdk:"type"`
+	Data              types.Dynamic `tfsdk:"data"`
+	Meta              types.Object  `tfsdk:"meta"`
+	RemovalPolicy     types.String  `tfsdk:"removal_policy"`
+	ManagedFields     types.Set     `tfsdk:"managed_fields"`
+	IdentityQuery     types.String  `tfsdk:"identity_query"`
+	ConditionalUpdate types.Bool    `tfsdk:"conditional_update"`
+	UpdateStrategy    types.String  `tfsdk:"update_strategy"`
+	OnConflict        types.String  `tfsdk:"on_conflict"`
+	Validate          types.Bool    `tfsdk:"validate"`
+	ValidationProfile types.String  `tfsdk:"validation_profile"`
+	VerifyReferences  types.Bool    `tfsdk:"verify_references"`
 }
 
 func convertFhirResourceToRawResource(ctx context.Context, resourceData FhirResourceData) (map[string]any, diag.Diagnostics) {
@@ -77,73 +86,71 @@ func convertRawResourceToFhirResource(ctx context.Context, rawResource map[strin
 		)}
 	}
 
-	// Extract computed meta fields
-	rawMeta, ok := rawResource["meta"].(map[string]any)
-	if !ok {
-		return FhirResourceData{}, diag.Diagnostics{diag.NewErrorDiagnostic(
-			"meta field is missing or not a map",
-			"Expected a map for the meta field.",
-		)}
+	// Extract computed meta fields, which are null for resources the server
+	// keeps no meta for
+	lastUpdated, versionID := types.StringNull(), types.StringNull()
+	if rawMeta, ok := rawResource["meta"].(map[string]any); ok {
+		if v, ok := rawMeta["lastUpdated"].(string); ok {
+			lastUpdated = types.StringValue(v)
+		}
+		if v, ok := rawMeta["versionId"].(string); ok {
+			versionID = types.StringValue(v)
+		}
+		// Remove computed fields from rawResource
+		delete(rawMeta, "lastUpdated")
+		delete(rawMeta, "versionId")
+		if len(rawMeta) == 0 {
+			delete(rawResource, "meta")
+		}
 	}
 	computedMeta, diags := types.ObjectValue(map[string]attr.Type{
 		"last_updated": types.StringType,
 		"version_id":   types.StringType,
 	}, map[string]attr.Value{
-		"last_updated": types.StringValue(rawMeta["lastUpdated"].(string)),
-		"version_id":   types.StringValue(rawMeta["versionId"].(string)),
+		"last_updated": lastUpdated,
+		"version_id":   versionID,
 	})
 	if diags.HasError() {
 		return FhirResourceData{}, diags
 	}
-	// Remove computed fields from rawResource
-	delete(rawMeta, "lastUpdated")
-	delete(rawMeta, "versionId")
-	if len(rawMeta) == 0 {
-		delete(rawResource, "meta")
-	} else {
-		rawResource["meta"] = rawMeta
-	}
 	mv, diags := mapToTerraformObject(ctx, rawResource)
 	if diags.HasError() {
 		return FhirResourceData{}, diags
 	}
 	return FhirResourceData{
-		ID:            types.StringValue(id),
-		Type:          types.StringValue(resourceType),
-		Data:          types.DynamicValue(mv),
-		Meta:          computedMeta,
-		RemovalPolicy: templ.RemovalPolicy,
-		ManagedFields: templ.ManagedFields,
+		ID:                types.StringValue(id),
+		Type:              types.StringValue(resourceType),
+		Data:              types.DynamicValue(mv),
+		Meta:              computedMeta,
+		RemovalPolicy:     templ.RemovalPolicy,
+		ManagedFields:     templ.ManagedFields,
+		IdentityQuery:     templ.IdentityQuery,
+		ConditionalUpdate: templ.ConditionalUpdate,
+		UpdateStrategy:    templ.UpdateStrategy,
+		OnConflict:        templ.OnConflict,
+		Validate:          templ.Validate,
+		ValidationProfile: templ.ValidationProfile,
+		VerifyReferences:  templ.VerifyReferences,
 	}, nil
 }
 
-func mergeTFObjects(ctx context.Context, base, update types.Object, managedFields []string) (types.Object, diag.Diagnostics) {
-	baseMap := base.Attributes()
-	baseAttributeTypes := base.AttributeTypes(ctx)
-	updateMap := update.Attributes()
-	updateAttributeTypes := update.AttributeTypes(ctx)
-
-	// Start with base map. This is the current state of the object.
-
-	// First iterate through old attrs, removing any dropped managed fields
-	for k := range baseMap {
-		// Field removed in update
-		if _, ok := updateMap[k]; !ok && len(managedFields) == 0 || slices.Contains(managedFields, k) {
-			delete(baseMap, k)
-			delete(baseAttributeTypes, k)
-		}
+// managedPaths parses the managed_fields of a resource. No paths are returned
+// when the whole resource is managed.
+func managedPaths(ctx context.Context, managedFields types.Set) ([]managedPath, diag.Diagnostics) {
+	if managedFields.IsNull() || managedFields.IsUnknown() {
+		return nil, nil
 	}
-
-	// Next, iterate through new attrs, adding or updating any managed fields
-	for k, v := range updateMap {
-		// No managed fields specified, or the field is in the managed fields list
-		if len(managedFields) == 0 || slices.Contains(managedFields, k) {
-			baseMap[k] = v
-			baseAttributeTypes[k] = updateAttributeTypes[k]
-		}
+	var fields []string
+	diags := managedFields.ElementsAs(ctx, &fields, true)
+	if diags.HasError() {
+		return nil, diags
 	}
-
-	return types.ObjectValue(baseAttributeTypes, baseMap)
+	paths, err := parseManagedPaths(fields)
+	if err != nil {
+		diags.AddAttributeError(path.Root("managed_fields"), "Invalid Managed Field", err.Error())
+		return nil, diags
+	}
+	return paths, diags
 }
 
 var _ resource.Resource = &FhirResource{}
@@ -209,11 +216,43 @@ func (r *FhirResource) Schema(_ context.Context, _ resource.SchemaRequest, resp
 				ElementType: types.StringType,
 				Optional:    true,
 				Computed:    true,
-				Description: "A set of fields to be managed by the provider. This is used to restrict updates to only the specified fields. Defaults to an empty set, which means all fields are managed.",
+				Description: "A set of fields to be managed by the provider, so that other systems can manage the rest of the resource. Fields are paths such as 'name' or 'meta.tag', and the last field may select array elements, e.g. 'telecom[system=email]'. The elements of extension and modifierExtension arrays are managed by url, of identifier arrays by system, and of coding, tag and security arrays by system and code, so only the elements set in 'data' are written. Only the managed fields are read back into 'data'. Other fields set in 'data' are written when the resource is created and ignored afterwards. Defaults to an empty set, which means all fields are managed.",
 				Default: setdefault.StaticValue(
 					types.SetValueMust(types.StringType, []attr.Value{}),
 				),
 			},
+			"update_strategy": schema.StringAttribute{
+				Optional:    true,
+				Computed:    true,
+				Description: "How updates are sent. Valid values are 'put', which replaces the resource, and 'patch', which sends a JSON Patch of the changes to 'data', so concurrent changes to other fields are kept. A patch fails if a value it changes was changed concurrently, and updates fall back to 'put' when they cannot be expressed as a patch, e.g. for managed elements of keyed arrays. Defaults to 'put'.",
+				Default:     stringdefault.StaticString("put"),
+			},
+			"on_conflict": schema.StringAttribute{
+				Optional:    true,
+				Computed:    true,
+				Description: "What to do when an update fails because another system changed the resource since it was read. Valid values are 'fail', 'rebase', which retries the update on the current version unless the other system changed one of the managed fields, and 'overwrite', which retries the update on the current version regardless. Defaults to 'fail'.",
+				Default:     stringdefault.StaticString("fail"),
+			},
+			"validate": schema.BoolAttribute{
+				Optional:    true,
+				Description: "Whether to check 'data' with the FHIR $validate operation when changes are planned, so invalid resources fail 'terraform plan' instead of the apply. Issues are reported on the fields of 'data' they concern. With 'managed_fields', the managed fields are validated merged into the current resource.",
+			},
+			"validation_profile": schema.StringAttribute{
+				Optional:    true,
+				Description: "The canonical URL of a profile to validate 'data' against when 'validate' is set.",
+			},
+			"verify_references": schema.BoolAttribute{
+				Optional:    true,
+				Description: "Whether to check that the references in 'data' are well formed and that the targets of their 'Type/id' references exist when changes are planned. Otherwise malformed references are only reported as warnings.",
+			},
+			"identity_query": schema.StringAttribute{
+				Optional:    true,
+				Description: "A FHIR search query that identifies the resource, e.g. 'identifier=https://example.com/mrn|123'. When set, the resource is created conditionally: an existing resource matching the query is adopted and updated to match 'data' instead of a duplicate being created. Once created or adopted, the resource is updated by its ID, so it need not keep matching the query, unless 'conditional_update' is set. The query must match at most one resource.",
+			},
+			"conditional_update": schema.BoolAttribute{
+				Optional:    true,
+				Description: "Whether updates are made with a conditional update on 'identity_query' rather than by ID, as the query stood before the update. Requires 'identity_query'. Defaults to false.",
+			},
 		},
 	}
 }
@@ -260,15 +299,26 @@ func (r *FhirResource) Create(ctx context.Context, req resource.CreateRequest, r
 
 	resourceData, diags := convertFhirResourceToRawResource(ctx, plan)
 	resp.Diagnostics.Append(diags...)
+	paths, diags := managedPaths(ctx, plan.ManagedFields)
+	resp.Diagnostics.Append(diags...)
 	if resp.Diagnostics.HasError() {
 		return
 	}
 
-	createdResource, err := r.client.Fhir.CreateResource(ctx, plan.Type.ValueString(), resourceData)
+	var createdResource map[string]any
+	var err error
+	if plan.IdentityQuery.IsNull() {
+		createdResource, err = r.client.Fhir.CreateResource(ctx, plan.Type.ValueString(), resourceData)
+	} else {
+		createdResource, err = r.createConditionally(ctx, plan, resourceData, paths)
+	}
 	if err != nil {
 		resp.Diagnostics.AddError("Error Creating FHIR Resource", err.Error())
 		return
 	}
+	if len(paths) > 0 {
+		createdResource = withUnmanagedFields(projectManagedFields(createdResource, paths, resourceData), resourceData, paths)
+	}
 
 	resource, diags := convertRawResourceToFhirResource(ctx, createdResource, plan)
 	resp.Diagnostics.Append(diags...)
@@ -283,6 +333,28 @@ func (r *FhirResource) Create(ctx context.Context, req resource.CreateRequest, r
 	resp.Diagnostics.Append(resp.Identity.Set(ctx, identity)...)
 }
 
+// createConditionally creates the resource unless its identity query matches
+// an existing resource, which is adopted and updated to match the plan. With
+// managed paths, only those parts of the adopted resource are updated.
+func (r *FhirResource) createConditionally(ctx context.Context, plan FhirResourceData, resourceData map[string]any, paths []managedPath) (map[string]any, error) {
+	resource, created, err := r.client.Fhir.ConditionalCreateResource(ctx, plan.Type.ValueString(), plan.IdentityQuery.ValueString(), resourceData)
+	if err != nil || created {
+		return resource, err
+	}
+
+	id, _ := resource["id"].(string)
+	meta, _ := resource["meta"].(map[string]any)
+	versionID, _ := meta["versionId"].(string)
+	tflog.Info(ctx, "Adopting existing FHIR resource matching identity_query", map[string]any{
+		"type": plan.Type.ValueString(),
+		"id":   id,
+	})
+	if len(paths) > 0 {
+		resourceData = mergeManagedFields(resource, resourceData, nil, paths)
+	}
+	return r.client.Fhir.UpdateResource(ctx, plan.Type.ValueString(), id, versionID, resourceData)
+}
+
 func (r *FhirResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
 	var state FhirResourceData
 	var identity FhirResourceIdentityModel
@@ -297,7 +369,7 @@ func (r *FhirResource) Read(ctx context.Context, req resource.ReadRequest, resp
 
 	returnedResource, err := r.client.Fhir.GetResource(ctx, state.Type.ValueString(), state.ID.ValueString())
 	if err != nil {
-		if strings.Contains(err.Error(), "unexpected status code: 410") {
+		if client.IsGone(err) || client.IsNotFound(err) {
 			resp.State.RemoveResource(ctx)
 			return
 		}
@@ -305,6 +377,20 @@ func (r *FhirResource) Read(ctx context.Context, req resource.ReadRequest, resp
 		return
 	}
 
+	paths, diags := managedPaths(ctx, state.ManagedFields)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if len(paths) > 0 {
+		stateData, diags := convertFhirResourceToRawResource(ctx, state)
+		resp.Diagnostics.Append(diags...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+		returnedResource = withUnmanagedFields(projectManagedFields(returnedResource, paths, stateData), stateData, paths)
+	}
+
 	resource, diags := convertRawResourceToFhirResource(ctx, returnedResource, state)
 	resp.Diagnostics.Append(diags...)
 	if resp.Diagnostics.HasError() {
@@ -329,25 +415,70 @@ func (r *FhirResource) Update(ctx context.Context, req resource.UpdateRequest, r
 		return
 	}
 
-	resourceData, diags := convertFhirResourceToRawResource(ctx, plan)
+	planData, diags := convertFhirResourceToRawResource(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+	paths, diags := managedPaths(ctx, plan.ManagedFields)
 	resp.Diagnostics.Append(diags...)
 	if resp.Diagnostics.HasError() {
 		return
 	}
 
-	var versionID string
-	versionIDValue, ok := state.Meta.Attributes()["version_id"]
-	if ok && !versionIDValue.IsNull() {
-		versionID = getStringFromValue(versionIDValue)
+	var stateData map[string]any
+	changed := !plan.Data.Equal(state.Data)
+	if changed {
+		stateData, diags = convertFhirResourceToRawResource(ctx, state)
+		resp.Diagnostics.Append(diags...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+		// Changes to unmanaged fields alone are not written
+		if len(paths) > 0 && jsonEqual(projectManagedFields(planData, paths, planData), projectManagedFields(stateData, paths, stateData)) {
+			changed = false
+		}
 	}
 
 	var resource FhirResourceData
-	if !plan.Data.Equal(state.Data) {
-		updatedResource, err := r.client.Fhir.UpdateResource(ctx, state.Type.ValueString(), state.ID.ValueString(), versionID, resourceData)
+	if changed {
+		var patch []client.PatchOperation
+		canPatch := false
+		if plan.UpdateStrategy.ValueString() == "patch" {
+			patch, canPatch = fhirPatch(stateData, planData, paths)
+			if !canPatch {
+				tflog.Info(ctx, "Update cannot be expressed as a JSON Patch, falling back to PUT", map[string]any{
+					"type": state.Type.ValueString(),
+					"id":   state.ID.ValueString(),
+				})
+			}
+		}
+
+		// The query from the state still identifies the resource if the update
+		// changes the fields it searches on
+		var conditionalQuery string
+		if plan.ConditionalUpdate.ValueBool() {
+			conditionalQuery = state.IdentityQuery.ValueString()
+		}
+		var updatedResource map[string]any
+		var err error
+		switch {
+		case canPatch && conditionalQuery != "":
+			updatedResource, err = r.client.Fhir.ConditionalPatchResource(ctx, state.Type.ValueString(), conditionalQuery, patch)
+		case canPatch:
+			updatedResource, err = r.client.Fhir.PatchResource(ctx, state.Type.ValueString(), state.ID.ValueString(), patch)
+		default:
+			updatedResource, err = r.putResource(ctx, state, conditionalQuery, plan.OnConflict.ValueString(), planData, stateData, paths)
+		}
+		var conflictErr *fhirConflictError
+		if errors.As(err, &conflictErr) {
+			resp.Diagnostics.AddError("FHIR Resource Conflict", err.Error())
+			return
+		}
 		if err != nil {
 			resp.Diagnostics.AddError("Error Updating FHIR Resource", err.Error())
 			return
 		}
+		if len(paths) > 0 {
+			updatedResource = withUnmanagedFields(projectManagedFields(updatedResource, paths, planData), planData, paths)
+		}
 
 		convertedResource, diags := convertRawResourceToFhirResource(ctx, updatedResource, plan)
 		resp.Diagnostics.Append(diags...)
@@ -357,18 +488,130 @@ func (r *FhirResource) Update(ctx context.Context, req resource.UpdateRequest, r
 		resource = convertedResource
 	} else {
 		resource = FhirResourceData{
-			ID:            state.ID,
-			Type:          state.Type,
-			Data:          state.Data,
-			Meta:          state.Meta,
-			RemovalPolicy: plan.RemovalPolicy,
-			ManagedFields: plan.ManagedFields,
+			ID:                state.ID,
+			Type:              state.Type,
+			Data:              plan.Data,
+			Meta:              state.Meta,
+			RemovalPolicy:     plan.RemovalPolicy,
+			ManagedFields:     plan.ManagedFields,
+			IdentityQuery:     plan.IdentityQuery,
+			ConditionalUpdate: plan.ConditionalUpdate,
+			UpdateStrategy:    plan.UpdateStrategy,
+			OnConflict:        plan.OnConflict,
+			Validate:          plan.Validate,
+			ValidationProfile: plan.ValidationProfile,
+			VerifyReferences:  plan.VerifyReferences,
 		}
 	}
 
 	resp.Diagnostics.Append(resp.State.Set(ctx, resource)...)
 }
 
+// maxFhirUpdateAttempts bounds the updates made when on_conflict retries an
+// update on the current version of a resource.
+const maxFhirUpdateAttempts = 3
+
+// fhirConflictError is returned when an update fails because another system
+// changed the resource since it was read.
+type fhirConflictError struct {
+	resource   string
+	fields     []string
+	onConflict string
+	attempts   int
+}
+
+func (e *fhirConflictError) Error() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s was changed by another system since it was read", e.resource)
+	if len(e.fields) > 0 {
+		fmt.Fprintf(&sb, ", including the managed fields %s", strings.Join(e.fields, ", "))
+	}
+	switch {
+	case e.onConflict == "fail":
+		sb.WriteString(". Refresh and apply again to review the changes, or set on_conflict to 'rebase' or 'overwrite'.")
+	case e.onConflict == "rebase" && len(e.fields) > 0:
+		sb.WriteString(", so the update cannot be rebased safely. Refresh and apply again to review the changes, or set on_conflict to 'overwrite'.")
+	default:
+		fmt.Fprintf(&sb, ", and kept changing after %d attempts.", e.attempts)
+	}
+	return sb.String()
+}
+
+// putResource replaces the resource with data, by ID or, when conditionalQuery
+// is set, with a conditional update on it. With managed paths, the managed
+// fields are written over the current resource instead, keeping the fields
+// other systems manage. When the resource was changed since it was read, the
+// update is retried on the current version as on_conflict allows.
+func (r *FhirResource) putResource(ctx context.Context, state FhirResourceData, conditionalQuery, onConflict string, data, stateData map[string]any, paths []managedPath) (map[string]any, error) {
+	var versionID string
+	versionIDValue, ok := state.Meta.Attributes()["version_id"]
+	if ok && !versionIDValue.IsNull() {
+		versionID = getStringFromValue(versionIDValue)
+	}
+
+	var currentResource map[string]any
+	if len(paths) > 0 {
+		var err error
+		currentResource, err = r.client.Fhir.GetResource(ctx, state.Type.ValueString(), state.ID.ValueString())
+		if err != nil {
+			return nil, err
+		}
+		// The update is made on the version just fetched, so changes made to
+		// the managed fields since the state was read are caught here rather
+		// than by a failed precondition
+		if conflicts := conflictingFields(currentResource, stateData, paths); len(conflicts) > 0 && onConflict != "overwrite" {
+			return nil, &fhirConflictError{
+				resource:   state.Type.ValueString() + "/" + state.ID.ValueString(),
+				fields:     conflicts,
+				onConflict: onConflict,
+				attempts:   1,
+			}
+		}
+	}
+
+	for attempt := 1; ; attempt++ {
+		resourceData := data
+		if currentResource != nil {
+			meta, _ := currentResource["meta"].(map[string]any)
+			versionID, _ = meta["versionId"].(string)
+			if len(paths) > 0 {
+				resourceData = mergeManagedFields(currentResource, data, stateData, paths)
+			}
+		}
+
+		var updatedResource map[string]any
+		var err error
+		if conditionalQuery != "" {
+			updatedResource, err = r.client.Fhir.ConditionalUpdateResource(ctx, state.Type.ValueString(), conditionalQuery, versionID, resourceData)
+		} else {
+			updatedResource, err = r.client.Fhir.UpdateResource(ctx, state.Type.ValueString(), state.ID.ValueString(), versionID, resourceData)
+		}
+		if !client.IsPreconditionFailed(err) {
+			return updatedResource, err
+		}
+
+		currentResource, err = r.client.Fhir.GetResource(ctx, state.Type.ValueString(), state.ID.ValueString())
+		if err != nil {
+			return nil, err
+		}
+		conflicts := conflictingFields(currentResource, stateData, paths)
+		if attempt == maxFhirUpdateAttempts || onConflict == "fail" || (onConflict == "rebase" && len(conflicts) > 0) {
+			return nil, &fhirConflictError{
+				resource:   state.Type.ValueString() + "/" + state.ID.ValueString(),
+				fields:     conflicts,
+				onConflict: onConflict,
+				attempts:   attempt,
+			}
+		}
+		tflog.Info(ctx, "FHIR resource was changed since it was read, retrying the update on the current version", map[string]any{
+			"type":        state.Type.ValueString(),
+			"id":          state.ID.ValueString(),
+			"on_conflict": onConflict,
+			"conflicts":   conflicts,
+		})
+	}
+}
+
 func (r *FhirResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
 	var state FhirResourceData
 
@@ -399,14 +642,50 @@ func (r *FhirResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRe
 		return
 	}
 
+	if strategy := plan.UpdateStrategy.ValueString(); strategy != "put" && strategy != "patch" && !plan.UpdateStrategy.IsNull() && !plan.UpdateStrategy.IsUnknown() {
+		resp.Diagnostics.AddAttributeError(path.Root("update_strategy"), "Invalid Update Strategy", "Expected 'put' or 'patch', got: "+strategy)
+		return
+	}
+
+	if onConflict := plan.OnConflict.ValueString(); onConflict != "fail" && onConflict != "rebase" && onConflict != "overwrite" && !plan.OnConflict.IsNull() && !plan.OnConflict.IsUnknown() {
+		resp.Diagnostics.AddAttributeError(path.Root("on_conflict"), "Invalid Conflict Handling", "Expected 'fail', 'rebase' or 'overwrite', got: "+onConflict)
+		return
+	}
+
+	if plan.ConditionalUpdate.ValueBool() && plan.IdentityQuery.IsNull() {
+		resp.Diagnostics.AddAttributeError(path.Root("conditional_update"), "Invalid Conditional Update", "conditional_update requires identity_query to be set")
+		return
+	}
+
 	if plan.Data.IsUnknown() {
 		// If the data is unknown, we cannot modify it, so we return early.
 		resp.Plan = req.Plan
 		return
 	}
 
+	paths, diags := managedPaths(ctx, plan.ManagedFields)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	var planData map[string]any
+	if len(paths) > 0 {
+		// Selected array elements outside managed_fields would never be
+		// written or read back
+		planData, diags = convertFhirResourceToRawResource(ctx, plan)
+		resp.Diagnostics.Append(diags...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+		if err := checkManagedData(planData, paths); err != nil {
+			resp.Diagnostics.AddAttributeError(path.Root("data"), "Invalid FHIR Resource Data", err.Error())
+			return
+		}
+	}
+
 	if req.State.Raw.IsNull() {
 		// If the state is null, there's nothing more to check against, so we return early.
+		resp.Diagnostics.Append(r.checkPlannedData(ctx, plan, nil, paths)...)
 		resp.Diagnostics.Append(resp.Plan.Set(ctx, &plan)...)
 		return
 	}
@@ -417,27 +696,88 @@ func (r *FhirResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRe
 		return
 	}
 
-	// Handle `managed_fields` and merging of `data`
-	if !plan.Data.Equal(state.Data) {
-		var managedFields []string
-		resp.Diagnostics.Append(plan.ManagedFields.ElementsAs(ctx, &managedFields, true)...)
-		if resp.Diagnostics.HasError() {
-			return
+	// With managed_fields, the state holds only the managed parts of the
+	// resource and the unmanaged fields as configured, so the data compares
+	// directly against the config
+	if plan.Data.Equal(state.Data) {
+		plan.Meta = state.Meta
+	} else {
+		if len(paths) > 0 {
+			stateData, diags := convertFhirResourceToRawResource(ctx, state)
+			resp.Diagnostics.Append(diags...)
+			if resp.Diagnostics.HasError() {
+				return
+			}
+			for _, field := range unmanagedFields(planData, paths) {
+				if !jsonEqual(planData[field], stateData[field]) {
+					resp.Diagnostics.AddAttributeWarning(path.Root("data").AtName(field), "Unmanaged FHIR Resource Field",
+						fmt.Sprintf("data sets %q, which is not covered by managed_fields. It was only written when the resource was created, so this change is not written.", field))
+				}
+			}
 		}
-		mergedData, diags := mergeTFObjects(ctx, state.Data.UnderlyingValue().(types.Object), plan.Data.UnderlyingValue().(types.Object), managedFields)
-		resp.Diagnostics.Append(diags...)
-		if resp.Diagnostics.HasError() {
-			return
+		resp.Diagnostics.Append(r.checkPlannedData(ctx, plan, &state, paths)...)
+	}
+	resp.Diagnostics.Append(resp.Plan.Set(ctx, &plan)...)
+}
+
+// checkPlannedData checks the references of planned data, which only warns
+// of malformed references unless verify_references is set, in which case they
+// are errors and the targets must exist. The data is validated when validate
+// is set.
+func (r *FhirResource) checkPlannedData(ctx context.Context, plan FhirResourceData, state *FhirResourceData, paths []managedPath) diag.Diagnostics {
+	var diags diag.Diagnostics
+	references := collectFhirReferences(plan.Data, path.Root("data"))
+	addFhirReferenceDiagnostics(&diags, references, plan.VerifyReferences.ValueBool())
+	if diags.HasError() {
+		return diags
+	}
+	if plan.VerifyReferences.ValueBool() && r.client != nil {
+		diags.Append(verifyFhirReferences(ctx, r.client, references)...)
+	}
+	if plan.Validate.ValueBool() {
+		diags.Append(r.validate(ctx, plan, state, paths)...)
+	}
+	return diags
+}
+
+// validate checks the planned resource with the $validate operation. With
+// managed paths, the planned fields are merged into the current resource
+// first, since on their own they are only part of a resource.
+func (r *FhirResource) validate(ctx context.Context, plan FhirResourceData, state *FhirResourceData, paths []managedPath) diag.Diagnostics {
+	if r.client == nil || plan.Type.IsUnknown() {
+		return nil
+	}
+	if value, err := plan.Data.ToTerraformValue(ctx); err != nil || !value.IsFullyKnown() {
+		// Values known only after apply would fail validation spuriously
+		tflog.Debug(ctx, "Skipping FHIR validation of data with unknown values")
+		return nil
+	}
+
+	data, diags := convertFhirResourceToRawResource(ctx, plan)
+	if diags.HasError() {
+		return diags
+	}
+	if len(paths) > 0 && state != nil {
+		currentResource, err := r.client.Fhir.GetResource(ctx, state.Type.ValueString(), state.ID.ValueString())
+		if err != nil {
+			diags.AddError("Error Validating FHIR Resource", err.Error())
+			return diags
 		}
-		dataChanged := !mergedData.Equal(state.Data)
-		plan.Data = types.DynamicValue(mergedData)
-		if !dataChanged {
-			plan.Meta = state.Meta
+		stateData, stateDiags := convertFhirResourceToRawResource(ctx, *state)
+		diags.Append(stateDiags...)
+		if diags.HasError() {
+			return diags
 		}
-	} else {
-		plan.Meta = state.Meta
+		data = mergeManagedFields(currentResource, data, stateData, paths)
 	}
-	resp.Diagnostics.Append(resp.Plan.Set(ctx, &plan)...)
+
+	issues, err := r.client.Fhir.ValidateResource(ctx, plan.Type.ValueString(), data, plan.ValidationProfile.ValueString())
+	if err != nil {
+		diags.AddError("Error Validating FHIR Resource", err.Error())
+		return diags
+	}
+	addFhirValidationDiagnostics(&diags, issues)
+	return diags
 }
 
 func (r *FhirResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
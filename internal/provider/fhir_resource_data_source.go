@@ -0,0 +1,201 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/masslight/terraform-provider-oystehr/internal/client"
+)
+
+type FhirResourceDataSourceModel struct {
+	ID         types.String  `tfsdk:"id"`
+	Type       types.String  `tfsdk:"type"`
+	URL        types.String  `tfsdk:"url"`
+	Version    types.String  `tfsdk:"version"`
+	Identifier types.String  `tfsdk:"identifier"`
+	Data       types.Dynamic `tfsdk:"data"`
+	Meta       types.Object  `tfsdk:"meta"`
+}
+
+var _ datasource.DataSource = &FhirResourceDataSource{}
+var _ datasource.DataSourceWithConfigure = &FhirResourceDataSource{}
+var _ datasource.DataSourceWithValidateConfig = &FhirResourceDataSource{}
+
+type FhirResourceDataSource struct {
+	client *client.Client
+}
+
+func NewFhirResourceDataSource() datasource.DataSource {
+	return &FhirResourceDataSource{}
+}
+
+func (d *FhirResourceDataSource) Metadata(ctx context.Context, _ datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = "oystehr_fhir_resource"
+}
+
+func (d *FhirResourceDataSource) Schema(ctx context.Context, _ datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Attributes: map[string]schema.Attribute{
+			"id": schema.StringAttribute{
+				Optional:    true,
+				Computed:    true,
+				Description: "The ID of the FHIR resource. Exactly one of `id`, `url` or `identifier` must be set.",
+			},
+			"type": schema.StringAttribute{
+				Required:    true,
+				Description: "The FHIR resource type (e.g., Patient, Observation).",
+			},
+			"url": schema.StringAttribute{
+				Optional:    true,
+				Description: "The canonical URL of a definitional resource such as a Questionnaire, ValueSet, CodeSystem or StructureDefinition.",
+			},
+			"version": schema.StringAttribute{
+				Optional:    true,
+				Description: "The business version of the resource with the canonical `url`.",
+			},
+			"identifier": schema.StringAttribute{
+				Optional:    true,
+				Description: "A business identifier of the resource, as `system|value`.",
+			},
+			"data": schema.DynamicAttribute{
+				Computed:    true,
+				Description: "The FHIR resource data as a terraform object.",
+			},
+			"meta": schema.SingleNestedAttribute{
+				Computed:    true,
+				Description: "Metadata about the FHIR resource.",
+				Attributes: map[string]schema.Attribute{
+					"last_updated": schema.StringAttribute{
+						Computed:    true,
+						Description: "The last updated timestamp of the FHIR resource.",
+					},
+					"version_id": schema.StringAttribute{
+						Computed:    true,
+						Description: "The version ID of the FHIR resource.",
+					},
+				},
+			},
+		},
+	}
+}
+
+func (d *FhirResourceDataSource) Configure(ctx context.Context, req datasource.ConfigureRequest, resp *datasource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+
+	client, ok := req.ProviderData.(*client.Client)
+	if !ok {
+		resp.Diagnostics.AddError(
+			"Unexpected Provider Data Type",
+			"Expected *client.Client but got a different type.",
+		)
+		return
+	}
+
+	d.client = client
+}
+
+func (d *FhirResourceDataSource) ValidateConfig(ctx context.Context, req datasource.ValidateConfigRequest, resp *datasource.ValidateConfigResponse) {
+	var data FhirResourceDataSourceModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	lookups := 0
+	for _, value := range []types.String{data.ID, data.URL, data.Identifier} {
+		if value.IsUnknown() {
+			// Cannot be checked until the value is known
+			return
+		}
+		if !value.IsNull() {
+			lookups++
+		}
+	}
+	if lookups != 1 {
+		resp.Diagnostics.AddError(
+			"Invalid FHIR Resource Lookup",
+			"Exactly one of id, url or identifier must be set.",
+		)
+	}
+	if !data.Version.IsNull() && data.URL.IsNull() {
+		resp.Diagnostics.AddAttributeError(path.Root("version"), "Invalid FHIR Resource Lookup", "version can only be set together with url.")
+	}
+}
+
+func (d *FhirResourceDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	var data FhirResourceDataSourceModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	var rawResource map[string]any
+	if !data.ID.IsNull() {
+		returnedResource, err := d.client.Fhir.GetResource(ctx, data.Type.ValueString(), data.ID.ValueString())
+		if err != nil {
+			resp.Diagnostics.AddError(
+				"Error Reading FHIR Resource",
+				"Could not read FHIR resource: "+err.Error(),
+			)
+			return
+		}
+		rawResource = returnedResource
+	} else {
+		// Two results are enough to tell that the lookup is ambiguous
+		params := url.Values{"_count": {"2"}}
+		description := "identifier " + data.Identifier.ValueString()
+		if !data.URL.IsNull() {
+			params.Set("url", data.URL.ValueString())
+			description = "url " + data.URL.ValueString()
+			if !data.Version.IsNull() {
+				params.Set("version", data.Version.ValueString())
+				description += " and version " + data.Version.ValueString()
+			}
+		} else {
+			params.Set("identifier", data.Identifier.ValueString())
+		}
+		result, err := d.client.Fhir.SearchResources(ctx, data.Type.ValueString(), params, 1)
+		if err != nil {
+			resp.Diagnostics.AddError(
+				"Error Reading FHIR Resource",
+				"Could not search FHIR resources: "+err.Error(),
+			)
+			return
+		}
+		switch {
+		case len(result.Matches) == 0:
+			resp.Diagnostics.AddError(
+				"FHIR Resource Not Found",
+				fmt.Sprintf("No %s with %s was found.", data.Type.ValueString(), description),
+			)
+			return
+		// A next link alone does not make the lookup ambiguous, since servers
+		// may link a further page that turns out to be empty
+		case len(result.Matches) > 1:
+			resp.Diagnostics.AddError(
+				"Multiple FHIR Resources Found",
+				fmt.Sprintf("More than one %s with %s was found. Use a lookup that matches a single resource.", data.Type.ValueString(), description),
+			)
+			return
+		}
+		rawResource = result.Matches[0]
+	}
+
+	resource, diags := convertRawResourceToFhirResource(ctx, rawResource, FhirResourceData{})
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	data.ID = resource.ID
+	data.Data = resource.Data
+	data.Meta = resource.Meta
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
@@ -0,0 +1,172 @@
+package provider
+
+import (
+	"net/http"
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+
+	"github.com/masslight/terraform-provider-oystehr/internal/testing/fakeoystehr"
+)
+
+func TestAccFhirResourceDataSource(t *testing.T) {
+	server := newTestAccServer(t)
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: server.ProviderConfig() + `
+data "oystehr_fhir_resource" "invalid" {
+  type       = "Organization"
+  id         = "1"
+  identifier = "https://example.com/clinics|main"
+}
+`,
+				ExpectError: regexp.MustCompile("Exactly one of id, url or identifier must be set"),
+			},
+			{
+				Config: server.ProviderConfig() + testAccFhirResourceDataSourceConfig + `
+data "oystehr_fhir_resource" "ambiguous" {
+  type       = "Questionnaire"
+  url        = "https://example.com/Questionnaire/intake"
+  depends_on = [oystehr_fhir_resource.questionnaire]
+}
+`,
+				ExpectError: regexp.MustCompile("Multiple FHIR Resources Found"),
+			},
+			{
+				Config: server.ProviderConfig() + testAccFhirResourceDataSourceConfig + `
+data "oystehr_fhir_resource" "missing" {
+  type       = "Organization"
+  identifier = "https://example.com/clinics|missing"
+  depends_on = [oystehr_fhir_resource.organization]
+}
+`,
+				ExpectError: regexp.MustCompile("FHIR Resource Not Found"),
+			},
+			{
+				Config: server.ProviderConfig() + testAccFhirResourceDataSourceConfig + `
+data "oystehr_fhir_resource" "by_id" {
+  type = "Organization"
+  id   = oystehr_fhir_resource.organization.id
+}
+
+data "oystehr_fhir_resource" "by_url" {
+  type       = "Questionnaire"
+  url        = "https://example.com/Questionnaire/intake"
+  version    = "2"
+  depends_on = [oystehr_fhir_resource.questionnaire]
+}
+
+data "oystehr_fhir_resource" "by_identifier" {
+  type       = "Organization"
+  identifier = "https://example.com/clinics|main"
+  depends_on = [oystehr_fhir_resource.organization]
+}
+`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttrPair("data.oystehr_fhir_resource.by_id", "data.name", "oystehr_fhir_resource.organization", "data.name"),
+					resource.TestCheckResourceAttrPair("data.oystehr_fhir_resource.by_id", "meta.version_id", "oystehr_fhir_resource.organization", "meta.version_id"),
+					resource.TestCheckResourceAttrPair("data.oystehr_fhir_resource.by_url", "id", "oystehr_fhir_resource.questionnaire.1", "id"),
+					resource.TestCheckResourceAttr("data.oystehr_fhir_resource.by_url", "data.version", "2"),
+					resource.TestCheckResourceAttrPair("data.oystehr_fhir_resource.by_identifier", "id", "oystehr_fhir_resource.organization", "id"),
+					resource.TestCheckResourceAttrSet("data.oystehr_fhir_resource.by_identifier", "meta.last_updated"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccFhirResourceDataSourceNextLink(t *testing.T) {
+	server := newTestAccServer(t)
+	// A single match with a link to a further page, as some servers return
+	// when the page is full
+	server.InjectFault(fakeoystehr.Fault{
+		Method: http.MethodGet,
+		Path:   "/fhir/Organization",
+		Status: http.StatusOK,
+		Header: http.Header{"Content-Type": {"application/fhir+json"}},
+		Body: `{"resourceType": "Bundle", "type": "searchset",
+  "link": [{"relation": "next", "url": "` + server.URL + `/fhir/Organization?_offset=1"}],
+  "entry": [{"resource": {"resourceType": "Organization", "id": "1", "meta": {"versionId": "1", "lastUpdated": "2024-01-01T00:00:00Z"}, "name": "Clinic"}, "search": {"mode": "match"}}]}`,
+	})
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: server.ProviderConfig() + `
+data "oystehr_fhir_resource" "by_identifier" {
+  type       = "Organization"
+  identifier = "https://example.com/clinics|main"
+}
+`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("data.oystehr_fhir_resource.by_identifier", "id", "1"),
+					resource.TestCheckResourceAttr("data.oystehr_fhir_resource.by_identifier", "data.name", "Clinic"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccFhirResourceDataSourceWithoutMeta(t *testing.T) {
+	server := newTestAccServer(t)
+	server.InjectFault(fakeoystehr.Fault{
+		Method: http.MethodGet,
+		Path:   "/fhir/Organization",
+		Status: http.StatusOK,
+		Header: http.Header{"Content-Type": {"application/fhir+json"}},
+		Body: `{"resourceType": "Bundle", "type": "searchset",
+  "entry": [{"resource": {"resourceType": "Organization", "id": "1", "name": "Clinic"}, "search": {"mode": "match"}}]}`,
+	})
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: server.ProviderConfig() + `
+data "oystehr_fhir_resource" "by_identifier" {
+  type       = "Organization"
+  identifier = "https://example.com/clinics|main"
+}
+`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("data.oystehr_fhir_resource.by_identifier", "id", "1"),
+					resource.TestCheckResourceAttr("data.oystehr_fhir_resource.by_identifier", "data.name", "Clinic"),
+					resource.TestCheckNoResourceAttr("data.oystehr_fhir_resource.by_identifier", "meta.version_id"),
+					resource.TestCheckNoResourceAttr("data.oystehr_fhir_resource.by_identifier", "meta.last_updated"),
+				),
+			},
+		},
+	})
+}
+
+const testAccFhirResourceDataSourceConfig = `
+resource "oystehr_fhir_resource" "organization" {
+  type = "Organization"
+  data = {
+    resourceType = "Organization"
+    name         = "Clinic"
+    identifier = [
+      {
+        system = "https://example.com/clinics"
+        value  = "main"
+      },
+    ]
+  }
+}
+
+resource "oystehr_fhir_resource" "questionnaire" {
+  count = 2
+  type  = "Questionnaire"
+  data = {
+    resourceType = "Questionnaire"
+    url          = "https://example.com/Questionnaire/intake"
+    version      = "${count.index + 1}"
+    status       = "active"
+  }
+}
+`
@@ -0,0 +1,867 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/plancheck"
+	"github.com/hashicorp/terraform-plugin-testing/terraform"
+
+	"github.com/masslight/terraform-provider-oystehr/internal/testing/fakeoystehr"
+)
+
+func TestAccFhirResource(t *testing.T) {
+	server := newTestAccServer(t)
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		CheckDestroy: func(s *terraform.State) error {
+			for _, rs := range s.RootModule().Resources {
+				if rs.Type != "oystehr_fhir_resource" {
+					continue
+				}
+				if _, ok := server.FhirResource(rs.Primary.Attributes["type"], rs.Primary.ID); ok {
+					return fmt.Errorf("oystehr_fhir_resource %s still exists", rs.Primary.ID)
+				}
+			}
+			return nil
+		},
+		Steps: []resource.TestStep{
+			{
+				Config: server.ProviderConfig() + testAccFhirResourceConfig("Smith"),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttrSet("oystehr_fhir_resource.test", "id"),
+					resource.TestCheckResourceAttr("oystehr_fhir_resource.test", "meta.version_id", "1"),
+					resource.TestCheckResourceAttr("oystehr_fhir_resource.test", "data.name.0.family", "Smith"),
+				),
+			},
+			{
+				ResourceName:      "oystehr_fhir_resource.test",
+				ImportState:       true,
+				ImportStateIdFunc: testAccFhirResourceImportID("oystehr_fhir_resource.test"),
+				ImportStateVerify: true,
+				// Settings that only exist in the configuration cannot be imported
+				ImportStateVerifyIgnore: []string{"removal_policy", "managed_fields", "update_strategy", "on_conflict"},
+			},
+			{
+				Config: server.ProviderConfig() + testAccFhirResourceConfig("Jones"),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("oystehr_fhir_resource.test", "meta.version_id", "2"),
+					resource.TestCheckResourceAttr("oystehr_fhir_resource.test", "data.name.0.family", "Jones"),
+				),
+			},
+		},
+	})
+}
+
+func testAccFhirResourceConfig(family string) string {
+	return fmt.Sprintf(`
+resource "oystehr_fhir_resource" "test" {
+  type = "Patient"
+  data = {
+    resourceType = "Patient"
+    active       = true
+    name = [
+      {
+        family = %q
+        given  = ["Jane"]
+      },
+    ]
+  }
+}
+`, family)
+}
+
+// testAccFhirResourceImportID returns the "<type>/<id>" import ID of a FHIR
+// resource.
+func testAccFhirResourceImportID(name string) resource.ImportStateIdFunc {
+	return func(s *terraform.State) (string, error) {
+		rs, ok := s.RootModule().Resources[name]
+		if !ok {
+			return "", fmt.Errorf("resource %s not found", name)
+		}
+		return rs.Primary.Attributes["type"] + "/" + rs.Primary.ID, nil
+	}
+}
+
+func TestAccFhirResourceIdentityQuery(t *testing.T) {
+	server := newTestAccServer(t)
+	var existingID string
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				// A resource left behind by a lost state is adopted
+				PreConfig: func() {
+					existingID = server.AddFhirResource(map[string]any{
+						"resourceType": "Patient",
+						"active":       false,
+						"identifier":   []any{map[string]any{"system": "https://example.com/mrn", "value": "123"}},
+					})
+				},
+				Config: server.ProviderConfig() + testAccFhirResourceIdentityQueryConfig("123", "Smith"),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttrWith("oystehr_fhir_resource.test", "id", func(id string) error {
+						if id != existingID {
+							return fmt.Errorf("expected the existing resource %s to be adopted, got %s", existingID, id)
+						}
+						return nil
+					}),
+					resource.TestCheckResourceAttr("oystehr_fhir_resource.test", "meta.version_id", "2"),
+					resource.TestCheckResourceAttr("oystehr_fhir_resource.test", "data.active", "true"),
+				),
+			},
+			{
+				// Updates are made by ID, so the identifier itself can change
+				Config: server.ProviderConfig() + testAccFhirResourceIdentityQueryConfig("456", "Jones"),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttrWith("oystehr_fhir_resource.test", "id", func(id string) error {
+						if id != existingID {
+							return fmt.Errorf("expected %s to be updated, got %s", existingID, id)
+						}
+						return nil
+					}),
+					resource.TestCheckResourceAttr("oystehr_fhir_resource.test", "meta.version_id", "3"),
+					resource.TestCheckResourceAttr("oystehr_fhir_resource.test", "data.identifier.0.value", "456"),
+				),
+			},
+			{
+				// A resource that no longer matches its query is still updated
+				PreConfig: func() {
+					patient, _ := server.FhirResource("Patient", existingID)
+					patient["identifier"] = []any{map[string]any{"system": "https://example.com/mrn", "value": "789"}}
+					server.UpdateFhirResource(patient)
+				},
+				Config: server.ProviderConfig() + testAccFhirResourceIdentityQueryConfig("456", "Brown"),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttrWith("oystehr_fhir_resource.test", "id", func(id string) error {
+						if id != existingID {
+							return fmt.Errorf("expected %s to be updated, got %s", existingID, id)
+						}
+						return nil
+					}),
+					resource.TestCheckResourceAttr("oystehr_fhir_resource.test", "meta.version_id", "5"),
+					resource.TestCheckResourceAttr("oystehr_fhir_resource.test", "data.name.0.family", "Brown"),
+				),
+			},
+			{
+				ResourceName:            "oystehr_fhir_resource.test",
+				ImportState:             true,
+				ImportStateIdFunc:       testAccFhirResourceImportID("oystehr_fhir_resource.test"),
+				ImportStateVerify:       true,
+				ImportStateVerifyIgnore: []string{"removal_policy", "managed_fields", "identity_query", "update_strategy", "on_conflict"},
+			},
+		},
+	})
+}
+
+func testAccFhirResourceIdentityQueryConfig(mrn, family string) string {
+	return fmt.Sprintf(`
+resource "oystehr_fhir_resource" "test" {
+  type           = "Patient"
+  identity_query = "identifier=https://example.com/mrn|%[1]s"
+  data = {
+    resourceType = "Patient"
+    active       = true
+    identifier = [
+      {
+        system = "https://example.com/mrn"
+        value  = %[1]q
+      },
+    ]
+    name = [
+      {
+        family = %[2]q
+      },
+    ]
+  }
+}
+`, mrn, family)
+}
+
+func TestAccFhirResourceConditionalUpdate(t *testing.T) {
+	server := newTestAccServer(t)
+	query := "Patient?identifier=https://example.com/mrn|123"
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config:      server.ProviderConfig() + strings.Replace(testAccFhirResourceConfig("Smith"), `type = "Patient"`, "type = \"Patient\"\n  conditional_update = true", 1),
+				ExpectError: regexp.MustCompile(`conditional_update\s+requires\s+identity_query`),
+			},
+			{
+				Config: server.ProviderConfig() + testAccFhirResourceConditionalUpdateConfig("put", "Smith"),
+			},
+			{
+				Config: server.ProviderConfig() + testAccFhirResourceConditionalUpdateConfig("put", "Jones"),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("oystehr_fhir_resource.test", "data.name.0.family", "Jones"),
+					testAccCheckFhirBundleRequest(server, "PUT", "/"+query),
+				),
+			},
+			{
+				Config: server.ProviderConfig() + testAccFhirResourceConditionalUpdateConfig("patch", "Brown"),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("oystehr_fhir_resource.test", "data.name.0.family", "Brown"),
+					testAccCheckFhirBundleRequest(server, "PATCH", "/"+query),
+				),
+			},
+		},
+	})
+}
+
+func testAccFhirResourceConditionalUpdateConfig(strategy, family string) string {
+	return strings.Replace(testAccFhirResourceIdentityQueryConfig("123", family), "  identity_query", fmt.Sprintf("  conditional_update = true\n  update_strategy    = %q\n  identity_query", strategy), 1)
+}
+
+// testAccCheckFhirBundleRequest checks that a FHIR bundle sent to the fake had
+// an entry with the given request method and URL.
+func testAccCheckFhirBundleRequest(server *fakeoystehr.Server, method, url string) resource.TestCheckFunc {
+	return func(*terraform.State) error {
+		for _, request := range server.Requests() {
+			if request.Path != "/fhir" {
+				continue
+			}
+			var bundle struct {
+				Entry []struct {
+					Request struct {
+						Method string `json:"method"`
+						URL    string `json:"url"`
+					} `json:"request"`
+				} `json:"entry"`
+			}
+			if err := json.Unmarshal(request.Body, &bundle); err != nil {
+				continue
+			}
+			for _, entry := range bundle.Entry {
+				if entry.Request.Method == method && entry.Request.URL == url {
+					return nil
+				}
+			}
+		}
+		return fmt.Errorf("no %s %s was sent", method, url)
+	}
+}
+
+func TestAccFhirResourceManagedFields(t *testing.T) {
+	server := newTestAccServer(t)
+	var id string
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: server.ProviderConfig() + `
+resource "oystehr_fhir_resource" "test" {
+  type           = "Patient"
+  managed_fields = ["telecom[system=email]"]
+  data = {
+    resourceType = "Patient"
+    telecom      = [{ system = "phone", value = "555-0100" }]
+  }
+}
+`,
+				ExpectError: regexp.MustCompile(`no\s+selector\s+in\s+managed_fields\s+matches`),
+			},
+			{
+				Config: server.ProviderConfig() + `
+resource "oystehr_fhir_resource" "test" {
+  type           = "Patient"
+  managed_fields = ["name["]
+  data = {
+    resourceType = "Patient"
+  }
+}
+`,
+				ExpectError: regexp.MustCompile(`unterminated selector`),
+			},
+			{
+				Config: server.ProviderConfig() + testAccFhirResourceManagedFieldsConfig("Smith"),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttrWith("oystehr_fhir_resource.test", "id", func(value string) error {
+						id = value
+						return nil
+					}),
+					resource.TestCheckResourceAttr("oystehr_fhir_resource.test", "meta.version_id", "1"),
+				),
+			},
+			{
+				// Fields, extensions, tags and telecoms added by other systems
+				// are left out of the state
+				PreConfig: func() {
+					patient, _ := server.FhirResource("Patient", id)
+					patient["active"] = true
+					patient["extension"] = append(patient["extension"].([]any), map[string]any{"url": "https://example.com/other", "valueString": "theirs"})
+					meta := patient["meta"].(map[string]any)
+					meta["tag"] = append(meta["tag"].([]any), map[string]any{"system": "https://example.com/tags", "code": "other"})
+					patient["telecom"] = append(patient["telecom"].([]any), map[string]any{"system": "phone", "value": "555-0100"})
+					server.UpdateFhirResource(patient)
+				},
+				Config: server.ProviderConfig() + testAccFhirResourceManagedFieldsConfig("Smith"),
+				ConfigPlanChecks: resource.ConfigPlanChecks{
+					PreApply: []plancheck.PlanCheck{plancheck.ExpectEmptyPlan()},
+				},
+			},
+			{
+				Config: server.ProviderConfig() + testAccFhirResourceManagedFieldsConfig("Jones"),
+				ConfigPlanChecks: resource.ConfigPlanChecks{
+					PreApply: []plancheck.PlanCheck{plancheck.ExpectResourceAction("oystehr_fhir_resource.test", plancheck.ResourceActionUpdate)},
+				},
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("oystehr_fhir_resource.test", "meta.version_id", "3"),
+					resource.TestCheckResourceAttr("oystehr_fhir_resource.test", "data.name.0.family", "Jones"),
+					resource.TestCheckNoResourceAttr("oystehr_fhir_resource.test", "data.active"),
+					resource.TestCheckResourceAttr("oystehr_fhir_resource.test", "data.extension.#", "1"),
+					func(*terraform.State) error {
+						patient, _ := server.FhirResource("Patient", id)
+						if patient["active"] != true {
+							return fmt.Errorf("expected active to be kept, got %v", patient["active"])
+						}
+						if n := len(patient["extension"].([]any)); n != 2 {
+							return fmt.Errorf("expected 2 extensions, got %d", n)
+						}
+						if n := len(patient["meta"].(map[string]any)["tag"].([]any)); n != 2 {
+							return fmt.Errorf("expected 2 tags, got %d", n)
+						}
+						if n := len(patient["telecom"].([]any)); n != 2 {
+							return fmt.Errorf("expected 2 telecoms, got %d", n)
+						}
+						return nil
+					},
+				),
+			},
+		},
+	})
+}
+
+func testAccFhirResourceManagedFieldsConfig(family string) string {
+	return fmt.Sprintf(`
+resource "oystehr_fhir_resource" "test" {
+  type           = "Patient"
+  managed_fields = ["name", "extension", "meta.tag", "telecom[system=email]"]
+  data = {
+    resourceType = "Patient"
+    name = [
+      {
+        family = %q
+      },
+    ]
+    extension = [
+      {
+        url         = "https://example.com/ours"
+        valueString = "ours"
+      },
+    ]
+    meta = {
+      tag = [
+        {
+          system = "https://example.com/tags"
+          code   = "ours"
+        },
+      ]
+    }
+    telecom = [
+      {
+        system = "email"
+        value  = "jane@example.com"
+      },
+    ]
+  }
+}
+`, family)
+}
+
+func TestAccFhirResourceManagedFieldsSeed(t *testing.T) {
+	server := newTestAccServer(t)
+	var id string
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				// Fields outside managed_fields are written on create
+				Config: server.ProviderConfig() + testAccFhirResourceManagedFieldsSeedConfig(true, "Doe"),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttrWith("oystehr_fhir_resource.test", "id", func(value string) error {
+						id = value
+						return nil
+					}),
+					resource.TestCheckResourceAttr("oystehr_fhir_resource.test", "data.active", "true"),
+					func(*terraform.State) error {
+						patient, _ := server.FhirResource("Patient", id)
+						if patient["active"] != true {
+							return fmt.Errorf("expected active to be written on create, got %v", patient["active"])
+						}
+						return nil
+					},
+				),
+			},
+			{
+				// and ignored afterwards
+				PreConfig: func() {
+					patient, _ := server.FhirResource("Patient", id)
+					patient["active"] = false
+					server.UpdateFhirResource(patient)
+				},
+				Config: server.ProviderConfig() + testAccFhirResourceManagedFieldsSeedConfig(true, "Doe"),
+				ConfigPlanChecks: resource.ConfigPlanChecks{
+					PreApply: []plancheck.PlanCheck{plancheck.ExpectEmptyPlan()},
+				},
+			},
+			{
+				Config: server.ProviderConfig() + testAccFhirResourceManagedFieldsSeedConfig(true, "Roe"),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("oystehr_fhir_resource.test", "meta.version_id", "3"),
+					resource.TestCheckResourceAttr("oystehr_fhir_resource.test", "data.name.0.family", "Roe"),
+					func(*terraform.State) error {
+						patient, _ := server.FhirResource("Patient", id)
+						if patient["active"] != false {
+							return fmt.Errorf("expected active to be kept, got %v", patient["active"])
+						}
+						return nil
+					},
+				),
+			},
+			{
+				// Changing only an unmanaged field writes nothing
+				Config: server.ProviderConfig() + testAccFhirResourceManagedFieldsSeedConfig(false, "Roe"),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("oystehr_fhir_resource.test", "meta.version_id", "3"),
+					resource.TestCheckResourceAttr("oystehr_fhir_resource.test", "data.active", "false"),
+				),
+			},
+		},
+	})
+}
+
+func testAccFhirResourceManagedFieldsSeedConfig(active bool, family string) string {
+	return fmt.Sprintf(`
+resource "oystehr_fhir_resource" "test" {
+  type           = "Patient"
+  managed_fields = ["maritalStatus", "link", "name"]
+  data = {
+    resourceType = "Patient"
+    active       = %t
+    name = [
+      {
+        use    = "official"
+        family = %q
+        given  = ["John"]
+      },
+    ]
+  }
+}
+`, active, family)
+}
+
+func TestAccFhirResourcePatch(t *testing.T) {
+	server := newTestAccServer(t)
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config:      server.ProviderConfig() + testAccFhirResourcePatchConfig("merge", "Smith"),
+				ExpectError: regexp.MustCompile(`Expected 'put' or 'patch', got: merge`),
+			},
+			{
+				Config: server.ProviderConfig() + testAccFhirResourcePatchConfig("patch", "Smith"),
+				Check:  resource.TestCheckResourceAttr("oystehr_fhir_resource.test", "meta.version_id", "1"),
+			},
+			{
+				Config: server.ProviderConfig() + testAccFhirResourcePatchConfig("patch", "Jones"),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("oystehr_fhir_resource.test", "meta.version_id", "2"),
+					resource.TestCheckResourceAttr("oystehr_fhir_resource.test", "data.name.0.family", "Jones"),
+					resource.TestCheckResourceAttr("oystehr_fhir_resource.test", "data.name.0.given.0", "Jane"),
+					func(*terraform.State) error {
+						for _, r := range server.Requests() {
+							if strings.Contains(string(r.Body), `"method":"PUT"`) {
+								return fmt.Errorf("expected the update to be a patch, got %s", r.Body)
+							}
+						}
+						return nil
+					},
+				),
+			},
+		},
+	})
+}
+
+func testAccFhirResourcePatchConfig(strategy, family string) string {
+	return fmt.Sprintf(`
+resource "oystehr_fhir_resource" "test" {
+  type            = "Patient"
+  update_strategy = %q
+  data = {
+    resourceType = "Patient"
+    active       = true
+    name = [
+      {
+        family = %q
+        given  = ["Jane"]
+      },
+    ]
+  }
+}
+`, strategy, family)
+}
+
+func TestAccFhirResourceConflict(t *testing.T) {
+	server := newTestAccServer(t)
+	var id string
+	changeName := func() {
+		server.ChangeBeforeFhirUpdate("Patient", id, 1, func(patient map[string]any) {
+			patient["name"] = []any{map[string]any{"family": "Other"}}
+		})
+	}
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config:      server.ProviderConfig() + testAccFhirResourceConflictConfig("merge", "Smith"),
+				ExpectError: regexp.MustCompile(`Expected 'fail', 'rebase' or 'overwrite', got: merge`),
+			},
+			{
+				Config: server.ProviderConfig() + testAccFhirResourceConflictConfig("fail", "Smith"),
+				Check: resource.TestCheckResourceAttrWith("oystehr_fhir_resource.test", "id", func(value string) error {
+					id = value
+					return nil
+				}),
+			},
+			{
+				PreConfig:   changeName,
+				Config:      server.ProviderConfig() + testAccFhirResourceConflictConfig("fail", "Jones"),
+				ExpectError: regexp.MustCompile(`including\s+the\s+managed\s+fields\s+name`),
+			},
+			{
+				// Changes to fields other systems manage are rebased onto
+				PreConfig: func() {
+					server.ChangeBeforeFhirUpdate("Patient", id, 1, func(patient map[string]any) {
+						patient["active"] = true
+					})
+				},
+				Config: server.ProviderConfig() + testAccFhirResourceConflictConfig("rebase", "Jones"),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("oystehr_fhir_resource.test", "data.name.0.family", "Jones"),
+					func(*terraform.State) error {
+						patient, _ := server.FhirResource("Patient", id)
+						if patient["active"] != true {
+							return fmt.Errorf("expected the concurrent change to be kept, got %v", patient)
+						}
+						return nil
+					},
+				),
+			},
+			{
+				PreConfig:   changeName,
+				Config:      server.ProviderConfig() + testAccFhirResourceConflictConfig("rebase", "Brown"),
+				ExpectError: regexp.MustCompile(`cannot be\s+rebased\s+safely`),
+			},
+			{
+				PreConfig: changeName,
+				Config:    server.ProviderConfig() + testAccFhirResourceConflictConfig("overwrite", "Brown"),
+				Check:     resource.TestCheckResourceAttr("oystehr_fhir_resource.test", "data.name.0.family", "Brown"),
+			},
+			{
+				// Changes made between plan and apply are conflicts too
+				Config: server.ProviderConfig() + testAccFhirResourceConflictConfig("fail", "Green"),
+				ConfigPlanChecks: resource.ConfigPlanChecks{
+					PreApply: []plancheck.PlanCheck{testAccChangeFhirResource(func() {
+						patient, _ := server.FhirResource("Patient", id)
+						patient["name"] = []any{map[string]any{"family": "Other"}}
+						server.UpdateFhirResource(patient)
+					})},
+				},
+				ExpectError: regexp.MustCompile(`including\s+the\s+managed\s+fields\s+name`),
+			},
+			{
+				Config: server.ProviderConfig() + testAccFhirResourceConflictConfig("rebase", "Green"),
+				ConfigPlanChecks: resource.ConfigPlanChecks{
+					PreApply: []plancheck.PlanCheck{testAccChangeFhirResource(func() {
+						patient, _ := server.FhirResource("Patient", id)
+						patient["active"] = false
+						server.UpdateFhirResource(patient)
+					})},
+				},
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("oystehr_fhir_resource.test", "data.name.0.family", "Green"),
+					func(*terraform.State) error {
+						patient, _ := server.FhirResource("Patient", id)
+						if patient["active"] != false {
+							return fmt.Errorf("expected the concurrent change to be kept, got %v", patient)
+						}
+						return nil
+					},
+				),
+			},
+		},
+	})
+}
+
+// testAccChangeFhirResource is a plan check that runs change, so that a
+// stored resource changes between plan and apply.
+type testAccChangeFhirResource func()
+
+func (c testAccChangeFhirResource) CheckPlan(context.Context, plancheck.CheckPlanRequest, *plancheck.CheckPlanResponse) {
+	c()
+}
+
+func testAccFhirResourceConflictConfig(onConflict, family string) string {
+	return fmt.Sprintf(`
+resource "oystehr_fhir_resource" "test" {
+  type           = "Patient"
+  on_conflict    = %q
+  managed_fields = ["name"]
+  data = {
+    resourceType = "Patient"
+    name = [
+      {
+        family = %q
+      },
+    ]
+  }
+}
+`, onConflict, family)
+}
+
+func TestAccFhirResourceValidate(t *testing.T) {
+	server := newTestAccServer(t)
+	validItem := `{ linkId = "1", type = "string" }`
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config:      server.ProviderConfig() + testAccFhirResourceValidateConfig(`{ type = "string" }`, ""),
+				ExpectError: regexp.MustCompile(`Questionnaire.item\[0\]:\s+Questionnaire.item.linkId:\s+minimum`),
+			},
+			{
+				Config:      server.ProviderConfig() + testAccFhirResourceValidateConfig(validItem, "https://example.com/unknown"),
+				ExpectError: regexp.MustCompile(`Profile reference 'https://example.com/unknown'`),
+			},
+			{
+				Config: server.ProviderConfig() + testAccFhirResourceValidateConfig(validItem, ""),
+				Check:  resource.TestCheckResourceAttr("oystehr_fhir_resource.test", "data.item.0.linkId", "1"),
+			},
+			{
+				Config:      server.ProviderConfig() + testAccFhirResourceValidateConfig(`{ linkId = "1" }`, ""),
+				ExpectError: regexp.MustCompile(`Questionnaire.item.type:\s+minimum\s+required`),
+			},
+			{
+				Config: server.ProviderConfig() + testAccFhirResourceValidateConfig(validItem, ""),
+			},
+		},
+	})
+}
+
+func testAccFhirResourceValidateConfig(item, profile string) string {
+	profileAttribute := ""
+	if profile != "" {
+		profileAttribute = fmt.Sprintf("validation_profile = %q", profile)
+	}
+	return fmt.Sprintf(`
+resource "oystehr_fhir_resource" "test" {
+  type     = "Questionnaire"
+  validate = true
+  %s
+  data = {
+    resourceType = "Questionnaire"
+    status       = "active"
+    item         = [%s]
+  }
+}
+`, profileAttribute, item)
+}
+
+func TestAccFhirResourceReferences(t *testing.T) {
+	server := newTestAccServer(t)
+	reference := `provider::oystehr::fhir_reference("Organization", oystehr_fhir_resource.organization.id)`
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config:      server.ProviderConfig() + testAccFhirResourceReferencesConfig(true, `{ reference = "Organization 1" }`),
+				ExpectError: regexp.MustCompile(`Invalid FHIR Reference`),
+			},
+			{
+				Config:      server.ProviderConfig() + testAccFhirResourceReferencesConfig(true, `{ reference = "Organization/1", type = "Patient" }`),
+				ExpectError: regexp.MustCompile(`does not match its type "Patient"`),
+			},
+			{
+				Config:      server.ProviderConfig() + testAccFhirResourceReferencesConfig(true, `{ reference = "Organization/missing" }`),
+				ExpectError: regexp.MustCompile(`The target of the reference "Organization/missing" does\s+not\s+exist`),
+			},
+			{
+				Config: server.ProviderConfig() + testAccFhirResourceReferencesConfig(true, fmt.Sprintf(`{ reference = %s }`, reference)),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttrWith("oystehr_fhir_resource.location", "data.managingOrganization.reference", func(value string) error {
+						if !strings.HasPrefix(value, "Organization/") {
+							return fmt.Errorf("expected a reference to an Organization, got %s", value)
+						}
+						return nil
+					}),
+				),
+			},
+			{
+				// Without verify_references, malformed references are only warned of
+				Config: server.ProviderConfig() + testAccFhirResourceReferencesConfig(false, `{ reference = "Organization 1" }`),
+				Check:  resource.TestCheckResourceAttr("oystehr_fhir_resource.location", "data.managingOrganization.reference", "Organization 1"),
+			},
+		},
+	})
+}
+
+func testAccFhirResourceReferencesConfig(verifyReferences bool, managingOrganization string) string {
+	return fmt.Sprintf(`
+resource "oystehr_fhir_resource" "organization" {
+  type = "Organization"
+  data = {
+    resourceType = "Organization"
+    name         = "Clinic"
+  }
+}
+
+resource "oystehr_fhir_resource" "location" {
+  type              = "Location"
+  verify_references = %t
+  data = {
+    resourceType         = "Location"
+    name                 = "Main Street"
+    managingOrganization = %s
+  }
+}
+`, verifyReferences, managingOrganization)
+}
+
+func TestAccFhirResourceNumbers(t *testing.T) {
+	server := newTestAccServer(t)
+	var id string
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: server.ProviderConfig() + testAccFhirResourceNumbersConfig,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttrWith("oystehr_fhir_resource.test", "id", func(value string) error {
+						id = value
+						return nil
+					}),
+					resource.TestCheckResourceAttr("oystehr_fhir_resource.test", "data.valueQuantity.value", "98.6"),
+					resource.TestCheckResourceAttr("oystehr_fhir_resource.test", "data.component.0.valueQuantity.value", "12345678901234567890.123"),
+					func(*terraform.State) error {
+						observation, _ := server.FhirResource("Observation", id)
+						value := observation["component"].([]any)[0].(map[string]any)["valueQuantity"].(map[string]any)["value"]
+						if value != json.Number("12345678901234567890.123") {
+							return fmt.Errorf("expected the exact value to be stored, got %v", value)
+						}
+						return nil
+					},
+				),
+			},
+			{
+				// The same numbers written with trailing zeros are no change
+				PreConfig: func() {
+					observation, _ := server.FhirResource("Observation", id)
+					observation["valueQuantity"].(map[string]any)["value"] = json.Number("98.60")
+					server.UpdateFhirResource(observation)
+				},
+				Config: server.ProviderConfig() + testAccFhirResourceNumbersConfig,
+				ConfigPlanChecks: resource.ConfigPlanChecks{
+					PreApply: []plancheck.PlanCheck{plancheck.ExpectEmptyPlan()},
+				},
+			},
+		},
+	})
+}
+
+const testAccFhirResourceNumbersConfig = `
+resource "oystehr_fhir_resource" "test" {
+  type = "Observation"
+  data = {
+    resourceType = "Observation"
+    status       = "final"
+    code         = { text = "Body temperature" }
+    valueQuantity = {
+      value = 98.6
+      unit  = "[degF]"
+    }
+    component = [
+      {
+        code          = { text = "Large" }
+        valueQuantity = { value = 12345678901234567890.123 }
+      },
+    ]
+  }
+}
+`
+
+func TestAccFhirResourceArrays(t *testing.T) {
+	server := newTestAccServer(t)
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: server.ProviderConfig() + testAccFhirResourceArraysConfig(""),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("oystehr_fhir_resource.test", "data.telecom.#", "3"),
+					resource.TestCheckResourceAttr("oystehr_fhir_resource.test", "data.telecom.1.rank", "1"),
+					resource.TestCheckNoResourceAttr("oystehr_fhir_resource.test", "data.name.0._given.0"),
+					resource.TestCheckResourceAttr("oystehr_fhir_resource.test", "data.name.0._given.1.extension.0.valueCode", "MID"),
+				),
+			},
+			{
+				// An element gaining a field leaves the others unchanged
+				Config: server.ProviderConfig() + testAccFhirResourceArraysConfig(`, rank = 2`),
+				ConfigPlanChecks: resource.ConfigPlanChecks{
+					PreApply: []plancheck.PlanCheck{plancheck.ExpectResourceAction("oystehr_fhir_resource.test", plancheck.ResourceActionUpdate)},
+				},
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("oystehr_fhir_resource.test", "meta.version_id", "2"),
+					resource.TestCheckResourceAttr("oystehr_fhir_resource.test", "data.telecom.2.rank", "2"),
+					resource.TestCheckNoResourceAttr("oystehr_fhir_resource.test", "data.telecom.0.rank"),
+				),
+			},
+		},
+	})
+}
+
+// testAccFhirResourceArraysConfig configures a Patient whose arrays hold
+// objects with differing fields and nulls. extra is added to the last telecom.
+func testAccFhirResourceArraysConfig(extra string) string {
+	return fmt.Sprintf(`
+resource "oystehr_fhir_resource" "test" {
+  type = "Patient"
+  data = {
+    resourceType = "Patient"
+    name = [
+      {
+        family = "Chalmers"
+        given  = ["Peter", "James"]
+        _given = [
+          null,
+          { extension = [{ url = "http://hl7.org/fhir/StructureDefinition/iso21090-EN-qualifier", valueCode = "MID" }] },
+        ]
+      },
+      { use = "usual", given = ["Jim"] },
+    ]
+    telecom = [
+      { use = "home" },
+      { system = "phone", value = "(03) 5555 6473", use = "work", rank = 1 },
+      { system = "phone", value = "(03) 5555 8834", use = "old", period = { end = "2014" }%s },
+    ]
+  }
+}
+`, extra)
+}
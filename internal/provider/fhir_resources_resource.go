@@ -0,0 +1,455 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringdefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/masslight/terraform-provider-oystehr/internal/client"
+)
+
+type FhirResourcesData struct {
+	ID            types.String `tfsdk:"id"`
+	Source        types.String `tfsdk:"source"`
+	Resources     types.Map    `tfsdk:"resources"`
+	RemovalPolicy types.String `tfsdk:"removal_policy"`
+}
+
+type FhirResourcesEntryData struct {
+	File      types.String `tfsdk:"file"`
+	Type      types.String `tfsdk:"type"`
+	ID        types.String `tfsdk:"id"`
+	VersionID types.String `tfsdk:"version_id"`
+	Hash      types.String `tfsdk:"hash"`
+}
+
+var (
+	fhirResourcesEntryAttributesType = map[string]attr.Type{
+		"file":       types.StringType,
+		"type":       types.StringType,
+		"id":         types.StringType,
+		"version_id": types.StringType,
+		"hash":       types.StringType,
+	}
+	fhirResourcesEntryType = types.ObjectType{
+		AttrTypes: fhirResourcesEntryAttributesType,
+	}
+)
+
+var _ resource.Resource = &FhirResourcesResource{}
+var _ resource.ResourceWithConfigure = &FhirResourcesResource{}
+var _ resource.ResourceWithModifyPlan = &FhirResourcesResource{}
+
+// FhirResourcesResource manages the FHIR resources of a set of files. Only the
+// hash of each resource's content is kept in the state, so large resources do
+// not bloat it, and the plan lists the resources that change.
+type FhirResourcesResource struct {
+	client *client.Client
+}
+
+func NewFhirResourcesResource() resource.Resource {
+	return &FhirResourcesResource{}
+}
+
+func (r *FhirResourcesResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = "oystehr_fhir_resources"
+}
+
+func (r *FhirResourcesResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Attributes: map[string]schema.Attribute{
+			"id": schema.StringAttribute{
+				Computed:    true,
+				Description: "The ID of the set of resources, generated by the provider.",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"source": schema.StringAttribute{
+				Required:    true,
+				Description: "A directory of FHIR resource files, which is read recursively, or a glob pattern of files, e.g. 'fhir/questionnaires/*.json'. A '.json' file holds a resource, an '.ndjson' file a resource per line, and a '.yaml' or '.yml' file a resource per document.",
+			},
+			"resources": schema.MapNestedAttribute{
+				Computed:    true,
+				Description: "The resources of the files, keyed by 'resourceType/id', or by canonical URL for resources without an ID. The canonical URL is followed by '|' and the version when the resource has one. Resources with an ID are created with it, and resources without one are created or adopted by a conditional update on their canonical URL.",
+				NestedObject: schema.NestedAttributeObject{
+					Attributes: map[string]schema.Attribute{
+						"file": schema.StringAttribute{
+							Computed:    true,
+							Description: "The file the resource is read from.",
+						},
+						"type": schema.StringAttribute{
+							Computed:    true,
+							Description: "The FHIR resource type.",
+						},
+						"id": schema.StringAttribute{
+							Computed:    true,
+							Description: "The ID of the FHIR resource.",
+						},
+						"version_id": schema.StringAttribute{
+							Computed:    true,
+							Description: "The version ID of the FHIR resource.",
+						},
+						"hash": schema.StringAttribute{
+							Computed:    true,
+							Description: "The SHA-256 hash of the resource's content, leaving out its id, meta.versionId and meta.lastUpdated. A change made outside of Terraform changes the hash, so the file's content is applied again.",
+						},
+					},
+				},
+			},
+			"removal_policy": schema.StringAttribute{
+				Optional:    true,
+				Computed:    true,
+				Description: "The removal policy for the FHIR resources. Valid values are 'delete' and 'retain'. Defaults to 'delete'.",
+				Default:     stringdefault.StaticString("delete"),
+			},
+		},
+	}
+}
+
+func (r *FhirResourcesResource) Configure(_ context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+
+	client, ok := req.ProviderData.(*client.Client)
+	if !ok {
+		resp.Diagnostics.AddError(
+			"Unexpected Provider Data Type",
+			"Expected *sdk.Client but got a different type.",
+		)
+		return
+	}
+
+	r.client = client
+}
+
+func (r *FhirResourcesResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	var plan FhirResourcesData
+
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	entries, diags := r.apply(ctx, plan, nil, "Error Creating FHIR Resources")
+	resp.Diagnostics.Append(diags...)
+	if len(entries) == 0 && resp.Diagnostics.HasError() {
+		return
+	}
+
+	// Resources created before an error are kept in the state
+	plan.ID = types.StringValue(uuid.NewString())
+	plan.Resources, diags = types.MapValueFrom(ctx, fhirResourcesEntryType, entries)
+	resp.Diagnostics.Append(diags...)
+	resp.Diagnostics.Append(resp.State.Set(ctx, plan)...)
+}
+
+func (r *FhirResourcesResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	var state FhirResourcesData
+
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	var entries map[string]FhirResourcesEntryData
+	resp.Diagnostics.Append(state.Resources.ElementsAs(ctx, &entries, false)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// Fetch the resources concurrently, so they share a batch bundle
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	refreshed := make(map[string]FhirResourcesEntryData, len(entries))
+	for key, entry := range entries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fetched, err := r.client.Fhir.GetResource(ctx, entry.Type.ValueString(), entry.ID.ValueString())
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				// Resources deleted outside of Terraform are dropped from the
+				// state, so they are recreated
+				if !client.IsGone(err) && !client.IsNotFound(err) {
+					resp.Diagnostics.AddError("Error Reading FHIR Resources", fmt.Sprintf("%s: %s", key, err))
+				}
+				return
+			}
+			meta, _ := fetched["meta"].(map[string]any)
+			if versionID, _ := meta["versionId"].(string); versionID != entry.VersionID.ValueString() {
+				hash, err := fhirResourceHash(fetched)
+				if err != nil {
+					resp.Diagnostics.AddError("Error Reading FHIR Resources", fmt.Sprintf("%s: %s", key, err))
+					return
+				}
+				entry.VersionID = types.StringValue(versionID)
+				entry.Hash = types.StringValue(hash)
+			}
+			refreshed[key] = entry
+		}()
+	}
+	wg.Wait()
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	var diags diag.Diagnostics
+	state.Resources, diags = types.MapValueFrom(ctx, fhirResourcesEntryType, refreshed)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, state)...)
+}
+
+func (r *FhirResourcesResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	var plan FhirResourcesData
+	var state FhirResourcesData
+
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	var prior map[string]FhirResourcesEntryData
+	resp.Diagnostics.Append(state.Resources.ElementsAs(ctx, &prior, false)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// Changes applied before an error are kept in the state
+	entries, diags := r.apply(ctx, plan, prior, "Error Updating FHIR Resources")
+	resp.Diagnostics.Append(diags...)
+	plan.ID = state.ID
+	plan.Resources, diags = types.MapValueFrom(ctx, fhirResourcesEntryType, entries)
+	resp.Diagnostics.Append(diags...)
+	resp.Diagnostics.Append(resp.State.Set(ctx, plan)...)
+}
+
+func (r *FhirResourcesResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	var state FhirResourcesData
+
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if state.RemovalPolicy.ValueString() != "delete" {
+		return
+	}
+
+	var entries map[string]FhirResourcesEntryData
+	resp.Diagnostics.Append(state.Resources.ElementsAs(ctx, &entries, false)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for key, entry := range entries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := r.client.Fhir.DeleteResource(ctx, entry.Type.ValueString(), entry.ID.ValueString())
+			if err != nil && !client.IsGone(err) && !client.IsNotFound(err) {
+				mu.Lock()
+				defer mu.Unlock()
+				resp.Diagnostics.AddError("Error Deleting FHIR Resources", fmt.Sprintf("%s: %s", key, err))
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func (r *FhirResourcesResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	if req.Plan.Raw.IsNull() {
+		return
+	}
+
+	var plan FhirResourcesData
+	var state FhirResourcesData
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if !req.State.Raw.IsNull() {
+		resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	}
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if plan.Source.IsUnknown() {
+		plan.Resources = types.MapUnknown(fhirResourcesEntryType)
+		resp.Diagnostics.Append(resp.Plan.Set(ctx, &plan)...)
+		return
+	}
+
+	loaded, err := loadFhirFiles(plan.Source.ValueString())
+	if err != nil {
+		resp.Diagnostics.AddAttributeError(path.Root("source"), "Invalid FHIR Resource Files", err.Error())
+		return
+	}
+	var prior map[string]FhirResourcesEntryData
+	if !state.Resources.IsNull() && !state.Resources.IsUnknown() {
+		resp.Diagnostics.Append(state.Resources.ElementsAs(ctx, &prior, false)...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+	}
+
+	// Resources whose content is unchanged keep their entry, so only the
+	// resources that are written show in the plan
+	planned := make(map[string]FhirResourcesEntryData, len(loaded))
+	for _, resource := range loaded {
+		entry, exists := prior[resource.Key]
+		exists = exists && entry.Type.ValueString() == resource.Type
+		if exists && entry.Hash.ValueString() == resource.Hash {
+			entry.File = types.StringValue(resource.File)
+			planned[resource.Key] = entry
+			continue
+		}
+		id := types.StringUnknown()
+		if resource.ID != "" {
+			id = types.StringValue(resource.ID)
+		} else if exists {
+			id = entry.ID
+		}
+		planned[resource.Key] = FhirResourcesEntryData{
+			File:      types.StringValue(resource.File),
+			Type:      types.StringValue(resource.Type),
+			ID:        id,
+			VersionID: types.StringUnknown(),
+			Hash:      types.StringValue(resource.Hash),
+		}
+	}
+
+	var diags diag.Diagnostics
+	plan.Resources, diags = types.MapValueFrom(ctx, fhirResourcesEntryType, planned)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	resp.Diagnostics.Append(resp.Plan.Set(ctx, &plan)...)
+}
+
+// apply writes the resources of the files whose content differs from their
+// prior entry and deletes the resources of prior entries that are no longer
+// planned. The requests are sent concurrently, so they share batch bundles. It
+// returns the entries of the resources that exist afterwards, including those
+// of the requests that succeeded when others failed.
+func (r *FhirResourcesResource) apply(ctx context.Context, plan FhirResourcesData, prior map[string]FhirResourcesEntryData, summary string) (map[string]FhirResourcesEntryData, diag.Diagnostics) {
+	var diags diag.Diagnostics
+	entries := make(map[string]FhirResourcesEntryData, len(prior))
+	for key, entry := range prior {
+		entries[key] = entry
+	}
+
+	var planned map[string]FhirResourcesEntryData
+	diags.Append(plan.Resources.ElementsAs(ctx, &planned, false)...)
+	if diags.HasError() {
+		return entries, diags
+	}
+	loaded, err := loadFhirFiles(plan.Source.ValueString())
+	if err != nil {
+		diags.AddAttributeError(path.Root("source"), summary, err.Error())
+		return entries, diags
+	}
+
+	var writes []fhirFileResource
+	for _, resource := range loaded {
+		planEntry, ok := planned[resource.Key]
+		if !ok || planEntry.Hash.ValueString() != resource.Hash {
+			diags.AddError(summary, fmt.Sprintf("%s in %s changed after the plan was made, plan again to apply it", resource.Key, resource.File))
+			continue
+		}
+		entry, exists := prior[resource.Key]
+		if exists && entry.Type.ValueString() == resource.Type && entry.Hash.ValueString() == resource.Hash {
+			entry.File = planEntry.File
+			entries[resource.Key] = entry
+			continue
+		}
+		writes = append(writes, resource)
+	}
+	if diags.HasError() {
+		return entries, diags
+	}
+
+	// Resources no longer in the files, or whose type changed, are removed
+	var deletes []string
+	for key, entry := range prior {
+		if planEntry, ok := planned[key]; ok && planEntry.Type.Equal(entry.Type) {
+			continue
+		}
+		delete(entries, key)
+		if plan.RemovalPolicy.ValueString() == "delete" {
+			deletes = append(deletes, key)
+		}
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, key := range deletes {
+		entry := prior[key]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := r.client.Fhir.DeleteResource(ctx, entry.Type.ValueString(), entry.ID.ValueString())
+			if err != nil && !client.IsGone(err) && !client.IsNotFound(err) {
+				mu.Lock()
+				defer mu.Unlock()
+				if _, replaced := entries[key]; !replaced {
+					entries[key] = entry
+				}
+				diags.AddError(summary, fmt.Sprintf("%s: %s", key, err))
+			}
+		}()
+	}
+	for _, resource := range writes {
+		entry, exists := prior[resource.Key]
+		exists = exists && entry.Type.ValueString() == resource.Type
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var priorID, priorVersionID string
+			if exists {
+				priorID, priorVersionID = entry.ID.ValueString(), entry.VersionID.ValueString()
+			}
+			written, err := putFhirFileResource(ctx, r.client, resource, priorID, priorVersionID)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				diags.AddError(summary, fmt.Sprintf("%s in %s: %s", resource.Key, resource.File, err))
+				return
+			}
+			id, _ := written["id"].(string)
+			meta, _ := written["meta"].(map[string]any)
+			versionID, _ := meta["versionId"].(string)
+			entries[resource.Key] = FhirResourcesEntryData{
+				File:      types.StringValue(resource.File),
+				Type:      types.StringValue(resource.Type),
+				ID:        types.StringValue(id),
+				VersionID: types.StringValue(versionID),
+				Hash:      types.StringValue(resource.Hash),
+			}
+		}()
+	}
+	wg.Wait()
+
+	return entries, diags
+}
@@ -0,0 +1,166 @@
+package provider
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/plancheck"
+	"github.com/hashicorp/terraform-plugin-testing/terraform"
+	"github.com/stretchr/testify/require"
+
+	"github.com/masslight/terraform-provider-oystehr/internal/testing/fakeoystehr"
+)
+
+const (
+	testAccColorsURL = "https://example.com/fhir/ValueSet/colors"
+	testAccShapesURL = "https://example.com/fhir/ActivityDefinition/shapes"
+	testAccSizesURL  = "https://example.com/fhir/ActivityDefinition/sizes"
+)
+
+func TestAccFhirResourcesResource(t *testing.T) {
+	server := newTestAccServer(t)
+	dir := t.TempDir()
+	writeFiles := func(title string, withSizes bool) func() {
+		return func() {
+			activities := fmt.Sprintf(`{"resourceType": "ActivityDefinition", "url": %q, "status": "active"}`+"\n", testAccShapesURL)
+			if withSizes {
+				activities += fmt.Sprintf(`{"resourceType": "ActivityDefinition", "url": %q, "status": "active"}`+"\n", testAccSizesURL)
+			}
+			files := map[string]string{
+				"intake.json":       fmt.Sprintf(`{"resourceType": "Questionnaire", "id": "intake", "status": "active", "title": %q}`, title),
+				"colors.yaml":       fmt.Sprintf("resourceType: ValueSet\nurl: %s\nstatus: active\ndate: 2024-01-01\n", testAccColorsURL),
+				"activities.ndjson": activities,
+			}
+			for name, content := range files {
+				require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600))
+			}
+		}
+	}
+	writeFiles("Intake", true)()
+	var sizesID string
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		CheckDestroy:             testAccCheckFhirResourcesDestroyed(server),
+		Steps: []resource.TestStep{
+			{
+				Config: server.ProviderConfig() + testAccFhirResourcesResourceConfig(dir),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttrSet("oystehr_fhir_resources.test", "id"),
+					resource.TestCheckResourceAttr("oystehr_fhir_resources.test", "resources.%", "4"),
+					resource.TestCheckResourceAttr("oystehr_fhir_resources.test", "resources.Questionnaire/intake.id", "intake"),
+					resource.TestCheckResourceAttr("oystehr_fhir_resources.test", "resources.Questionnaire/intake.file", "intake.json"),
+					resource.TestCheckResourceAttr("oystehr_fhir_resources.test", "resources.Questionnaire/intake.version_id", "1"),
+					resource.TestCheckResourceAttr("oystehr_fhir_resources.test", "resources."+testAccColorsURL+".type", "ValueSet"),
+					resource.TestCheckResourceAttrSet("oystehr_fhir_resources.test", "resources."+testAccColorsURL+".id"),
+					resource.TestCheckResourceAttr("oystehr_fhir_resources.test", "resources."+testAccSizesURL+".file", "activities.ndjson"),
+					testAccCheckFhirResourcesField(server, "Questionnaire/intake", "title", "Intake"),
+					testAccCheckFhirResourcesField(server, testAccColorsURL, "date", "2024-01-01"),
+					func(s *terraform.State) error {
+						sizesID = s.RootModule().Resources["oystehr_fhir_resources.test"].Primary.Attributes["resources."+testAccSizesURL+".id"]
+						return nil
+					},
+				),
+			},
+			{
+				// Only the resources whose files changed are updated
+				PreConfig: writeFiles("New patient intake", false),
+				Config:    server.ProviderConfig() + testAccFhirResourcesResourceConfig(dir),
+				ConfigPlanChecks: resource.ConfigPlanChecks{
+					PreApply: []plancheck.PlanCheck{
+						plancheck.ExpectResourceAction("oystehr_fhir_resources.test", plancheck.ResourceActionUpdate),
+					},
+				},
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("oystehr_fhir_resources.test", "resources.%", "3"),
+					resource.TestCheckResourceAttr("oystehr_fhir_resources.test", "resources.Questionnaire/intake.version_id", "2"),
+					resource.TestCheckResourceAttr("oystehr_fhir_resources.test", "resources."+testAccColorsURL+".version_id", "1"),
+					resource.TestCheckNoResourceAttr("oystehr_fhir_resources.test", "resources."+testAccSizesURL+".id"),
+					testAccCheckFhirResourcesField(server, "Questionnaire/intake", "title", "New patient intake"),
+					func(*terraform.State) error {
+						if _, ok := server.FhirResource("ActivityDefinition", sizesID); ok {
+							return fmt.Errorf("ActivityDefinition/%s still exists", sizesID)
+						}
+						return nil
+					},
+				),
+			},
+			{
+				// A change made outside of Terraform is reverted to the file
+				PreConfig: func() {
+					stored, ok := server.FhirResource("Questionnaire", "intake")
+					require.True(t, ok)
+					stored["title"] = "Changed"
+					server.UpdateFhirResource(stored)
+				},
+				Config: server.ProviderConfig() + testAccFhirResourcesResourceConfig(dir),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("oystehr_fhir_resources.test", "resources.Questionnaire/intake.version_id", "4"),
+					testAccCheckFhirResourcesField(server, "Questionnaire/intake", "title", "New patient intake"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccFhirResourcesResourceInvalidSource(t *testing.T) {
+	server := newTestAccServer(t)
+	source := writeTestFile(t, "patient.json", `{"resourceType": "Patient", "name": [{"family": "Smith"}]}`)
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config:      server.ProviderConfig() + testAccFhirResourcesResourceConfig(source),
+				ExpectError: regexp.MustCompile(`Patient has neither an id nor a canonical url`),
+			},
+		},
+	})
+}
+
+func testAccFhirResourcesResourceConfig(source string) string {
+	return fmt.Sprintf(`
+resource "oystehr_fhir_resources" "test" {
+  source = %q
+}
+`, source)
+}
+
+// testAccCheckFhirResourcesField checks a field of the stored resource of an
+// entry.
+func testAccCheckFhirResourcesField(server *fakeoystehr.Server, key, field, expected string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		attributes := s.RootModule().Resources["oystehr_fhir_resources.test"].Primary.Attributes
+		resourceType := attributes["resources."+key+".type"]
+		stored, ok := server.FhirResource(resourceType, attributes["resources."+key+".id"])
+		if !ok {
+			return fmt.Errorf("%s does not exist", key)
+		}
+		if stored[field] != expected {
+			return fmt.Errorf("expected %s.%s to be %q, got %v", key, field, expected, stored[field])
+		}
+		return nil
+	}
+}
+
+func testAccCheckFhirResourcesDestroyed(server *fakeoystehr.Server) func(*terraform.State) error {
+	return func(s *terraform.State) error {
+		for _, rs := range s.RootModule().Resources {
+			if rs.Type != "oystehr_fhir_resources" {
+				continue
+			}
+			for _, key := range []string{"Questionnaire/intake", testAccColorsURL, testAccShapesURL} {
+				resourceType := rs.Primary.Attributes["resources."+key+".type"]
+				id := rs.Primary.Attributes["resources."+key+".id"]
+				if _, ok := server.FhirResource(resourceType, id); ok {
+					return fmt.Errorf("%s/%s still exists", resourceType, id)
+				}
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,172 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/masslight/terraform-provider-oystehr/internal/client"
+)
+
+// defaultFhirSearchMaxPages bounds how many result pages a search fetches when
+// max_pages is not set.
+const defaultFhirSearchMaxPages = 10
+
+type FhirSearchDataSourceModel struct {
+	Type      types.String           `tfsdk:"type"`
+	Params    []FhirSearchParamModel `tfsdk:"params"`
+	MaxPages  types.Int64            `tfsdk:"max_pages"`
+	Resources types.Dynamic          `tfsdk:"resources"`
+	Included  types.Dynamic          `tfsdk:"included"`
+	Truncated types.Bool             `tfsdk:"truncated"`
+}
+
+type FhirSearchParamModel struct {
+	Name  types.String `tfsdk:"name"`
+	Value types.String `tfsdk:"value"`
+}
+
+func convertFhirSearchParamsToValues(params []FhirSearchParamModel) url.Values {
+	values := url.Values{}
+	for _, param := range params {
+		values.Add(param.Name.ValueString(), param.Value.ValueString())
+	}
+	return values
+}
+
+// convertRawResourcesToDynamic converts FHIR resources to a list of objects.
+func convertRawResourcesToDynamic(ctx context.Context, resources []map[string]any) (types.Dynamic, diag.Diagnostics) {
+	elements := make([]any, len(resources))
+	for i, resource := range resources {
+		elements[i] = resource
+	}
+	_, value, diags := valueToTerraformValue(ctx, elements)
+	if diags.HasError() {
+		return types.DynamicNull(), diags
+	}
+	return types.DynamicValue(value), nil
+}
+
+var _ datasource.DataSource = &FhirSearchDataSource{}
+var _ datasource.DataSourceWithConfigure = &FhirSearchDataSource{}
+
+type FhirSearchDataSource struct {
+	client *client.Client
+}
+
+func NewFhirSearchDataSource() datasource.DataSource {
+	return &FhirSearchDataSource{}
+}
+
+func (d *FhirSearchDataSource) Metadata(ctx context.Context, _ datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = "oystehr_fhir_search"
+}
+
+func (d *FhirSearchDataSource) Schema(ctx context.Context, _ datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Attributes: map[string]schema.Attribute{
+			"type": schema.StringAttribute{
+				Required:    true,
+				Description: "The FHIR resource type to search (e.g., Organization, ValueSet).",
+			},
+			"params": schema.ListNestedAttribute{
+				Optional:    true,
+				Description: "The search parameters, e.g. `identifier`, `_include`, `_revinclude`, `_elements` or `_count`. A parameter may be given more than once.",
+				NestedObject: schema.NestedAttributeObject{
+					Attributes: map[string]schema.Attribute{
+						"name": schema.StringAttribute{
+							Required:    true,
+							Description: "The name of the search parameter, including any modifier (e.g., name:exact).",
+						},
+						"value": schema.StringAttribute{
+							Required:    true,
+							Description: "The value of the search parameter.",
+						},
+					},
+				},
+			},
+			"max_pages": schema.Int64Attribute{
+				Optional:    true,
+				Description: fmt.Sprintf("The maximum number of result pages to fetch. Defaults to %d.", defaultFhirSearchMaxPages),
+			},
+			"resources": schema.DynamicAttribute{
+				Computed:    true,
+				Description: "The matching FHIR resources as a list of terraform objects.",
+			},
+			"included": schema.DynamicAttribute{
+				Computed:    true,
+				Description: "The FHIR resources added by `_include` and `_revinclude`, as a list of terraform objects.",
+			},
+			"truncated": schema.BoolAttribute{
+				Computed:    true,
+				Description: "Whether more results remained after `max_pages` pages.",
+			},
+		},
+	}
+}
+
+func (d *FhirSearchDataSource) Configure(ctx context.Context, req datasource.ConfigureRequest, resp *datasource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+
+	client, ok := req.ProviderData.(*client.Client)
+	if !ok {
+		resp.Diagnostics.AddError(
+			"Unexpected Provider Data Type",
+			"Expected *client.Client but got a different type.",
+		)
+		return
+	}
+
+	d.client = client
+}
+
+func (d *FhirSearchDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	var data FhirSearchDataSourceModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	maxPages := defaultFhirSearchMaxPages
+	if !data.MaxPages.IsNull() {
+		if data.MaxPages.ValueInt64() < 1 {
+			resp.Diagnostics.AddError("Invalid Max Pages", "max_pages must be at least 1")
+			return
+		}
+		maxPages = int(data.MaxPages.ValueInt64())
+	}
+
+	result, err := d.client.Fhir.SearchResources(ctx, data.Type.ValueString(), convertFhirSearchParamsToValues(data.Params), maxPages)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Error Searching FHIR Resources",
+			"Could not search FHIR resources: "+err.Error(),
+		)
+		return
+	}
+
+	resources, diags := convertRawResourcesToDynamic(ctx, result.Matches)
+	resp.Diagnostics.Append(diags...)
+	included, diags := convertRawResourcesToDynamic(ctx, result.Included)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	data.Resources = resources
+	data.Included = included
+	data.Truncated = types.BoolValue(result.Truncated)
+	if result.Truncated {
+		resp.Diagnostics.AddWarning(
+			"FHIR Search Results Truncated",
+			fmt.Sprintf("The search for %s returned more than %d pages of results. Only the first %d pages were read; narrow the search or raise max_pages.", data.Type.ValueString(), maxPages, maxPages),
+		)
+	}
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
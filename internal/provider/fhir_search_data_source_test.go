@@ -0,0 +1,78 @@
+package provider
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+)
+
+func TestAccFhirSearchDataSource(t *testing.T) {
+	server := newTestAccServer(t)
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: server.ProviderConfig() + testAccFhirSearchDataSourceConfig,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("data.oystehr_fhir_search.by_identifier", "resources.#", "1"),
+					resource.TestCheckResourceAttrPair("data.oystehr_fhir_search.by_identifier", "resources.0.id", "oystehr_fhir_resource.organization.0", "id"),
+					resource.TestCheckResourceAttr("data.oystehr_fhir_search.by_identifier", "resources.0.name", "Clinic 0"),
+					resource.TestCheckResourceAttr("data.oystehr_fhir_search.by_identifier", "included.#", "1"),
+					resource.TestCheckResourceAttrPair("data.oystehr_fhir_search.by_identifier", "included.0.id", "oystehr_fhir_resource.location", "id"),
+					resource.TestCheckResourceAttr("data.oystehr_fhir_search.by_identifier", "truncated", "false"),
+					resource.TestCheckResourceAttr("data.oystehr_fhir_search.paged", "resources.#", "2"),
+					resource.TestCheckResourceAttrSet("data.oystehr_fhir_search.paged", "resources.0.name"),
+					resource.TestCheckNoResourceAttr("data.oystehr_fhir_search.paged", "resources.0.identifier.#"),
+					resource.TestCheckResourceAttr("data.oystehr_fhir_search.paged", "truncated", "true"),
+				),
+			},
+		},
+	})
+}
+
+const testAccFhirSearchDataSourceConfig = `
+resource "oystehr_fhir_resource" "organization" {
+  count = 3
+  type  = "Organization"
+  data = {
+    resourceType = "Organization"
+    name         = "Clinic ${count.index}"
+    identifier = [
+      {
+        system = "https://example.com/clinics"
+        value  = "clinic-${count.index}"
+      },
+    ]
+  }
+}
+
+resource "oystehr_fhir_resource" "location" {
+  type = "Location"
+  data = {
+    resourceType         = "Location"
+    name                 = "Main Street"
+    managingOrganization = { reference = "Organization/${oystehr_fhir_resource.organization[0].id}" }
+  }
+}
+
+data "oystehr_fhir_search" "by_identifier" {
+  type = "Organization"
+  params = [
+    { name = "identifier", value = "https://example.com/clinics|clinic-0" },
+    { name = "_revinclude", value = "Location:managingOrganization" },
+  ]
+  depends_on = [oystehr_fhir_resource.location]
+}
+
+data "oystehr_fhir_search" "paged" {
+  type = "Organization"
+  params = [
+    { name = "name", value = "Clinic" },
+    { name = "_count", value = "1" },
+    { name = "_elements", value = "name" },
+  ]
+  max_pages  = 2
+  depends_on = [oystehr_fhir_resource.organization]
+}
+`
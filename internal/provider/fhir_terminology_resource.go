@@ -0,0 +1,399 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringdefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/masslight/terraform-provider-oystehr/internal/client"
+	"github.com/masslight/terraform-provider-oystehr/internal/fs"
+)
+
+type FhirTerminologyData struct {
+	ID            types.String `tfsdk:"id"`
+	Source        types.String `tfsdk:"source"`
+	Type          types.String `tfsdk:"type"`
+	URL           types.String `tfsdk:"url"`
+	VersionID     types.String `tfsdk:"version_id"`
+	ContentHash   types.String `tfsdk:"content_hash"`
+	ConceptCount  types.Int64  `tfsdk:"concept_count"`
+	CheckExpand   types.Bool   `tfsdk:"check_expand"`
+	CheckCodes    types.List   `tfsdk:"check_codes"`
+	RemovalPolicy types.String `tfsdk:"removal_policy"`
+}
+
+var _ resource.Resource = &FhirTerminologyResource{}
+var _ resource.ResourceWithConfigure = &FhirTerminologyResource{}
+var _ resource.ResourceWithModifyPlan = &FhirTerminologyResource{}
+
+// FhirTerminologyResource manages a CodeSystem or ValueSet uploaded from a
+// file. The state holds a hash of its content and its number of concepts
+// rather than the content, which may hold tens of thousands of concepts.
+type FhirTerminologyResource struct {
+	client *client.Client
+}
+
+func NewFhirTerminologyResource() resource.Resource {
+	return &FhirTerminologyResource{}
+}
+
+func (r *FhirTerminologyResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = "oystehr_fhir_terminology"
+}
+
+func (r *FhirTerminologyResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Attributes: map[string]schema.Attribute{
+			"id": schema.StringAttribute{
+				Computed:    true,
+				Description: "The ID of the CodeSystem or ValueSet. A resource without an ID in the source file is created or adopted by a conditional update on its canonical URL.",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"source": schema.StringAttribute{
+				Required:    true,
+				Description: "The path to a JSON or YAML file holding a CodeSystem or ValueSet.",
+			},
+			"type": schema.StringAttribute{
+				Computed:    true,
+				Description: "The resource type, 'CodeSystem' or 'ValueSet', read from the source file.",
+			},
+			"url": schema.StringAttribute{
+				Computed:    true,
+				Description: "The canonical URL of the CodeSystem or ValueSet.",
+			},
+			"version_id": schema.StringAttribute{
+				Computed:    true,
+				Description: "The version ID of the CodeSystem or ValueSet.",
+			},
+			"content_hash": schema.StringAttribute{
+				Computed:    true,
+				Description: "The SHA-256 hash of the resource's content, leaving out its id, meta.versionId and meta.lastUpdated. A change made outside of Terraform changes the hash, so the source file is uploaded again.",
+			},
+			"concept_count": schema.Int64Attribute{
+				Computed:    true,
+				Description: "The number of concepts the CodeSystem defines, including nested concepts, or the number of concepts the compose of the ValueSet includes by code.",
+			},
+			"check_expand": schema.BoolAttribute{
+				Optional:    true,
+				Description: "Whether to check that the ValueSet expands to at least one code with '$expand' after each apply.",
+			},
+			"check_codes": schema.ListAttribute{
+				Optional:    true,
+				ElementType: types.StringType,
+				Description: "Codes to check with '$validate-code' after each apply. For a CodeSystem, each is a code of the system, and for a ValueSet, a 'system|code' pair.",
+			},
+			"removal_policy": schema.StringAttribute{
+				Optional:    true,
+				Computed:    true,
+				Description: "The removal policy for the FHIR resource. Valid values are 'delete' and 'retain'. Defaults to 'delete'.",
+				Default:     stringdefault.StaticString("delete"),
+			},
+		},
+	}
+}
+
+func (r *FhirTerminologyResource) Configure(_ context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+
+	client, ok := req.ProviderData.(*client.Client)
+	if !ok {
+		resp.Diagnostics.AddError(
+			"Unexpected Provider Data Type",
+			"Expected *sdk.Client but got a different type.",
+		)
+		return
+	}
+
+	r.client = client
+}
+
+func (r *FhirTerminologyResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	var plan FhirTerminologyData
+
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	terminology, err := loadFhirTerminology(plan.Source.ValueString())
+	if err != nil {
+		resp.Diagnostics.AddError("Error Creating FHIR Terminology", err.Error())
+		return
+	}
+	if terminology.Hash != plan.ContentHash.ValueString() {
+		resp.Diagnostics.AddError("Error Creating FHIR Terminology", fmt.Sprintf("%s changed after the plan was made, plan again to apply it", plan.Source.ValueString()))
+		return
+	}
+	created, err := putFhirFileResource(ctx, r.client, terminology, "", "")
+	if err != nil {
+		resp.Diagnostics.AddError("Error Creating FHIR Terminology", err.Error())
+		return
+	}
+	plan.ID, plan.VersionID = fhirTerminologyVersion(created)
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	resp.Diagnostics.Append(r.check(ctx, plan)...)
+}
+
+func (r *FhirTerminologyResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	var state FhirTerminologyData
+
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	fetched, err := r.client.Fhir.GetResource(ctx, state.Type.ValueString(), state.ID.ValueString())
+	if err != nil {
+		if client.IsGone(err) || client.IsNotFound(err) {
+			resp.State.RemoveResource(ctx)
+			return
+		}
+		resp.Diagnostics.AddError("Error Reading FHIR Terminology", err.Error())
+		return
+	}
+
+	// The content is only hashed again when it changed outside of Terraform
+	_, versionID := fhirTerminologyVersion(fetched)
+	if !versionID.Equal(state.VersionID) {
+		hash, err := fhirResourceHash(fetched)
+		if err != nil {
+			resp.Diagnostics.AddError("Error Reading FHIR Terminology", err.Error())
+			return
+		}
+		state.VersionID = versionID
+		state.ContentHash = types.StringValue(hash)
+		state.ConceptCount = types.Int64Value(countConcepts(fetched))
+		state.URL = types.StringNull()
+		if url, _ := fetched["url"].(string); url != "" {
+			state.URL = types.StringValue(url)
+		}
+	}
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, state)...)
+}
+
+func (r *FhirTerminologyResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	var plan FhirTerminologyData
+	var state FhirTerminologyData
+
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if plan.VersionID.IsUnknown() {
+		terminology, err := loadFhirTerminology(plan.Source.ValueString())
+		if err != nil {
+			resp.Diagnostics.AddError("Error Updating FHIR Terminology", err.Error())
+			return
+		}
+		if terminology.Hash != plan.ContentHash.ValueString() {
+			resp.Diagnostics.AddError("Error Updating FHIR Terminology", fmt.Sprintf("%s changed after the plan was made, plan again to apply it", plan.Source.ValueString()))
+			return
+		}
+		updated, err := putFhirFileResource(ctx, r.client, terminology, state.ID.ValueString(), state.VersionID.ValueString())
+		if err != nil {
+			resp.Diagnostics.AddError("Error Updating FHIR Terminology", err.Error())
+			return
+		}
+		plan.ID, plan.VersionID = fhirTerminologyVersion(updated)
+	}
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	resp.Diagnostics.Append(r.check(ctx, plan)...)
+}
+
+func (r *FhirTerminologyResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	var state FhirTerminologyData
+
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if state.RemovalPolicy.ValueString() != "delete" {
+		return
+	}
+
+	err := r.client.Fhir.DeleteResource(ctx, state.Type.ValueString(), state.ID.ValueString())
+	if err != nil && !client.IsGone(err) && !client.IsNotFound(err) {
+		resp.Diagnostics.AddError("Error Deleting FHIR Terminology", err.Error())
+		return
+	}
+}
+
+func (r *FhirTerminologyResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	if req.Plan.Raw.IsNull() {
+		return
+	}
+
+	var plan FhirTerminologyData
+	var state FhirTerminologyData
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if !req.State.Raw.IsNull() {
+		resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	}
+	if resp.Diagnostics.HasError() || plan.Source.IsUnknown() {
+		return
+	}
+
+	terminology, err := loadFhirTerminology(plan.Source.ValueString())
+	if err != nil {
+		resp.Diagnostics.AddAttributeError(path.Root("source"), "Invalid FHIR Terminology File", err.Error())
+		return
+	}
+	if plan.CheckExpand.ValueBool() && terminology.Type != "ValueSet" {
+		resp.Diagnostics.AddAttributeError(path.Root("check_expand"), "Invalid Terminology Check", "'check_expand' is only supported for ValueSets, got: "+terminology.Type)
+	}
+	var codes []types.String
+	resp.Diagnostics.Append(plan.CheckCodes.ElementsAs(ctx, &codes, true)...)
+	for i, code := range codes {
+		if terminology.Type == "ValueSet" && !code.IsUnknown() && !strings.Contains(code.ValueString(), "|") {
+			resp.Diagnostics.AddAttributeError(path.Root("check_codes").AtListIndex(i), "Invalid Terminology Check", "Expected 'system|code' for a ValueSet, got: "+code.ValueString())
+		}
+	}
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	plan.Type = types.StringValue(terminology.Type)
+	plan.URL = types.StringNull()
+	if url, _ := terminology.Data["url"].(string); url != "" {
+		plan.URL = types.StringValue(url)
+	}
+	plan.ContentHash = types.StringValue(terminology.Hash)
+	plan.ConceptCount = types.Int64Value(countConcepts(terminology.Data))
+	plan.VersionID = types.StringUnknown()
+	if terminology.ID != "" {
+		plan.ID = types.StringValue(terminology.ID)
+	}
+
+	if !req.State.Raw.IsNull() {
+		switch {
+		case terminology.Type != state.Type.ValueString():
+			resp.RequiresReplace = append(resp.RequiresReplace, path.Root("type"))
+			plan.ID = types.StringUnknown()
+			if terminology.ID != "" {
+				plan.ID = types.StringValue(terminology.ID)
+			}
+		case terminology.ID != "" && terminology.ID != state.ID.ValueString():
+			resp.RequiresReplace = append(resp.RequiresReplace, path.Root("id"))
+		default:
+			plan.ID = state.ID
+			if terminology.Hash == state.ContentHash.ValueString() {
+				plan.VersionID = state.VersionID
+			}
+		}
+	}
+
+	resp.Diagnostics.Append(resp.Plan.Set(ctx, &plan)...)
+}
+
+// check runs the $expand and $validate-code checks of a CodeSystem or
+// ValueSet.
+func (r *FhirTerminologyResource) check(ctx context.Context, plan FhirTerminologyData) diag.Diagnostics {
+	var diags diag.Diagnostics
+	resourceType := plan.Type.ValueString()
+	id := plan.ID.ValueString()
+
+	if plan.CheckExpand.ValueBool() {
+		expanded, err := r.client.Fhir.ExpandValueSet(ctx, id)
+		if err != nil {
+			diags.AddAttributeError(path.Root("check_expand"), "Terminology Check Failed", fmt.Sprintf("$expand of ValueSet/%s failed: %s", id, err))
+		} else if expansion, _ := expanded["expansion"].(map[string]any); expansion["contains"] == nil {
+			diags.AddAttributeError(path.Root("check_expand"), "Terminology Check Failed", fmt.Sprintf("ValueSet/%s expanded to no codes", id))
+		}
+	}
+
+	var codes []string
+	diags.Append(plan.CheckCodes.ElementsAs(ctx, &codes, true)...)
+	for i, code := range codes {
+		var system string
+		if resourceType == "ValueSet" {
+			system, code, _ = strings.Cut(code, "|")
+		}
+		valid, message, err := r.client.Fhir.ValidateCode(ctx, resourceType, id, system, code)
+		if err != nil {
+			diags.AddAttributeError(path.Root("check_codes").AtListIndex(i), "Terminology Check Failed", fmt.Sprintf("$validate-code of %s/%s failed: %s", resourceType, id, err))
+		} else if !valid {
+			diags.AddAttributeError(path.Root("check_codes").AtListIndex(i), "Terminology Check Failed", fmt.Sprintf("%s is not valid in %s/%s: %s", codes[i], resourceType, id, message))
+		}
+	}
+	return diags
+}
+
+// loadFhirTerminology reads the CodeSystem or ValueSet of a file.
+func loadFhirTerminology(source string) (fhirFileResource, error) {
+	resources, err := readFhirFile(fs.CleanPath(source))
+	if err != nil {
+		return fhirFileResource{}, fmt.Errorf("%s: %w", source, err)
+	}
+	if len(resources) != 1 {
+		return fhirFileResource{}, fmt.Errorf("%s: expected a single resource, found %d", source, len(resources))
+	}
+	terminology, err := newFhirFileResource(source, resources[0])
+	if err != nil {
+		return fhirFileResource{}, fmt.Errorf("%s: %w", source, err)
+	}
+	if terminology.Type != "CodeSystem" && terminology.Type != "ValueSet" {
+		return fhirFileResource{}, fmt.Errorf("%s: expected a CodeSystem or ValueSet, got %s", source, terminology.Type)
+	}
+	return terminology, nil
+}
+
+// fhirTerminologyVersion returns the ID and version ID of a resource returned
+// by the server.
+func fhirTerminologyVersion(resource map[string]any) (types.String, types.String) {
+	id, _ := resource["id"].(string)
+	meta, _ := resource["meta"].(map[string]any)
+	versionID, _ := meta["versionId"].(string)
+	return types.StringValue(id), types.StringValue(versionID)
+}
+
+// countConcepts counts the concepts a CodeSystem defines, including nested
+// concepts, or those the compose of a ValueSet includes by code.
+func countConcepts(resource map[string]any) int64 {
+	if resource["resourceType"] == "CodeSystem" {
+		var count func(concepts any) int64
+		count = func(concepts any) int64 {
+			list, _ := concepts.([]any)
+			var n int64
+			for _, elem := range list {
+				concept, _ := elem.(map[string]any)
+				n += 1 + count(concept["concept"])
+			}
+			return n
+		}
+		return count(resource["concept"])
+	}
+
+	var n int64
+	compose, _ := resource["compose"].(map[string]any)
+	includes, _ := compose["include"].([]any)
+	for _, elem := range includes {
+		include, _ := elem.(map[string]any)
+		concepts, _ := include["concept"].([]any)
+		n += int64(len(concepts))
+	}
+	return n
+}
@@ -0,0 +1,198 @@
+package provider
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/terraform"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/masslight/terraform-provider-oystehr/internal/testing/fakeoystehr"
+)
+
+const testAccColorSystemURL = "https://example.com/fhir/CodeSystem/colors"
+
+func testAccColorCodeSystem(extraConcept string) string {
+	concepts := `{"code": "red", "display": "Red", "concept": [{"code": "crimson"}]}, {"code": "blue"}`
+	if extraConcept != "" {
+		concepts += fmt.Sprintf(`, {"code": %q}`, extraConcept)
+	}
+	return fmt.Sprintf(`{"resourceType": "CodeSystem", "id": "colors", "url": %q, "status": "active", "content": "complete", "concept": [%s]}`, testAccColorSystemURL, concepts)
+}
+
+func TestAccFhirTerminologyResource(t *testing.T) {
+	server := newTestAccServer(t)
+	codeSystem := writeTestFile(t, "colors.json", testAccColorCodeSystem(""))
+	valueSet := writeTestFile(t, "colors.yaml", fmt.Sprintf("resourceType: ValueSet\nurl: https://example.com/fhir/ValueSet/colors\nstatus: active\ncompose:\n  include:\n    - system: %s\n", testAccColorSystemURL))
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		CheckDestroy:             testAccCheckFhirTerminologyDestroyed(server),
+		Steps: []resource.TestStep{
+			{
+				Config: server.ProviderConfig() + testAccFhirTerminologyResourceConfig(codeSystem, valueSet, `"crimson"`),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("oystehr_fhir_terminology.code_system", "id", "colors"),
+					resource.TestCheckResourceAttr("oystehr_fhir_terminology.code_system", "type", "CodeSystem"),
+					resource.TestCheckResourceAttr("oystehr_fhir_terminology.code_system", "url", testAccColorSystemURL),
+					resource.TestCheckResourceAttr("oystehr_fhir_terminology.code_system", "version_id", "1"),
+					resource.TestCheckResourceAttr("oystehr_fhir_terminology.code_system", "concept_count", "3"),
+					resource.TestCheckResourceAttrSet("oystehr_fhir_terminology.code_system", "content_hash"),
+					resource.TestCheckResourceAttrSet("oystehr_fhir_terminology.value_set", "id"),
+					resource.TestCheckResourceAttr("oystehr_fhir_terminology.value_set", "type", "ValueSet"),
+					resource.TestCheckResourceAttr("oystehr_fhir_terminology.value_set", "concept_count", "0"),
+				),
+			},
+			{
+				PreConfig: func() {
+					require.NoError(t, os.WriteFile(codeSystem, []byte(testAccColorCodeSystem("green")), 0o600))
+				},
+				Config: server.ProviderConfig() + testAccFhirTerminologyResourceConfig(codeSystem, valueSet, `"green"`),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("oystehr_fhir_terminology.code_system", "version_id", "2"),
+					resource.TestCheckResourceAttr("oystehr_fhir_terminology.code_system", "concept_count", "4"),
+					resource.TestCheckResourceAttr("oystehr_fhir_terminology.value_set", "version_id", "1"),
+				),
+			},
+			{
+				// A change made outside of Terraform is reverted to the file
+				PreConfig: func() {
+					stored, ok := server.FhirResource("CodeSystem", "colors")
+					require.True(t, ok)
+					stored["concept"] = []any{map[string]any{"code": "red"}}
+					server.UpdateFhirResource(stored)
+				},
+				Config: server.ProviderConfig() + testAccFhirTerminologyResourceConfig(codeSystem, valueSet, `"green"`),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("oystehr_fhir_terminology.code_system", "version_id", "4"),
+					resource.TestCheckResourceAttr("oystehr_fhir_terminology.code_system", "concept_count", "4"),
+					testAccCheckFhirTerminologyConcepts(server, "colors", 3),
+				),
+			},
+		},
+	})
+}
+
+func TestAccFhirTerminologyResourceChecks(t *testing.T) {
+	server := newTestAccServer(t)
+	codeSystem := writeTestFile(t, "colors.json", testAccColorCodeSystem(""))
+	valueSet := writeTestFile(t, "colors.json", fmt.Sprintf(`{"resourceType": "ValueSet", "url": "https://example.com/fhir/ValueSet/colors", "status": "active", "compose": {"include": [{"system": %q}]}}`, testAccColorSystemURL))
+	patient := writeTestFile(t, "patient.json", `{"resourceType": "Patient", "id": "1"}`)
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config:      server.ProviderConfig() + testAccFhirTerminologyResourceConfig(patient, valueSet, ""),
+				ExpectError: regexp.MustCompile(`expected\s+a\s+CodeSystem\s+or\s+ValueSet,\s+got\s+Patient`),
+			},
+			{
+				Config: server.ProviderConfig() + testAccFhirTerminologyResourceConfig(codeSystem, valueSet, "") + fmt.Sprintf(`
+resource "oystehr_fhir_terminology" "invalid" {
+  source       = %q
+  check_expand = true
+}
+`, codeSystem),
+				ExpectError: regexp.MustCompile(`'check_expand'\s+is\s+only\s+supported\s+for\s+ValueSets`),
+			},
+			{
+				Config:      server.ProviderConfig() + testAccFhirTerminologyResourceConfig(codeSystem, valueSet, `"purple"`),
+				ExpectError: regexp.MustCompile(`purple\s+is\s+not\s+valid\s+in\s+CodeSystem/colors`),
+			},
+			{
+				Config: server.ProviderConfig() + testAccFhirTerminologyResourceConfig(codeSystem, valueSet, `"blue"`),
+				Check:  resource.TestCheckResourceAttr("oystehr_fhir_terminology.code_system", "concept_count", "3"),
+			},
+		},
+	})
+}
+
+func TestCountConcepts(t *testing.T) {
+	tt := []struct {
+		name     string
+		resource map[string]any
+		expected int64
+	}{
+		{
+			name: "nested code system concepts",
+			resource: map[string]any{"resourceType": "CodeSystem", "concept": []any{
+				map[string]any{"code": "a", "concept": []any{map[string]any{"code": "a1"}, map[string]any{"code": "a2"}}},
+				map[string]any{"code": "b"},
+			}},
+			expected: 4,
+		},
+		{
+			name: "value set concepts",
+			resource: map[string]any{"resourceType": "ValueSet", "compose": map[string]any{"include": []any{
+				map[string]any{"system": "s1", "concept": []any{map[string]any{"code": "a"}, map[string]any{"code": "b"}}},
+				map[string]any{"system": "s2"},
+				map[string]any{"system": "s3", "concept": []any{map[string]any{"code": "c"}}},
+			}}},
+			expected: 3,
+		},
+		{
+			name:     "no concepts",
+			resource: map[string]any{"resourceType": "CodeSystem", "content": "not-present"},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, countConcepts(tc.resource))
+		})
+	}
+}
+
+// testAccFhirTerminologyResourceConfig configures a CodeSystem with the given
+// check_codes and a ValueSet of it, which is checked with $expand and
+// $validate-code.
+func testAccFhirTerminologyResourceConfig(codeSystem, valueSet, checkCodes string) string {
+	return fmt.Sprintf(`
+resource "oystehr_fhir_terminology" "code_system" {
+  source      = %q
+  check_codes = [%s]
+}
+
+resource "oystehr_fhir_terminology" "value_set" {
+  source       = %q
+  check_expand = true
+  check_codes  = ["%s|red"]
+
+  depends_on = [oystehr_fhir_terminology.code_system]
+}
+`, codeSystem, checkCodes, valueSet, testAccColorSystemURL)
+}
+
+// testAccCheckFhirTerminologyConcepts checks the number of top-level concepts
+// of a stored CodeSystem.
+func testAccCheckFhirTerminologyConcepts(server *fakeoystehr.Server, id string, expected int) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		stored, ok := server.FhirResource("CodeSystem", id)
+		if !ok {
+			return fmt.Errorf("CodeSystem/%s does not exist", id)
+		}
+		concepts, _ := stored["concept"].([]any)
+		if len(concepts) != expected {
+			return fmt.Errorf("expected CodeSystem/%s to have %d concepts, got %d", id, expected, len(concepts))
+		}
+		return nil
+	}
+}
+
+func testAccCheckFhirTerminologyDestroyed(server *fakeoystehr.Server) func(*terraform.State) error {
+	return func(s *terraform.State) error {
+		for _, rs := range s.RootModule().Resources {
+			if rs.Type != "oystehr_fhir_terminology" {
+				continue
+			}
+			if _, ok := server.FhirResource(rs.Primary.Attributes["type"], rs.Primary.ID); ok {
+				return fmt.Errorf("%s/%s still exists", rs.Primary.Attributes["type"], rs.Primary.ID)
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,68 @@
+package provider
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"unicode"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/masslight/terraform-provider-oystehr/internal/client"
+)
+
+// fhirPathStep matches a field of a FHIRPath expression with an optional
+// index, e.g. "item[0]".
+var fhirPathStep = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_]*)(?:\[(\d+)\])?`)
+
+// fhirPathAttributePath maps the FHIRPath location of an issue, e.g.
+// "Questionnaire.item[0].linkId", onto the data attribute. The mapping stops at
+// the first step that is not a field, such as a function call.
+func fhirPathAttributePath(expression string) path.Path {
+	attributePath := path.Root("data")
+	rest := expression
+	for first := true; rest != ""; first = false {
+		match := fhirPathStep.FindStringSubmatch(rest)
+		if match == nil || (len(match[0]) < len(rest) && rest[len(match[0])] != '.') {
+			break
+		}
+		rest = rest[len(match[0]):]
+		if len(rest) > 0 {
+			rest = rest[1:]
+		}
+		// Expressions start with the resource type
+		if first && unicode.IsUpper(rune(match[1][0])) {
+			continue
+		}
+		attributePath = attributePath.AtName(match[1])
+		if match[2] != "" {
+			index, _ := strconv.Atoi(match[2])
+			attributePath = attributePath.AtListIndex(index)
+		}
+	}
+	return attributePath
+}
+
+// addFhirValidationDiagnostics reports the error and warning issues of a
+// $validate outcome on the fields of data they concern.
+func addFhirValidationDiagnostics(diags *diag.Diagnostics, issues []client.OperationOutcomeIssue) {
+	for _, issue := range issues {
+		var expression string
+		if len(issue.Expression) > 0 {
+			expression = issue.Expression[0]
+		} else if len(issue.Location) > 0 {
+			expression = issue.Location[0]
+		}
+		detail := issue.Message()
+		if expression != "" {
+			detail = fmt.Sprintf("%s: %s", expression, detail)
+		}
+
+		switch issue.Severity {
+		case "fatal", "error":
+			diags.AddAttributeError(fhirPathAttributePath(expression), "Invalid FHIR Resource", detail)
+		case "warning":
+			diags.AddAttributeWarning(fhirPathAttributePath(expression), "FHIR Resource Validation Warning", detail)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package provider
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFhirPathAttributePath(t *testing.T) {
+	tt := []struct {
+		name       string
+		expression string
+		expected   path.Path
+	}{
+		{
+			name:       "resource",
+			expression: "Questionnaire",
+			expected:   path.Root("data"),
+		},
+		{
+			name:       "empty",
+			expression: "",
+			expected:   path.Root("data"),
+		},
+		{
+			name:       "field",
+			expression: "Patient.gender",
+			expected:   path.Root("data").AtName("gender"),
+		},
+		{
+			name:       "indexes",
+			expression: "Questionnaire.item[0].item[12].linkId",
+			expected:   path.Root("data").AtName("item").AtListIndex(0).AtName("item").AtListIndex(12).AtName("linkId"),
+		},
+		{
+			name:       "function",
+			expression: "Patient.extension.where(url='https://example.com/ext').value",
+			expected:   path.Root("data").AtName("extension"),
+		},
+		{
+			name:       "relative",
+			expression: "name[1].family",
+			expected:   path.Root("data").AtName("name").AtListIndex(1).AtName("family"),
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, fhirPathAttributePath(tc.expression))
+		})
+	}
+}
@@ -3,7 +3,6 @@ package provider
 import (
 	"context"
 	"regexp"
-	"strings"
 
 	"github.com/hashicorp/terraform-plugin-framework/attr"
 	"github.com/hashicorp/terraform-plugin-framework/path"
@@ -322,7 +321,7 @@ func (r *LabRouteResource) Read(ctx context.Context, req resource.ReadRequest, r
 
 	route, err := r.client.Lab.GetLabRoute(ctx, routeID)
 	if err != nil {
-		if strings.Contains(err.Error(), "unexpected status code: 404") {
+		if client.IsNotFound(err) {
 			resp.State.RemoveResource(ctx)
 			return
 		}
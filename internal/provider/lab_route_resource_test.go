@@ -0,0 +1,47 @@
+package provider
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+
+	"github.com/masslight/terraform-provider-oystehr/internal/testing/fakeoystehr"
+)
+
+func TestAccLabRouteResource(t *testing.T) {
+	server := newTestAccServer(t)
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		CheckDestroy:             testAccCheckDestroyed(server, "oystehr_lab_route", fakeoystehr.CollectionLabRoutes, "id"),
+		Steps: []resource.TestStep{
+			{
+				Config: server.ProviderConfig() + `
+resource "oystehr_lab_route" "test" {
+  account_number = "12345"
+  lab_id         = "lab-guid"
+  primary_name   = "Primary"
+  primary_phone  = "(123) 456-7890"
+  primary_address = {
+    address1            = "1 Main St"
+    city                = "Springfield"
+    state_province_code = "IL"
+    postal_code         = "62701"
+  }
+}
+`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttrSet("oystehr_lab_route.test", "id"),
+					resource.TestCheckResourceAttrSet("oystehr_lab_route.test", "primary_id"),
+					resource.TestCheckResourceAttr("oystehr_lab_route.test", "lab_name", "Lab lab-guid"),
+					resource.TestCheckResourceAttr("oystehr_lab_route.test", "primary_address.city", "Springfield"),
+				),
+			},
+			{
+				ResourceName:      "oystehr_lab_route.test",
+				ImportState:       true,
+				ImportStateVerify: true,
+			},
+		},
+	})
+}
@@ -2,7 +2,6 @@ package provider
 
 import (
 	"context"
-	"strings"
 
 	"github.com/hashicorp/terraform-plugin-framework/attr"
 	"github.com/hashicorp/terraform-plugin-framework/path"
@@ -223,7 +222,7 @@ func (r *M2MResource) Read(ctx context.Context, req resource.ReadRequest, resp *
 
 	m2m, err := r.client.M2M.GetM2M(ctx, id)
 	if err != nil {
-		if strings.Contains(err.Error(), "unexpected status code: 404") {
+		if client.IsNotFound(err) {
 			resp.State.RemoveResource(ctx)
 			return
 		}
@@ -0,0 +1,63 @@
+package provider
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+
+	"github.com/masslight/terraform-provider-oystehr/internal/testing/fakeoystehr"
+)
+
+func TestAccM2MResource(t *testing.T) {
+	server := newTestAccServer(t)
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		CheckDestroy:             testAccCheckDestroyed(server, "oystehr_m2m", fakeoystehr.CollectionM2M, "id"),
+		Steps: []resource.TestStep{
+			{
+				Config: server.ProviderConfig() + testAccM2MResourceConfig(0),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttrSet("oystehr_m2m.test", "id"),
+					resource.TestCheckResourceAttrSet("oystehr_m2m.test", "client_id"),
+					resource.TestCheckResourceAttrSet("oystehr_m2m.test", "client_secret"),
+					resource.TestCheckResourceAttrSet("oystehr_m2m.test", "profile"),
+					resource.TestCheckResourceAttrPair("oystehr_m2m.test", "roles.0", "oystehr_role.test", "id"),
+				),
+			},
+			{
+				ResourceName:            "oystehr_m2m.test",
+				ImportState:             true,
+				ImportStateVerify:       true,
+				ImportStateVerifyIgnore: []string{"client_secret", "client_secret_version"},
+			},
+			{
+				// Bumping the version rotates the secret in place
+				Config: server.ProviderConfig() + testAccM2MResourceConfig(1),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("oystehr_m2m.test", "client_secret_version", "1"),
+					resource.TestCheckResourceAttrSet("oystehr_m2m.test", "client_secret"),
+				),
+			},
+		},
+	})
+}
+
+func testAccM2MResourceConfig(secretVersion int) string {
+	return fmt.Sprintf(`
+resource "oystehr_role" "test" {
+  name = "M2M role"
+  access_policy = {
+    rule = []
+  }
+}
+
+resource "oystehr_m2m" "test" {
+  name                  = "Acceptance test M2M"
+  description           = "Acceptance test M2M"
+  roles                 = [oystehr_role.test.id]
+  client_secret_version = %d
+}
+`, secretVersion)
+}
@@ -0,0 +1,412 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// managedPathSegment is a field of a managed_fields path. A segment with a Key
+// selects the elements of an array field whose Key field equals Value, e.g.
+// telecom[system=email].
+type managedPathSegment struct {
+	Name  string
+	Key   string
+	Value string
+}
+
+// managedPath is a parsed managed_fields entry, such as "meta.tag" or
+// "telecom[system=email]".
+type managedPath []managedPathSegment
+
+func (p managedPath) String() string {
+	names := make([]string, len(p))
+	for i, segment := range p {
+		names[i] = segment.Name
+		if segment.Key != "" {
+			names[i] += "[" + segment.Key + "=" + segment.Value + "]"
+		}
+	}
+	return strings.Join(names, ".")
+}
+
+func (p managedPath) last() managedPathSegment {
+	return p[len(p)-1]
+}
+
+func (p managedPath) parents() []string {
+	names := make([]string, len(p)-1)
+	for i, segment := range p[:len(p)-1] {
+		names[i] = segment.Name
+	}
+	return names
+}
+
+// keyedArrays are the array fields whose elements are merged by key rather
+// than replaced as a whole, keyed by the field name.
+var keyedArrays = map[string]func(map[string]any) string{
+	"extension":         extensionKey,
+	"modifierExtension": extensionKey,
+	"identifier": func(element map[string]any) string {
+		return fmt.Sprint(element["system"])
+	},
+	"coding":   codingKey,
+	"tag":      codingKey,
+	"security": codingKey,
+}
+
+func extensionKey(element map[string]any) string {
+	return fmt.Sprint(element["url"])
+}
+
+func codingKey(element map[string]any) string {
+	return fmt.Sprintf("%v|%v", element["system"], element["code"])
+}
+
+// parseManagedPath parses a dot separated managed_fields path. Selectors may
+// contain dots, e.g. extension[url=https://example.com/ext], and are only
+// supported on the last segment.
+func parseManagedPath(field string) (managedPath, error) {
+	var path managedPath
+	rest := field
+	for rest != "" {
+		var segment string
+		end := strings.IndexAny(rest, ".[")
+		if end >= 0 && rest[end] == '[' {
+			closing := strings.IndexByte(rest[end:], ']')
+			if closing < 0 {
+				return nil, fmt.Errorf("unterminated selector in %q", field)
+			}
+			closing += end
+			segment, rest = rest[:closing+1], rest[closing+1:]
+			if rest != "" && !strings.HasPrefix(rest, ".") {
+				return nil, fmt.Errorf("unexpected %q after selector in %q", rest, field)
+			}
+			rest = strings.TrimPrefix(rest, ".")
+			if rest != "" {
+				return nil, fmt.Errorf("a selector is only supported on the last field of %q", field)
+			}
+		} else if end >= 0 {
+			segment, rest = rest[:end], rest[end+1:]
+			if rest == "" {
+				return nil, fmt.Errorf("empty field in %q", field)
+			}
+		} else {
+			segment, rest = rest, ""
+		}
+
+		name, selector, hasSelector := strings.Cut(segment, "[")
+		if name == "" {
+			return nil, fmt.Errorf("empty field in %q", field)
+		}
+		pathSegment := managedPathSegment{Name: name}
+		if hasSelector {
+			key, value, ok := strings.Cut(strings.TrimSuffix(selector, "]"), "=")
+			if !ok || key == "" {
+				return nil, fmt.Errorf("expected a selector of the form [key=value] in %q", field)
+			}
+			pathSegment.Key, pathSegment.Value = key, value
+		}
+		path = append(path, pathSegment)
+	}
+	if len(path) == 0 {
+		return nil, fmt.Errorf("empty managed field")
+	}
+	return path, nil
+}
+
+func parseManagedPaths(fields []string) ([]managedPath, error) {
+	paths := make([]managedPath, len(fields))
+	for i, field := range fields {
+		path, err := parseManagedPath(field)
+		if err != nil {
+			return nil, err
+		}
+		paths[i] = path
+	}
+	return paths, nil
+}
+
+// unmanagedFields returns the top level fields of data that no managed path
+// covers. They seed the resource when it is created and are not written or
+// read back afterwards.
+func unmanagedFields(data map[string]any, paths []managedPath) []string {
+	var fields []string
+	for _, field := range sortedKeys(data) {
+		if field == "resourceType" || field == "id" {
+			continue
+		}
+		if !slices.ContainsFunc(paths, func(path managedPath) bool { return path[0].Name == field }) {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// withUnmanagedFields returns the projection of a resource with the unmanaged
+// fields of data, which the state keeps as configured.
+func withUnmanagedFields(projection, data map[string]any, paths []managedPath) map[string]any {
+	for _, field := range unmanagedFields(data, paths) {
+		projection[field] = deepCopy(data[field])
+	}
+	return projection
+}
+
+// checkManagedData returns an error for the first element of an array managed
+// by selectors that none of them selects, since it would never be written.
+func checkManagedData(data map[string]any, paths []managedPath) error {
+	selectors := make(map[string][]managedPath)
+	for _, path := range paths {
+		if path.last().Key != "" {
+			field := strings.Join(append(path.parents(), path.last().Name), ".")
+			selectors[field] = append(selectors[field], path)
+		}
+	}
+	for _, field := range slices.Sorted(maps.Keys(selectors)) {
+		path := selectors[field][0]
+		elements, _ := lookupObject(data, path.parents(), false)[path.last().Name].([]any)
+		for i, element := range elements {
+			if !slices.ContainsFunc(selectors[field], func(path managedPath) bool { return selectElements(path)(element) }) {
+				return fmt.Errorf("data sets element %d of %q, which no selector in managed_fields matches", i, field)
+			}
+		}
+	}
+	return nil
+}
+
+// projectManagedFields returns the managed parts of a resource, along with its
+// resourceType, id and version metadata. Elements of keyed arrays are only
+// managed when their key appears in owner, which is the data they were last
+// written from.
+func projectManagedFields(resource map[string]any, paths []managedPath, owner map[string]any) map[string]any {
+	projection := make(map[string]any)
+	for _, field := range []string{"resourceType", "id"} {
+		if value, ok := resource[field]; ok {
+			projection[field] = value
+		}
+	}
+	if meta, ok := resource["meta"].(map[string]any); ok {
+		projectedMeta := make(map[string]any)
+		for _, field := range []string{"versionId", "lastUpdated"} {
+			if value, ok := meta[field]; ok {
+				projectedMeta[field] = value
+			}
+		}
+		projection["meta"] = projectedMeta
+	}
+
+	for _, path := range paths {
+		value, ok := managedValue(resource, path, owner)
+		if !ok {
+			continue
+		}
+		value = deepCopy(value)
+		target := lookupObject(projection, path.parents(), true)
+		// Several selectors may pick elements of the same array
+		existing, isArray := target[path.last().Name].([]any)
+		if elements, ok := value.([]any); ok && isArray {
+			value = append(existing, elements...)
+		}
+		target[path.last().Name] = value
+	}
+	return projection
+}
+
+// managedValue returns the managed part of the field at path: the field
+// itself, or the managed elements of a selected or keyed array.
+func managedValue(resource map[string]any, path managedPath, owner map[string]any) (any, bool) {
+	value, ok := lookupObject(resource, path.parents(), false)[path.last().Name]
+	elements, isArray := value.([]any)
+	selects := selectElements(path, owner)
+	if !ok || !isArray || selects == nil {
+		return value, ok
+	}
+	var selected []any
+	for _, element := range elements {
+		if selects(element) {
+			selected = append(selected, element)
+		}
+	}
+	return selected, len(selected) > 0
+}
+
+// conflictingFields returns the managed fields whose current values differ
+// from the prior data, the data the resource was last read with. Without
+// managed paths, every field is managed.
+func conflictingFields(current, prior map[string]any, paths []managedPath) []string {
+	var conflicts []string
+	if len(paths) == 0 {
+		current = deepCopy(current).(map[string]any)
+		if meta, ok := current["meta"].(map[string]any); ok {
+			delete(meta, "versionId")
+			delete(meta, "lastUpdated")
+			if len(meta) == 0 {
+				delete(current, "meta")
+			}
+		}
+		for _, field := range sortedKeys(current, prior) {
+			if field != "resourceType" && field != "id" && !jsonEqual(current[field], prior[field]) {
+				conflicts = append(conflicts, field)
+			}
+		}
+		return conflicts
+	}
+
+	for _, path := range paths {
+		currentValue, _ := managedValue(current, path, prior)
+		priorValue, _ := managedValue(prior, path, prior)
+		if !jsonEqual(currentValue, priorValue) {
+			conflicts = append(conflicts, path.String())
+		}
+	}
+	return conflicts
+}
+
+// jsonEqual reports whether two values have the same JSON encoding, since
+// numbers converted from Terraform and decoded from JSON differ in type.
+func jsonEqual(a, b any) bool {
+	aJSON, aErr := json.Marshal(a)
+	bJSON, bErr := json.Marshal(b)
+	return aErr == nil && bErr == nil && bytes.Equal(aJSON, bJSON)
+}
+
+// mergeManagedFields writes the managed parts of data over a copy of resource,
+// leaving everything else as other systems wrote it. Elements of keyed arrays
+// are replaced when their key appears in data or prior, the data the resource
+// was last written from, so elements removed from data are removed too.
+func mergeManagedFields(resource, data, prior map[string]any, paths []managedPath) map[string]any {
+	merged := deepCopy(resource).(map[string]any)
+	for _, path := range paths {
+		last := path.last()
+		dataValue, inData := lookupObject(data, path.parents(), false)[last.Name]
+		dataElements, dataIsArray := dataValue.([]any)
+
+		selects := selectElements(path, data, prior)
+		container := lookupObject(merged, path.parents(), false)
+		elements, isArray := container[last.Name].([]any)
+		if selects == nil || (inData && !dataIsArray) || (container[last.Name] != nil && !isArray) {
+			// The whole field is managed
+			if inData {
+				lookupObject(merged, path.parents(), true)[last.Name] = deepCopy(dataValue)
+			} else {
+				delete(container, last.Name)
+				pruneEmptyObjects(merged, path.parents())
+			}
+			continue
+		}
+
+		// Replace the managed elements in place, keeping the others
+		var result []any
+		inserted := false
+		insert := func() {
+			if !inserted {
+				// Only the elements this path selects, since several
+				// selectors may manage elements of the same array
+				for _, element := range dataElements {
+					if last.Key == "" || selects(element) {
+						result = append(result, deepCopy(element))
+					}
+				}
+				inserted = true
+			}
+		}
+		for _, element := range elements {
+			if selects(element) {
+				insert()
+				continue
+			}
+			result = append(result, element)
+		}
+		insert()
+		if len(result) == 0 {
+			delete(container, last.Name)
+			pruneEmptyObjects(merged, path.parents())
+			continue
+		}
+		lookupObject(merged, path.parents(), true)[last.Name] = result
+	}
+	return merged
+}
+
+// selectElements returns a predicate for the managed elements of the array at
+// path, or nil if the array is managed as a whole. Keyed array elements are
+// managed when their key appears in the array at path in any of owners.
+func selectElements(path managedPath, owners ...map[string]any) func(any) bool {
+	last := path.last()
+	if last.Key != "" {
+		return func(element any) bool {
+			object, ok := element.(map[string]any)
+			return ok && object[last.Key] != nil && fmt.Sprint(object[last.Key]) == last.Value
+		}
+	}
+	key, ok := keyedArrays[last.Name]
+	if !ok {
+		return nil
+	}
+	keys := make(map[string]bool)
+	for _, owner := range owners {
+		elements, _ := lookupObject(owner, path.parents(), false)[last.Name].([]any)
+		for _, element := range elements {
+			if object, ok := element.(map[string]any); ok {
+				keys[key(object)] = true
+			}
+		}
+	}
+	return func(element any) bool {
+		object, ok := element.(map[string]any)
+		return ok && keys[key(object)]
+	}
+}
+
+// lookupObject returns the object at the path of field names, or nil if there
+// is none. With create, missing objects are added.
+func lookupObject(object map[string]any, names []string, create bool) map[string]any {
+	for _, name := range names {
+		if object == nil {
+			return nil
+		}
+		next, ok := object[name].(map[string]any)
+		if !ok {
+			if !create {
+				return nil
+			}
+			next = make(map[string]any)
+			object[name] = next
+		}
+		object = next
+	}
+	return object
+}
+
+// pruneEmptyObjects removes the objects along the path that were left empty,
+// deepest first, since FHIR does not allow empty elements.
+func pruneEmptyObjects(object map[string]any, names []string) {
+	for i := len(names); i > 0; i-- {
+		parent := lookupObject(object, names[:i-1], false)
+		if child, ok := parent[names[i-1]].(map[string]any); ok && len(child) == 0 {
+			delete(parent, names[i-1])
+		}
+	}
+}
+
+func deepCopy(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		copied := make(map[string]any, len(v))
+		for k, elem := range v {
+			copied[k] = deepCopy(elem)
+		}
+		return copied
+	case []any:
+		copied := make([]any, len(v))
+		for i, elem := range v {
+			copied[i] = deepCopy(elem)
+		}
+		return copied
+	default:
+		return v
+	}
+}
@@ -0,0 +1,428 @@
+package provider
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseManagedPath(t *testing.T) {
+	tt := []struct {
+		name     string
+		field    string
+		expected managedPath
+		err      string
+	}{
+		{
+			name:     "field",
+			field:    "name",
+			expected: managedPath{{Name: "name"}},
+		},
+		{
+			name:     "nested field",
+			field:    "meta.tag",
+			expected: managedPath{{Name: "meta"}, {Name: "tag"}},
+		},
+		{
+			name:     "selector",
+			field:    "telecom[system=email]",
+			expected: managedPath{{Name: "telecom", Key: "system", Value: "email"}},
+		},
+		{
+			name:     "selector containing dots",
+			field:    "extension[url=https://example.com/ext]",
+			expected: managedPath{{Name: "extension", Key: "url", Value: "https://example.com/ext"}},
+		},
+		{
+			name:  "empty field",
+			field: "meta..tag",
+			err:   `empty field in "meta..tag"`,
+		},
+		{
+			name:  "trailing dot",
+			field: "meta.",
+			err:   `empty field in "meta."`,
+		},
+		{
+			name:  "unterminated selector",
+			field: "telecom[system=email",
+			err:   `unterminated selector in "telecom[system=email"`,
+		},
+		{
+			name:  "selector without value",
+			field: "telecom[system]",
+			err:   `expected a selector of the form [key=value] in "telecom[system]"`,
+		},
+		{
+			name:  "selector before the last field",
+			field: "contact[gender=female].name",
+			err:   `a selector is only supported on the last field of "contact[gender=female].name"`,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			path, err := parseManagedPath(tc.field)
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, path)
+		})
+	}
+}
+
+func TestProjectManagedFields(t *testing.T) {
+	resource := map[string]any{
+		"resourceType": "Patient",
+		"id":           "1",
+		"active":       true,
+		"meta": map[string]any{
+			"versionId":   "2",
+			"lastUpdated": "2024-01-01T00:00:00Z",
+			"source":      "other",
+			"tag": []any{
+				map[string]any{"system": "s", "code": "ours"},
+				map[string]any{"system": "s", "code": "theirs"},
+			},
+		},
+		"name": []any{map[string]any{"family": "Smith"}},
+		"telecom": []any{
+			map[string]any{"system": "phone", "value": "555-0100"},
+			map[string]any{"system": "email", "value": "jane@example.com"},
+		},
+		"extension": []any{
+			map[string]any{"url": "https://example.com/theirs", "valueString": "theirs"},
+			map[string]any{"url": "https://example.com/ours", "valueString": "ours"},
+		},
+	}
+	owner := map[string]any{
+		"meta":      map[string]any{"tag": []any{map[string]any{"system": "s", "code": "ours"}}},
+		"extension": []any{map[string]any{"url": "https://example.com/ours"}},
+	}
+	base := map[string]any{
+		"resourceType": "Patient",
+		"id":           "1",
+		"meta":         map[string]any{"versionId": "2", "lastUpdated": "2024-01-01T00:00:00Z"},
+	}
+
+	tt := []struct {
+		name     string
+		fields   []string
+		expected map[string]any
+	}{
+		{
+			name:   "field",
+			fields: []string{"name", "birthDate"},
+			expected: map[string]any{
+				"name": []any{map[string]any{"family": "Smith"}},
+			},
+		},
+		{
+			name:   "keyed array",
+			fields: []string{"extension"},
+			expected: map[string]any{
+				"extension": []any{map[string]any{"url": "https://example.com/ours", "valueString": "ours"}},
+			},
+		},
+		{
+			name:   "nested keyed array",
+			fields: []string{"meta.tag"},
+			expected: map[string]any{
+				"meta": map[string]any{
+					"versionId":   "2",
+					"lastUpdated": "2024-01-01T00:00:00Z",
+					"tag":         []any{map[string]any{"system": "s", "code": "ours"}},
+				},
+			},
+		},
+		{
+			name:   "selectors",
+			fields: []string{"telecom[system=email]", "telecom[system=phone]"},
+			expected: map[string]any{
+				"telecom": []any{
+					map[string]any{"system": "email", "value": "jane@example.com"},
+					map[string]any{"system": "phone", "value": "555-0100"},
+				},
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			paths, err := parseManagedPaths(tc.fields)
+			require.NoError(t, err)
+			expected := deepCopy(base).(map[string]any)
+			for k, v := range tc.expected {
+				expected[k] = v
+			}
+			assert.Equal(t, expected, projectManagedFields(resource, paths, owner))
+		})
+	}
+}
+
+func TestMergeManagedFields(t *testing.T) {
+	resource := map[string]any{
+		"resourceType": "Patient",
+		"id":           "1",
+		"active":       true,
+		"meta": map[string]any{
+			"versionId": "2",
+			"tag": []any{
+				map[string]any{"system": "s", "code": "ours"},
+				map[string]any{"system": "s", "code": "theirs"},
+			},
+		},
+		"name": []any{map[string]any{"family": "Smith"}},
+		"telecom": []any{
+			map[string]any{"system": "phone", "value": "555-0100"},
+			map[string]any{"system": "email", "value": "jane@example.com"},
+		},
+		"extension": []any{
+			map[string]any{"url": "https://example.com/theirs", "valueString": "theirs"},
+			map[string]any{"url": "https://example.com/ours", "valueString": "ours"},
+		},
+	}
+
+	tt := []struct {
+		name     string
+		fields   []string
+		data     map[string]any
+		prior    map[string]any
+		expected map[string]any
+	}{
+		{
+			name:   "field",
+			fields: []string{"name"},
+			data:   map[string]any{"name": []any{map[string]any{"family": "Jones"}}},
+			expected: map[string]any{
+				"name": []any{map[string]any{"family": "Jones"}},
+			},
+		},
+		{
+			name:     "removed field",
+			fields:   []string{"name"},
+			data:     map[string]any{},
+			expected: map[string]any{"name": nil},
+		},
+		{
+			name:   "keyed array",
+			fields: []string{"extension"},
+			data: map[string]any{"extension": []any{
+				map[string]any{"url": "https://example.com/ours", "valueString": "changed"},
+				map[string]any{"url": "https://example.com/new", "valueString": "new"},
+			}},
+			expected: map[string]any{
+				"extension": []any{
+					map[string]any{"url": "https://example.com/theirs", "valueString": "theirs"},
+					map[string]any{"url": "https://example.com/ours", "valueString": "changed"},
+					map[string]any{"url": "https://example.com/new", "valueString": "new"},
+				},
+			},
+		},
+		{
+			name:   "removed keyed array element",
+			fields: []string{"extension"},
+			data:   map[string]any{},
+			prior:  map[string]any{"extension": []any{map[string]any{"url": "https://example.com/ours"}}},
+			expected: map[string]any{
+				"extension": []any{map[string]any{"url": "https://example.com/theirs", "valueString": "theirs"}},
+			},
+		},
+		{
+			name:   "nested keyed array",
+			fields: []string{"meta.tag"},
+			data:   map[string]any{"meta": map[string]any{"tag": []any{map[string]any{"system": "s", "code": "new"}}}},
+			prior:  map[string]any{"meta": map[string]any{"tag": []any{map[string]any{"system": "s", "code": "ours"}}}},
+			expected: map[string]any{
+				"meta": map[string]any{
+					"versionId": "2",
+					"tag": []any{
+						map[string]any{"system": "s", "code": "new"},
+						map[string]any{"system": "s", "code": "theirs"},
+					},
+				},
+			},
+		},
+		{
+			name:   "selector",
+			fields: []string{"telecom[system=email]"},
+			data:   map[string]any{"telecom": []any{map[string]any{"system": "email", "value": "new@example.com"}}},
+			expected: map[string]any{
+				"telecom": []any{
+					map[string]any{"system": "phone", "value": "555-0100"},
+					map[string]any{"system": "email", "value": "new@example.com"},
+				},
+			},
+		},
+		{
+			name:   "selectors sharing an array",
+			fields: []string{"telecom[system=email]", "telecom[system=phone]"},
+			data: map[string]any{"telecom": []any{
+				map[string]any{"system": "email", "value": "new@example.com"},
+				map[string]any{"system": "phone", "value": "555-0199"},
+			}},
+			expected: map[string]any{
+				"telecom": []any{
+					map[string]any{"system": "phone", "value": "555-0199"},
+					map[string]any{"system": "email", "value": "new@example.com"},
+				},
+			},
+		},
+		{
+			name:     "removed nested field",
+			fields:   []string{"contact.name"},
+			data:     map[string]any{},
+			expected: map[string]any{},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			paths, err := parseManagedPaths(tc.fields)
+			require.NoError(t, err)
+			expected := deepCopy(resource).(map[string]any)
+			for k, v := range tc.expected {
+				if v == nil {
+					delete(expected, k)
+				} else {
+					expected[k] = v
+				}
+			}
+			original := deepCopy(resource)
+			assert.Equal(t, expected, mergeManagedFields(resource, tc.data, tc.prior, paths))
+			assert.Equal(t, original, resource, "the resource must not be modified")
+		})
+	}
+}
+
+func TestCheckManagedData(t *testing.T) {
+	tt := []struct {
+		name     string
+		fields   []string
+		data     map[string]any
+		expected string
+	}{
+		{
+			name:   "unmanaged field",
+			fields: []string{"name"},
+			data:   map[string]any{"name": []any{}, "active": true},
+		},
+		{
+			name:   "selected elements",
+			fields: []string{"telecom[system=email]", "telecom[system=phone]"},
+			data: map[string]any{"telecom": []any{
+				map[string]any{"system": "email", "value": "jane@example.com"},
+				map[string]any{"system": "phone", "value": "555-0100"},
+			}},
+		},
+		{
+			name:   "unselected element",
+			fields: []string{"telecom[system=email]"},
+			data: map[string]any{"telecom": []any{
+				map[string]any{"system": "email", "value": "jane@example.com"},
+				map[string]any{"system": "phone", "value": "555-0100"},
+			}},
+			expected: `data sets element 1 of "telecom", which no selector in managed_fields matches`,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			paths, err := parseManagedPaths(tc.fields)
+			require.NoError(t, err)
+			err = checkManagedData(tc.data, paths)
+			if tc.expected == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, tc.expected)
+		})
+	}
+}
+
+func TestWithUnmanagedFields(t *testing.T) {
+	paths, err := parseManagedPaths([]string{"name", "meta.tag"})
+	require.NoError(t, err)
+	data := map[string]any{
+		"resourceType": "Patient",
+		"id":           "1",
+		"active":       true,
+		"name":         []any{map[string]any{"family": "Smith"}},
+		"meta":         map[string]any{"tag": []any{}},
+	}
+
+	assert.Equal(t, []string{"active"}, unmanagedFields(data, paths))
+	assert.Equal(t,
+		map[string]any{"id": "1", "name": "projected", "active": true},
+		withUnmanagedFields(map[string]any{"id": "1", "name": "projected"}, data, paths),
+	)
+}
+
+func TestConflictingFields(t *testing.T) {
+	prior := map[string]any{
+		"resourceType":         "Patient",
+		"id":                   "1",
+		"name":                 []any{map[string]any{"family": "Smith"}},
+		"birthDate":            "1990-01-01",
+		"multipleBirthInteger": int64(2),
+		"extension":            []any{map[string]any{"url": "https://example.com/ours", "valueString": "ours"}},
+	}
+
+	tt := []struct {
+		name     string
+		fields   []string
+		current  map[string]any
+		expected []string
+	}{
+		{
+			name:   "unchanged",
+			fields: []string{"name", "multipleBirthInteger", "extension"},
+			current: map[string]any{
+				"meta":                 map[string]any{"versionId": "3"},
+				"name":                 []any{map[string]any{"family": "Smith"}},
+				"multipleBirthInteger": float64(2),
+				"active":               true,
+				"extension": []any{
+					map[string]any{"url": "https://example.com/theirs", "valueString": "theirs"},
+					map[string]any{"url": "https://example.com/ours", "valueString": "ours"},
+				},
+			},
+		},
+		{
+			name:   "managed fields changed",
+			fields: []string{"name", "birthDate", "extension"},
+			current: map[string]any{
+				"name":      []any{map[string]any{"family": "Jones"}},
+				"extension": []any{map[string]any{"url": "https://example.com/ours", "valueString": "changed"}},
+			},
+			expected: []string{"name", "birthDate", "extension"},
+		},
+		{
+			name: "whole resource",
+			current: map[string]any{
+				"meta":                 map[string]any{"versionId": "3", "lastUpdated": "2024-01-01T00:00:00Z"},
+				"name":                 []any{map[string]any{"family": "Smith"}},
+				"birthDate":            "1990-01-02",
+				"multipleBirthInteger": float64(2),
+				"extension":            prior["extension"],
+				"active":               true,
+			},
+			expected: []string{"active", "birthDate"},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			paths, err := parseManagedPaths(tc.fields)
+			require.NoError(t, err)
+			current := map[string]any{"resourceType": "Patient", "id": "1"}
+			for k, v := range tc.current {
+				current[k] = v
+			}
+			assert.Equal(t, tc.expected, conflictingFields(current, prior, paths))
+		})
+	}
+}
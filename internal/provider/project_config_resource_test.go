@@ -0,0 +1,67 @@
+package provider
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/terraform"
+
+	"github.com/masslight/terraform-provider-oystehr/internal/testing/fakeoystehr"
+)
+
+func TestAccProjectConfigResource(t *testing.T) {
+	server := newTestAccServer(t)
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		// Destroying the configuration only disables signup
+		CheckDestroy: func(*terraform.State) error {
+			if server.Project()["signupEnabled"] != false {
+				return errors.New("signup is still enabled")
+			}
+			return nil
+		},
+		Steps: []resource.TestStep{
+			{
+				Config: server.ProviderConfig() + testAccProjectConfigResourceConfig,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("oystehr_project_configuration.test", "id", fakeoystehr.ProjectID),
+					resource.TestCheckResourceAttr("oystehr_project_configuration.test", "name", "Acceptance test project"),
+					resource.TestCheckResourceAttr("oystehr_project_configuration.test", "signup_enabled", "true"),
+					resource.TestCheckResourceAttrPair("oystehr_project_configuration.test", "default_patient_role_id", "oystehr_role.patient", "id"),
+				),
+			},
+			{
+				Config: server.ProviderConfig() + testAccProjectConfigResourceConfig + `
+data "oystehr_project" "test" {
+  depends_on = [oystehr_project_configuration.test]
+}
+`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("data.oystehr_project.test", "id", fakeoystehr.ProjectID),
+					resource.TestCheckResourceAttr("data.oystehr_project.test", "name", "Acceptance test project"),
+					resource.TestCheckResourceAttr("data.oystehr_project.test", "fhir_version", "r4"),
+					resource.TestCheckResourceAttr("data.oystehr_project.test", "sandbox", "true"),
+					resource.TestCheckResourceAttrPair("data.oystehr_project.test", "default_patient_role_id", "oystehr_role.patient", "id"),
+				),
+			},
+		},
+	})
+}
+
+const testAccProjectConfigResourceConfig = `
+resource "oystehr_role" "patient" {
+  name = "Patient"
+  access_policy = {
+    rule = []
+  }
+}
+
+resource "oystehr_project_configuration" "test" {
+  name                    = "Acceptance test project"
+  description             = "Acceptance test project"
+  signup_enabled          = true
+  default_patient_role_id = oystehr_role.patient.id
+}
+`
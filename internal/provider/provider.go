@@ -2,26 +2,126 @@ package provider
 
 import (
 	"context"
+	"crypto"
+	"fmt"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/function"
 	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/provider"
 	"github.com/hashicorp/terraform-plugin-framework/provider/schema"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
 	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
 	"github.com/masslight/terraform-provider-oystehr/internal/client"
+	"github.com/masslight/terraform-provider-oystehr/internal/credentials"
+	"github.com/masslight/terraform-provider-oystehr/internal/fs"
+	"github.com/masslight/terraform-provider-oystehr/internal/retry"
 )
 
 var _ provider.Provider = &OystehrProvider{}
 
 type OystehrProviderModel struct {
-	ProjectID    types.String `tfsdk:"project_id"`
-	AccessToken  types.String `tfsdk:"access_token"`
-	ClientID     types.String `tfsdk:"client_id"`
-	ClientSecret types.String `tfsdk:"client_secret"`
+	ProjectID        types.String                    `tfsdk:"project_id"`
+	AccessToken      types.String                    `tfsdk:"access_token"`
+	ClientID         types.String                    `tfsdk:"client_id"`
+	ClientSecret     types.String                    `tfsdk:"client_secret"`
+	PrivateKey       types.String                    `tfsdk:"private_key"`
+	PrivateKeyFile   types.String                    `tfsdk:"private_key_file"`
+	KeyID            types.String                    `tfsdk:"key_id"`
+	Profile          types.String                    `tfsdk:"profile"`
+	CredentialsFile  types.String                    `tfsdk:"credentials_file"`
+	Endpoints        *OystehrProviderEndpointsModel  `tfsdk:"endpoints"`
+	MaxRetries       types.Int64                     `tfsdk:"max_retries"`
+	MaxRetryDuration types.String                    `tfsdk:"max_retry_duration"`
+	RequestTimeout   types.String                    `tfsdk:"request_timeout"`
+	CompressRequests types.Bool                      `tfsdk:"compress_requests"`
+	FhirBatchWindow  types.String                    `tfsdk:"fhir_batch_window"`
+	FhirBatchTimeout types.String                    `tfsdk:"fhir_batch_timeout"`
+	RateLimits       *OystehrProviderRateLimitsModel `tfsdk:"rate_limits"`
+}
+
+type OystehrProviderEndpointsModel struct {
+	BaseURL     types.String `tfsdk:"base_url"`
+	Application types.String `tfsdk:"application"`
+	Auth        types.String `tfsdk:"auth"`
+	Fax         types.String `tfsdk:"fax"`
+	Fhir        types.String `tfsdk:"fhir"`
+	IAM         types.String `tfsdk:"iam"`
+	Lab         types.String `tfsdk:"lab"`
+	Project     types.String `tfsdk:"project"`
+	Z3          types.String `tfsdk:"z3"`
+	Zambda      types.String `tfsdk:"zambda"`
+}
+
+func convertEndpointsToClientEndpoints(endpoints *OystehrProviderEndpointsModel) *client.Endpoints {
+	if endpoints == nil {
+		return nil
+	}
+	return &client.Endpoints{
+		Base:        tfStringToStringPointer(endpoints.BaseURL),
+		Application: tfStringToStringPointer(endpoints.Application),
+		Auth:        tfStringToStringPointer(endpoints.Auth),
+		Fax:         tfStringToStringPointer(endpoints.Fax),
+		Fhir:        tfStringToStringPointer(endpoints.Fhir),
+		IAM:         tfStringToStringPointer(endpoints.IAM),
+		Lab:         tfStringToStringPointer(endpoints.Lab),
+		Project:     tfStringToStringPointer(endpoints.Project),
+		Z3:          tfStringToStringPointer(endpoints.Z3),
+		Zambda:      tfStringToStringPointer(endpoints.Zambda),
+	}
+}
+
+type OystehrProviderRateLimitsModel struct {
+	Fhir   *OystehrProviderRateLimitModel `tfsdk:"fhir"`
+	IAM    *OystehrProviderRateLimitModel `tfsdk:"iam"`
+	Z3     *OystehrProviderRateLimitModel `tfsdk:"z3"`
+	Zambda *OystehrProviderRateLimitModel `tfsdk:"zambda"`
+}
+
+type OystehrProviderRateLimitModel struct {
+	RequestsPerSecond types.Float64 `tfsdk:"requests_per_second"`
+	Burst             types.Int64   `tfsdk:"burst"`
+}
+
+func convertRateLimitsToClientRateLimits(rateLimits *OystehrProviderRateLimitsModel) (*client.RateLimits, diag.Diagnostics) {
+	var diags diag.Diagnostics
+	if rateLimits == nil {
+		return nil, diags
+	}
+	convert := func(name string, rateLimit *OystehrProviderRateLimitModel) *client.RateLimit {
+		if rateLimit == nil {
+			return nil
+		}
+		limit := client.DefaultRateLimit
+		if !rateLimit.RequestsPerSecond.IsNull() {
+			limit.RequestsPerSecond = rateLimit.RequestsPerSecond.ValueFloat64()
+			if limit.RequestsPerSecond < 0 {
+				diags.AddAttributeError(path.Root("rate_limits").AtName(name).AtName("requests_per_second"), "Invalid rate limit", "requests_per_second must not be negative")
+			}
+		}
+		if !rateLimit.Burst.IsNull() {
+			limit.Burst = int(rateLimit.Burst.ValueInt64())
+			if limit.Burst < 1 {
+				diags.AddAttributeError(path.Root("rate_limits").AtName(name).AtName("burst"), "Invalid rate limit", "burst must be at least 1")
+			}
+		}
+		return &limit
+	}
+	return &client.RateLimits{
+		Fhir:   convert("fhir", rateLimits.Fhir),
+		IAM:    convert("iam", rateLimits.IAM),
+		Z3:     convert("z3", rateLimits.Z3),
+		Zambda: convert("zambda", rateLimits.Zambda),
+	}, diags
 }
 
 var _ provider.Provider = &OystehrProvider{}
+var _ provider.ProviderWithFunctions = &OystehrProvider{}
 
 type OystehrProvider struct {
 	version string
@@ -36,19 +136,139 @@ func (o *OystehrProvider) Schema(ctx context.Context, req provider.SchemaRequest
 	resp.Schema = schema.Schema{
 		Attributes: map[string]schema.Attribute{
 			"project_id": schema.StringAttribute{
-				MarkdownDescription: "Oystehr project ID",
-				Required:            true,
+				MarkdownDescription: "Oystehr project ID. May also be set with the `OYSTEHR_PROJECT_ID` environment variable or the `project_id` key of the selected credentials file profile",
+				Optional:            true,
 			},
 			"access_token": schema.StringAttribute{
-				MarkdownDescription: "Oystehr developer temporary access token",
+				MarkdownDescription: "Oystehr developer temporary access token. May also be set with the `OYSTEHR_ACCESS_TOKEN` environment variable or the `access_token` key of the selected credentials file profile",
 				Optional:            true,
+				Sensitive:           true,
 			},
 			"client_id": schema.StringAttribute{
-				MarkdownDescription: "Oystehr developer client ID",
+				MarkdownDescription: "Oystehr developer client ID. May also be set with the `OYSTEHR_CLIENT_ID` environment variable or the `client_id` key of the selected credentials file profile",
 				Optional:            true,
 			},
 			"client_secret": schema.StringAttribute{
-				MarkdownDescription: "Oystehr developer client secret",
+				MarkdownDescription: "Oystehr developer client secret. May also be set with the `OYSTEHR_CLIENT_SECRET` environment variable or the `client_secret` key of the selected credentials file profile",
+				Optional:            true,
+				Sensitive:           true,
+			},
+			"private_key": schema.StringAttribute{
+				MarkdownDescription: "PEM encoded RSA or P-256 ECDSA private key of an M2M client, used with `client_id` to authenticate with a signed JWT client assertion instead of `client_secret`. May also be set with the `OYSTEHR_PRIVATE_KEY` environment variable. Conflicts with `private_key_file`",
+				Optional:            true,
+				Sensitive:           true,
+			},
+			"private_key_file": schema.StringAttribute{
+				MarkdownDescription: "Path to a PEM encoded private key, as an alternative to `private_key`. May also be set with the `OYSTEHR_PRIVATE_KEY_FILE` environment variable or the `private_key_file` key of the selected credentials file profile",
+				Optional:            true,
+			},
+			"key_id": schema.StringAttribute{
+				MarkdownDescription: "Key ID (`kid`) of the private key in the M2M client's JWKS. May also be set with the `OYSTEHR_KEY_ID` environment variable or the `key_id` key of the selected credentials file profile",
+				Optional:            true,
+			},
+			"profile": schema.StringAttribute{
+				MarkdownDescription: "Name of the credentials file profile to use. May also be set with the `OYSTEHR_PROFILE` environment variable. Defaults to `default`. Settings in the provider configuration take precedence over environment variables, which take precedence over the profile; the access token and client credentials are always taken together from a single source",
+				Optional:            true,
+			},
+			"credentials_file": schema.StringAttribute{
+				MarkdownDescription: "Path to an INI style credentials file with one `[profile]` section per profile. May also be set with the `OYSTEHR_CREDENTIALS_FILE` environment variable. Defaults to `~/.oystehr/credentials`",
+				Optional:            true,
+			},
+			"endpoints": schema.SingleNestedAttribute{
+				MarkdownDescription: "Overrides for the Oystehr API endpoints, e.g. to target a staging environment or a local mock server",
+				Optional:            true,
+				Attributes: map[string]schema.Attribute{
+					"base_url": schema.StringAttribute{
+						MarkdownDescription: "Base URL for every service without an explicit override. Each service is reached at `<base_url>/<service>`, e.g. `http://localhost:8080/fhir`",
+						Optional:            true,
+					},
+					"application": schema.StringAttribute{
+						MarkdownDescription: "Application API URL. Defaults to `https://app-api.zapehr.com`",
+						Optional:            true,
+					},
+					"auth": schema.StringAttribute{
+						MarkdownDescription: "OAuth server URL used to mint access tokens. Defaults to `https://auth.zapehr.com`",
+						Optional:            true,
+					},
+					"fax": schema.StringAttribute{
+						MarkdownDescription: "Fax API URL. Defaults to `https://fax-api.zapehr.com`",
+						Optional:            true,
+					},
+					"fhir": schema.StringAttribute{
+						MarkdownDescription: "FHIR API URL. Defaults to `https://fhir-api.zapehr.com`",
+						Optional:            true,
+					},
+					"iam": schema.StringAttribute{
+						MarkdownDescription: "IAM API URL, used for roles and M2M clients. Defaults to `https://iam-api.zapehr.com`",
+						Optional:            true,
+					},
+					"lab": schema.StringAttribute{
+						MarkdownDescription: "Labs API URL. Defaults to `https://labs-api.zapehr.com`",
+						Optional:            true,
+					},
+					"project": schema.StringAttribute{
+						MarkdownDescription: "Project API URL. Defaults to `https://project-api.zapehr.com`",
+						Optional:            true,
+					},
+					"z3": schema.StringAttribute{
+						MarkdownDescription: "Z3 API URL. Defaults to `https://z3-api.zapehr.com`",
+						Optional:            true,
+					},
+					"zambda": schema.StringAttribute{
+						MarkdownDescription: "Zambda API URL, used for zambdas and secrets. Defaults to `https://zambda-api.zapehr.com`",
+						Optional:            true,
+					},
+				},
+			},
+			"max_retries": schema.Int64Attribute{
+				MarkdownDescription: "Maximum number of times a throttled (429) or transiently failing (502, 503, 504, connection reset) request is retried. Defaults to 2",
+				Optional:            true,
+			},
+			"max_retry_duration": schema.StringAttribute{
+				MarkdownDescription: "Maximum total time spent retrying a request, as a duration string such as `30s` or `2m`. Defaults to `30s`",
+				Optional:            true,
+			},
+			"request_timeout": schema.StringAttribute{
+				MarkdownDescription: "Timeout for a single API request attempt, as a duration string such as `60s`. Defaults to `60s`",
+				Optional:            true,
+			},
+			"compress_requests": schema.BoolAttribute{
+				MarkdownDescription: "Whether API request bodies of 1 KiB or more, such as large FHIR bundles, are sent gzip compressed with `Content-Encoding: gzip`. Defaults to `false`",
+				Optional:            true,
+			},
+			"fhir_batch_timeout": schema.StringAttribute{
+				MarkdownDescription: "Timeout for sending a FHIR batch bundle, including retries, as a duration string such as `30s`. An operation whose bundle times out fails rather than blocking the run. Defaults to `30s`",
+				Optional:            true,
+			},
+			"fhir_batch_window": schema.StringAttribute{
+				MarkdownDescription: "How long FHIR operations are collected into a single batch bundle before it is sent, as a duration string such as `50ms`. Longer windows send fewer, larger bundles at the cost of latency. Defaults to `50ms`",
+				Optional:            true,
+			},
+			"rate_limits": schema.SingleNestedAttribute{
+				MarkdownDescription: "Client-side rate limits, shared by every resource and data source using the same service. Requests beyond the limit wait rather than being rejected by the platform. Each service defaults to 10 requests per second with bursts of 10",
+				Optional:            true,
+				Attributes: map[string]schema.Attribute{
+					"fhir":   rateLimitAttribute("FHIR API"),
+					"iam":    rateLimitAttribute("IAM API, used for roles and M2M clients"),
+					"z3":     rateLimitAttribute("Z3 API"),
+					"zambda": rateLimitAttribute("Zambda API, used for zambdas and secrets"),
+				},
+			},
+		},
+	}
+}
+
+func rateLimitAttribute(service string) schema.SingleNestedAttribute {
+	return schema.SingleNestedAttribute{
+		MarkdownDescription: fmt.Sprintf("Rate limit for the %s", service),
+		Optional:            true,
+		Attributes: map[string]schema.Attribute{
+			"requests_per_second": schema.Float64Attribute{
+				MarkdownDescription: "Average number of requests per second. Set to 0 to disable limiting. Defaults to 10",
+				Optional:            true,
+			},
+			"burst": schema.Int64Attribute{
+				MarkdownDescription: "Number of requests that may be sent at once before the average rate applies. Defaults to 10",
 				Optional:            true,
 			},
 		},
@@ -61,34 +281,160 @@ func (o *OystehrProvider) Configure(ctx context.Context, req provider.ConfigureR
 	if resp.Diagnostics.HasError() {
 		return
 	}
-	hasAccessToken := false
-	hasClientCreds := false
-	if !data.AccessToken.IsNull() {
-		hasAccessToken = true
+	resolved, diags := resolveCredentials(ctx, data, os.Getenv)
+	resp.Diagnostics.Append(diags...)
+	endpoints := convertEndpointsToClientEndpoints(data.Endpoints)
+	if err := endpoints.Validate(); err != nil {
+		resp.Diagnostics.AddAttributeError(path.Root("endpoints"), "Invalid endpoint", err.Error())
 	}
-	if !data.ClientID.IsNull() && !data.ClientSecret.IsNull() {
-		hasClientCreds = true
+	retryConfig := retry.DefaultRetryConfig
+	if !data.MaxRetries.IsNull() {
+		if data.MaxRetries.ValueInt64() < 0 {
+			resp.Diagnostics.AddAttributeError(path.Root("max_retries"), "Invalid max retries", "max_retries must not be negative")
+		}
+		retryConfig.MaxAttempts = int(data.MaxRetries.ValueInt64()) + 1
+	}
+	if !data.MaxRetryDuration.IsNull() {
+		maxRetryDuration, err := time.ParseDuration(data.MaxRetryDuration.ValueString())
+		// The retry package treats a duration that is not positive as no limit
+		if err != nil || maxRetryDuration <= 0 {
+			resp.Diagnostics.AddAttributeError(path.Root("max_retry_duration"), "Invalid max retry duration", "max_retry_duration must be a positive duration such as 30s")
+		}
+		retryConfig.MaxDuration = maxRetryDuration
+	}
+	var requestTimeout *time.Duration
+	if !data.RequestTimeout.IsNull() {
+		timeout, err := time.ParseDuration(data.RequestTimeout.ValueString())
+		if err != nil || timeout <= 0 {
+			resp.Diagnostics.AddAttributeError(path.Root("request_timeout"), "Invalid request timeout", "request_timeout must be a positive duration such as 60s")
+		}
+		requestTimeout = &timeout
 	}
-	// Either neither present or both present
-	if hasAccessToken == hasClientCreds {
-		resp.Diagnostics.AddAttributeError(path.Root("access_token"), "Misconfigured credentials", "Either access token or client ID and client secret must be known")
+	var fhirBatchWindow *time.Duration
+	if !data.FhirBatchWindow.IsNull() {
+		window, err := time.ParseDuration(data.FhirBatchWindow.ValueString())
+		if err != nil || window < 0 {
+			resp.Diagnostics.AddAttributeError(path.Root("fhir_batch_window"), "Invalid FHIR batch window", "fhir_batch_window must be a non-negative duration such as 50ms")
+		}
+		fhirBatchWindow = &window
+	}
+	var fhirBatchTimeout *time.Duration
+	if !data.FhirBatchTimeout.IsNull() {
+		timeout, err := time.ParseDuration(data.FhirBatchTimeout.ValueString())
+		if err != nil || timeout <= 0 {
+			resp.Diagnostics.AddAttributeError(path.Root("fhir_batch_timeout"), "Invalid FHIR batch timeout", "fhir_batch_timeout must be a positive duration such as 30s")
+		}
+		fhirBatchTimeout = &timeout
+	}
+	rateLimits, rateLimitDiags := convertRateLimitsToClientRateLimits(data.RateLimits)
+	resp.Diagnostics.Append(rateLimitDiags...)
+	var privateKey crypto.Signer
+	if !diags.HasError() {
+		var err error
+		privateKey, err = loadPrivateKey(resolved)
+		if err != nil {
+			resp.Diagnostics.AddAttributeError(path.Root("private_key"), "Invalid private key", err.Error())
+		}
 	}
 	if resp.Diagnostics.HasError() {
 		return
 	}
 
 	client := client.New(&client.ClientConfig{
-		ProjectID:    data.ProjectID.ValueStringPointer(),
-		AccessToken:  data.AccessToken.ValueStringPointer(),
-		ClientID:     data.ClientID.ValueStringPointer(),
-		ClientSecret: data.ClientSecret.ValueStringPointer(),
+		ProjectID:        stringToStringPointer(resolved.ProjectID),
+		AccessToken:      stringToStringPointer(resolved.AccessToken),
+		ClientID:         stringToStringPointer(resolved.ClientID),
+		ClientSecret:     stringToStringPointer(resolved.ClientSecret),
+		PrivateKey:       privateKey,
+		KeyID:            stringToStringPointer(resolved.KeyID),
+		Endpoints:        endpoints,
+		Retry:            &retryConfig,
+		RequestTimeout:   requestTimeout,
+		RateLimits:       rateLimits,
+		FhirBatchWindow:  fhirBatchWindow,
+		FhirBatchTimeout: fhirBatchTimeout,
+		CompressRequests: data.CompressRequests.ValueBool(),
 	})
 	resp.DataSourceData = client
 	resp.ResourceData = client
 }
 
+// resolveCredentials merges the provider configuration, the environment and the
+// credentials file profile, in that order of precedence.
+func resolveCredentials(ctx context.Context, data OystehrProviderModel, getenv func(string) string) (credentials.Resolved, diag.Diagnostics) {
+	var diags diag.Diagnostics
+
+	profile, profileRequired := credentials.DefaultProfile, false
+	if !data.Profile.IsNull() {
+		profile, profileRequired = data.Profile.ValueString(), true
+	} else if v := getenv(credentials.EnvProfile); v != "" {
+		profile, profileRequired = v, true
+	}
+	credentialsFile, fileRequired := credentials.DefaultFile, false
+	if !data.CredentialsFile.IsNull() {
+		credentialsFile, fileRequired = data.CredentialsFile.ValueString(), true
+	} else if v := getenv(credentials.EnvCredentialsFile); v != "" {
+		credentialsFile, fileRequired = v, true
+	}
+	profileSource, err := credentials.LoadProfile(credentialsFile, profile, profileRequired || fileRequired)
+	if err != nil {
+		diags.AddAttributeError(path.Root("profile"), "Invalid credentials profile", err.Error())
+		return credentials.Resolved{}, diags
+	}
+
+	configSource := credentials.Source{
+		Name: "provider configuration",
+		Credentials: credentials.Credentials{
+			ProjectID:      data.ProjectID.ValueString(),
+			AccessToken:    data.AccessToken.ValueString(),
+			ClientID:       data.ClientID.ValueString(),
+			ClientSecret:   data.ClientSecret.ValueString(),
+			PrivateKey:     data.PrivateKey.ValueString(),
+			PrivateKeyFile: data.PrivateKeyFile.ValueString(),
+			KeyID:          data.KeyID.ValueString(),
+		},
+	}
+	resolved, err := credentials.Resolve(configSource, credentials.FromEnvironment(getenv), profileSource)
+	if err != nil {
+		attribute := path.Root("access_token")
+		if resolved.ProjectID == "" {
+			attribute = path.Root("project_id")
+		}
+		diags.AddAttributeError(attribute, "Misconfigured credentials", err.Error())
+		return resolved, diags
+	}
+	if len(resolved.Ignored) > 0 {
+		diags.AddWarning(
+			"Ignoring lower precedence credentials",
+			fmt.Sprintf("Using credentials from %s. Credentials in %s are ignored.", resolved.AuthSource, strings.Join(resolved.Ignored, ", ")),
+		)
+	}
+	tflog.Info(ctx, "Resolved Oystehr credentials", map[string]any{
+		"project_id_source":  resolved.ProjectIDSource,
+		"credentials_source": resolved.AuthSource,
+	})
+	return resolved, diags
+}
+
+func loadPrivateKey(resolved credentials.Resolved) (crypto.Signer, error) {
+	pemData := []byte(resolved.PrivateKey)
+	if resolved.PrivateKeyFile != "" {
+		data, err := os.ReadFile(fs.CleanPath(resolved.PrivateKeyFile))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read private key file: %w", err)
+		}
+		pemData = data
+	}
+	if len(pemData) == 0 {
+		return nil, nil
+	}
+	return client.ParsePrivateKey(pemData)
+}
+
 func (o *OystehrProvider) DataSources(ctx context.Context) []func() datasource.DataSource {
 	return []func() datasource.DataSource{
+		NewFhirResourceDataSource,
+		NewFhirSearchDataSource,
 		NewProjectDataSource,
 	}
 }
@@ -97,7 +443,10 @@ func (o *OystehrProvider) Resources(ctx context.Context) []func() resource.Resou
 	return []func() resource.Resource{
 		NewApplicationResource,
 		NewFaxNumberResource,
+		NewFhirBundleResource,
 		NewFhirResource,
+		NewFhirResourcesResource,
+		NewFhirTerminologyResource,
 		NewLabRouteResource,
 		NewM2MResource,
 		NewProjectConfigResource,
@@ -109,6 +458,12 @@ func (o *OystehrProvider) Resources(ctx context.Context) []func() resource.Resou
 	}
 }
 
+func (o *OystehrProvider) Functions(ctx context.Context) []func() function.Function {
+	return []func() function.Function{
+		NewFhirReferenceFunction,
+	}
+}
+
 func New(version string) func() provider.Provider {
 	return func() provider.Provider {
 		return &OystehrProvider{
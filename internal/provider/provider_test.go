@@ -0,0 +1,125 @@
+package provider
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/providerserver"
+	"github.com/hashicorp/terraform-plugin-go/tfprotov6"
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/terraform"
+	"github.com/stretchr/testify/require"
+
+	"github.com/masslight/terraform-provider-oystehr/internal/testing/fakeoystehr"
+)
+
+// testAccProtoV6ProviderFactories serves the provider in-process to the
+// Terraform CLI run by acceptance tests.
+var testAccProtoV6ProviderFactories = map[string]func() (tfprotov6.ProviderServer, error){
+	"oystehr": providerserver.NewProtocol6WithError(New("test")()),
+}
+
+// newTestAccServer starts a fake Oystehr API for an acceptance test. Tests
+// prefix their configuration with the server's ProviderConfig, so they run
+// fully offline.
+func newTestAccServer(t *testing.T) *fakeoystehr.Server {
+	t.Helper()
+	// Keep the developer's own credentials out of the tests
+	for _, env := range []string{"OYSTEHR_ACCESS_TOKEN", "OYSTEHR_PRIVATE_KEY", "OYSTEHR_PRIVATE_KEY_FILE", "OYSTEHR_KEY_ID", "OYSTEHR_PROFILE"} {
+		t.Setenv(env, "")
+	}
+	t.Setenv("OYSTEHR_CREDENTIALS_FILE", writeTestFile(t, "credentials", "[default]\n"))
+
+	server := fakeoystehr.New()
+	t.Cleanup(server.Close)
+	return server
+}
+
+// writeTestFile writes a file for a resource source attribute and returns its
+// path.
+func writeTestFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+// testAccCheckDestroyed checks that the fake no longer holds the objects of
+// the resources of a type, looked up by the given state attribute.
+func testAccCheckDestroyed(server *fakeoystehr.Server, resourceType, collection, attribute string) func(*terraform.State) error {
+	return func(s *terraform.State) error {
+		for _, rs := range s.RootModule().Resources {
+			if rs.Type != resourceType {
+				continue
+			}
+			id := rs.Primary.Attributes[attribute]
+			if _, ok := server.Object(collection, id); ok {
+				return fmt.Errorf("%s %s still exists", resourceType, id)
+			}
+		}
+		return nil
+	}
+}
+
+func TestAccProviderMaxRetryDuration(t *testing.T) {
+	server := newTestAccServer(t)
+	config := func(duration string) string {
+		return strings.Replace(server.ProviderConfig(), "endpoints = {", fmt.Sprintf("max_retry_duration = %q\n  endpoints = {", duration), 1) + `
+data "oystehr_fhir_resource" "organization" {
+  type = "Organization"
+  id   = "1"
+}
+`
+	}
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config:      config("0s"),
+				ExpectError: regexp.MustCompile(`max_retry_duration\s+must\s+be\s+a\s+positive\s+duration`),
+			},
+			{
+				Config:      config("-5s"),
+				ExpectError: regexp.MustCompile(`max_retry_duration\s+must\s+be\s+a\s+positive\s+duration`),
+			},
+		},
+	})
+}
+
+func TestAccProviderCompressRequests(t *testing.T) {
+	server := newTestAccServer(t)
+	config := strings.Replace(server.ProviderConfig(), "endpoints = {", "compress_requests = true\n  endpoints = {", 1) + fmt.Sprintf(`
+resource "oystehr_fhir_resource" "organization" {
+  type = "Organization"
+  data = {
+    resourceType = "Organization"
+    name         = %q
+  }
+}
+`, strings.Repeat("Clinic ", 200))
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: config,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("oystehr_fhir_resource.organization", "data.name", strings.Repeat("Clinic ", 200)),
+					func(*terraform.State) error {
+						for _, request := range server.Requests() {
+							if request.Path == "/fhir" && request.Header.Get("Content-Encoding") == "gzip" {
+								return nil
+							}
+						}
+						return fmt.Errorf("no FHIR bundle was sent compressed")
+					},
+				),
+			},
+		},
+	})
+}
@@ -2,7 +2,6 @@ package provider
 
 import (
 	"context"
-	"strings"
 
 	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
@@ -151,7 +150,7 @@ func (r *RoleResource) Read(ctx context.Context, req resource.ReadRequest, resp
 
 	role, err := r.client.Role.GetRole(ctx, id)
 	if err != nil {
-		if strings.Contains(err.Error(), "unexpected status code: 404") {
+		if client.IsNotFound(err) {
 			resp.State.RemoveResource(ctx)
 			return
 		}
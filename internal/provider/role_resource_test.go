@@ -0,0 +1,58 @@
+package provider
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+
+	"github.com/masslight/terraform-provider-oystehr/internal/testing/fakeoystehr"
+)
+
+func TestAccRoleResource(t *testing.T) {
+	server := newTestAccServer(t)
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		CheckDestroy:             testAccCheckDestroyed(server, "oystehr_role", fakeoystehr.CollectionRoles, "id"),
+		Steps: []resource.TestStep{
+			{
+				Config: server.ProviderConfig() + testAccRoleResourceConfig("Read patients", "Patient:*"),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttrSet("oystehr_role.test", "id"),
+					resource.TestCheckResourceAttr("oystehr_role.test", "name", "Read patients"),
+					resource.TestCheckResourceAttr("oystehr_role.test", "access_policy.rule.0.resource.0", "FHIR:Patient:*"),
+				),
+			},
+			{
+				ResourceName:      "oystehr_role.test",
+				ImportState:       true,
+				ImportStateVerify: true,
+			},
+			{
+				Config: server.ProviderConfig() + testAccRoleResourceConfig("Read observations", "Observation:*"),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("oystehr_role.test", "name", "Read observations"),
+					resource.TestCheckResourceAttr("oystehr_role.test", "access_policy.rule.0.resource.0", "FHIR:Observation:*"),
+				),
+			},
+		},
+	})
+}
+
+func testAccRoleResourceConfig(name, resource string) string {
+	return `
+resource "oystehr_role" "test" {
+  name        = "` + name + `"
+  description = "Acceptance test role"
+  access_policy = {
+    rule = [
+      {
+        resource = ["FHIR:` + resource + `"]
+        action   = ["FHIR:Read"]
+        effect   = "Allow"
+      },
+    ]
+  }
+}
+`
+}
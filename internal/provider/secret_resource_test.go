@@ -0,0 +1,44 @@
+package provider
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+
+	"github.com/masslight/terraform-provider-oystehr/internal/testing/fakeoystehr"
+)
+
+func TestAccSecretResource(t *testing.T) {
+	server := newTestAccServer(t)
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		CheckDestroy:             testAccCheckDestroyed(server, "oystehr_secret", fakeoystehr.CollectionSecrets, "name"),
+		Steps: []resource.TestStep{
+			{
+				Config: server.ProviderConfig() + testAccSecretResourceConfig("first"),
+				Check:  resource.TestCheckResourceAttr("oystehr_secret.test", "value", "first"),
+			},
+			{
+				ResourceName:                         "oystehr_secret.test",
+				ImportState:                          true,
+				ImportStateId:                        "API_KEY",
+				ImportStateVerify:                    true,
+				ImportStateVerifyIdentifierAttribute: "name",
+			},
+			{
+				Config: server.ProviderConfig() + testAccSecretResourceConfig("second"),
+				Check:  resource.TestCheckResourceAttr("oystehr_secret.test", "value", "second"),
+			},
+		},
+	})
+}
+
+func testAccSecretResourceConfig(value string) string {
+	return `
+resource "oystehr_secret" "test" {
+  name  = "API_KEY"
+  value = "` + value + `"
+}
+`
+}
@@ -1,8 +1,12 @@
 package provider
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"math/big"
 
 	"github.com/hashicorp/terraform-plugin-framework/attr"
@@ -73,6 +77,13 @@ func tfStringToStringPointer(value types.String) *string {
 	return &val
 }
 
+func stringToStringPointer(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
 func tfBoolToBoolPointer(value types.Bool) *bool {
 	if value.IsNull() || value.IsUnknown() {
 		return nil
@@ -169,92 +180,62 @@ func getStringFromValue(m attr.Value) string {
 	}
 }
 
+// valueToTerraformValue converts decoded JSON to a Terraform value, typed the
+// way Terraform types the same JSON written in configuration, so that state
+// compares equal to it. Arrays become tuples rather than lists, since the
+// elements of a FHIR array are objects with differing optional fields, and
+// the type of each element is only its own. null becomes a dynamic null, as
+// in the primitive extension arrays of FHIR, e.g. "_given": [null, {...}].
 func valueToTerraformValue(ctx context.Context, v any) (attr.Type, attr.Value, diag.Diagnostics) {
-	if v == nil {
-		return nil, nil, nil
-	}
-
 	var value attr.Value
-	var ty attr.Type
 	switch val := v.(type) {
+	case nil:
+		value = types.DynamicNull()
 	case string:
 		value = types.StringValue(val)
-		ty = types.StringType
 	case bool:
 		value = types.BoolValue(val)
-		ty = types.BoolType
 	case int64:
 		value = types.Int64Value(val)
-		ty = types.Int64Type
 	case float64:
 		value = types.Float64Value(val)
-		ty = types.Float64Type
+	case json.Number:
+		bf, err := parseNumber(val)
+		if err != nil {
+			diags := diag.NewErrorDiagnostic("Invalid number", fmt.Sprintf("invalid number %q: %s", val, err))
+			return nil, nil, diag.Diagnostics{diags}
+		}
+		value = types.NumberValue(bf)
 	case map[string]any:
-		tflog.Debug(ctx, "Converting nested map to Terraform object", map[string]any{
-			"map": val,
-		})
 		nestedObject, diags := mapToTerraformObject(ctx, val)
 		if diags.HasError() {
 			return nil, nil, diags
 		}
 		value = nestedObject
-		ty = types.ObjectType{
-			AttrTypes: nestedObject.AttributeTypes(ctx),
-		}
 	case []any:
-		tflog.Debug(ctx, "Converting slice to Terraform list", map[string]any{
-			"slice": val,
+		tflog.Debug(ctx, "Converting slice to Terraform tuple", map[string]any{
+			"length": len(val),
 		})
+		elementTypes := make([]attr.Type, len(val))
 		elements := make([]attr.Value, len(val))
-		var nestedType attr.Type
-		var nestedTypes []attr.Type
 		for i, elem := range val {
 			nt, nv, diags := valueToTerraformValue(ctx, elem)
 			if diags.HasError() {
 				return nil, nil, diags
 			}
+			elementTypes[i] = nt
 			elements[i] = nv
-			if nestedType == nt {
-				// still the same type
-				nestedType = nt
-			} else {
-				// now we need a tuple
-				if nestedTypes == nil {
-					nestedTypes = make([]attr.Type, len(val))
-					for j := 0; j < i; j++ {
-						nestedTypes[j] = nestedType
-					}
-				}
-				nestedTypes[i] = nt
-				nestedType = nil
-			}
 		}
-		if nestedType == nil {
-			// we have a tuple of types
-			tv, diags := types.TupleValue(nestedTypes, elements)
-			if diags.HasError() {
-				return nil, nil, diags
-			}
-			value = tv
-			ty = types.TupleType{
-				ElemTypes: nestedTypes,
-			}
-		} else {
-			// we have a single type
-			lv, diags := types.ListValue(nestedType, elements)
-			if diags.HasError() {
-				return nil, nil, diags
-			}
-			value = lv
-			ty = types.ListType{
-				ElemType: nestedType,
-			}
+		tuple, diags := types.TupleValue(elementTypes, elements)
+		if diags.HasError() {
+			return nil, nil, diags
 		}
+		value = tuple
 	default:
 		diags := diag.NewErrorDiagnostic("Unsupported type", fmt.Sprintf("unsupported type for attr %+v: %T", val, v))
 		return nil, nil, diag.Diagnostics{diags}
 	}
-	return ty, value, nil
+	return value.Type(ctx), value, nil
 }
 
 func mapToTerraformObject(ctx context.Context, m map[string]any) (types.Object, diag.Diagnostics) {
@@ -293,16 +274,9 @@ func terraformValueToValue(ctx context.Context, v attr.Value) (any, diag.Diagnos
 	case types.Float64:
 		return val.ValueFloat64(), nil
 	case types.Number:
-		bf := val.ValueBigFloat()
-		if bf.IsInt() {
-			bfi, acc := bf.Int64()
-			if acc == big.Exact {
-				return bfi, nil
-			}
-			//fallthrough
-		}
-		bff, _ := bf.Float64()
-		return bff, nil
+		return formatNumber(val.ValueBigFloat()), nil
+	case types.Dynamic:
+		return terraformValueToValue(ctx, val.UnderlyingValue())
 	case types.Object:
 		m := make(map[string]any, len(val.Attributes()))
 		for k, elem := range val.Attributes() {
@@ -333,6 +307,26 @@ func terraformValueToValue(ctx context.Context, v attr.Value) (any, diag.Diagnos
 			slice[i] = nv
 		}
 		return slice, nil
+	case types.Set:
+		slice := make([]any, len(val.Elements()))
+		for i, elem := range val.Elements() {
+			nv, diags := terraformValueToValue(ctx, elem)
+			if diags.HasError() {
+				return nil, diags
+			}
+			slice[i] = nv
+		}
+		return slice, nil
+	case types.Map:
+		m := make(map[string]any, len(val.Elements()))
+		for k, elem := range val.Elements() {
+			nv, diags := terraformValueToValue(ctx, elem)
+			if diags.HasError() {
+				return nil, diags
+			}
+			m[k] = nv
+		}
+		return m, nil
 	default:
 		return nil, diag.Diagnostics{diag.NewErrorDiagnostic("Unsupported type", fmt.Sprintf("unsupported type for attr %+v: %T", val, v))}
 	}
@@ -353,3 +347,36 @@ func terraformObjectToMap(ctx context.Context, obj types.Object) (map[string]any
 	}
 	return m, nil
 }
+
+// decodeJSON decodes FHIR data with numbers as json.Number, as the client
+// decodes responses, so decimals and large integers are read exactly.
+func decodeJSON(data []byte, v any) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(v); err != nil {
+		return err
+	}
+	if _, err := decoder.Token(); !errors.Is(err, io.EOF) {
+		return errors.New("invalid data after top-level value")
+	}
+	return nil
+}
+
+// numberPrecision is the precision of the numbers Terraform itself parses
+// from configuration, so numbers from the API compare equal to the same
+// numbers written in configuration.
+const numberPrecision = 512
+
+// parseNumber parses a JSON number exactly, unlike a float64, which rounds
+// decimals and integers beyond 2^53.
+func parseNumber(n json.Number) (*big.Float, error) {
+	bf, _, err := big.ParseFloat(n.String(), 10, numberPrecision, big.ToNearestEven)
+	return bf, err
+}
+
+// formatNumber returns the shortest JSON number that parses back to the same
+// value, without an exponent. Terraform numbers have no notion of trailing
+// zeros, so a decimal such as 1.50 is sent as 1.5.
+func formatNumber(bf *big.Float) json.Number {
+	return json.Number(bf.Text('f', -1))
+}
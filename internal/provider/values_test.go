@@ -0,0 +1,298 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"testing/quick"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// decodeFhirJSON decodes JSON the way the client decodes FHIR responses.
+func decodeFhirJSON(t *testing.T, data string) map[string]any {
+	decoder := json.NewDecoder(strings.NewReader(data))
+	decoder.UseNumber()
+	var resource map[string]any
+	require.NoError(t, decoder.Decode(&resource))
+	return resource
+}
+
+func TestNumberRoundTrip(t *testing.T) {
+	tt := []struct {
+		name     string
+		number   string
+		expected string
+	}{
+		{name: "integer", number: "42", expected: "42"},
+		{name: "negative", number: "-7", expected: "-7"},
+		{name: "large integer", number: "12345678901234567890", expected: "12345678901234567890"},
+		{name: "decimal", number: "98.6", expected: "98.6"},
+		{name: "trailing zeros", number: "1.50", expected: "1.5"},
+		{name: "small decimal", number: "0.000001", expected: "0.000001"},
+		{name: "many digits", number: "3.14159265358979323846264338327950288", expected: "3.14159265358979323846264338327950288"},
+		{name: "exponent", number: "1e21", expected: "1000000000000000000000"},
+		{name: "negative exponent", number: "2.5E-3", expected: "0.0025"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			ty, value, diags := valueToTerraformValue(t.Context(), json.Number(tc.number))
+			require.False(t, diags.HasError())
+			assert.Equal(t, types.NumberType, ty)
+
+			converted, diags := terraformValueToValue(t.Context(), value)
+			require.False(t, diags.HasError())
+			assert.Equal(t, json.Number(tc.expected), converted)
+		})
+	}
+}
+
+// TestNumberRoundTripProperty checks that any decimal the server returns is
+// kept exactly: the state holds the same value, and converting the value sent
+// back to the server reproduces the same state, so no diff is planned.
+func TestNumberRoundTripProperty(t *testing.T) {
+	property := func(negative bool, integer uint64, leadingZeros uint8, fraction uint32, trailingZeros uint8) bool {
+		digits := fmt.Sprintf("%s%d", strings.Repeat("0", int(leadingZeros%20)), fraction)
+		number := fmt.Sprintf("%d.%s%s", integer, digits, strings.Repeat("0", int(trailingZeros%5)))
+
+		// The shortest form of the same decimal
+		expected := fmt.Sprint(integer)
+		if digits := strings.TrimRight(digits, "0"); digits != "" {
+			expected += "." + digits
+		}
+		if negative {
+			number = "-" + number
+			expected = "-" + expected
+		}
+
+		resource := decodeFhirJSON(t, fmt.Sprintf(`{"valueQuantity": {"value": %s}}`, number))
+		state, diags := mapToTerraformObject(t.Context(), resource)
+		if diags.HasError() {
+			return false
+		}
+		data, diags := terraformObjectToMap(t.Context(), state)
+		if diags.HasError() {
+			return false
+		}
+		encoded, err := json.Marshal(data)
+		if err != nil || !bytes.Equal(encoded, []byte(fmt.Sprintf(`{"valueQuantity":{"value":%s}}`, expected))) {
+			t.Logf("%s: got %s", number, encoded)
+			return false
+		}
+
+		// The server echoes what was sent
+		echoed, diags := mapToTerraformObject(t.Context(), decodeFhirJSON(t, string(encoded)))
+		return !diags.HasError() && echoed.Equal(state)
+	}
+	require.NoError(t, quick.Check(property, &quick.Config{MaxCount: 1000}))
+}
+
+const testPatientJSON = `{
+  "resourceType": "Patient",
+  "id": "example",
+  "identifier": [
+    {
+      "use": "usual",
+      "type": {"coding": [{"system": "http://terminology.hl7.org/CodeSystem/v2-0203", "code": "MR"}]},
+      "system": "urn:oid:1.2.36.146.595.217.0.1",
+      "value": "12345",
+      "period": {"start": "2001-05-06"},
+      "assigner": {"display": "Acme Healthcare"}
+    }
+  ],
+  "active": true,
+  "name": [
+    {"use": "official", "family": "Chalmers", "given": ["Peter", "James"], "_given": [null, {"extension": [{"url": "http://hl7.org/fhir/StructureDefinition/iso21090-EN-qualifier", "valueCode": "MID"}]}]},
+    {"use": "usual", "given": ["Jim"]},
+    {"use": "maiden", "family": "Windsor", "given": ["Peter", "James"], "period": {"end": "2002"}}
+  ],
+  "telecom": [
+    {"use": "home"},
+    {"system": "phone", "value": "(03) 5555 6473", "use": "work", "rank": 1},
+    {"system": "phone", "value": "(03) 3410 5613", "use": "mobile", "rank": 2},
+    {"system": "phone", "value": "(03) 5555 8834", "use": "old", "period": {"end": "2014"}}
+  ],
+  "gender": "male",
+  "birthDate": "1974-12-25",
+  "_birthDate": {"extension": [{"url": "http://hl7.org/fhir/StructureDefinition/patient-birthTime", "valueDateTime": "1974-12-25T14:35:45-05:00"}]},
+  "deceasedBoolean": false,
+  "address": [
+    {"use": "home", "type": "both", "text": "534 Erewhon St PeasantVille, Rainbow, Vic  3999", "line": ["534 Erewhon St"], "city": "PleasantVille", "postalCode": "3999", "period": {"start": "1974-12-25"}}
+  ],
+  "contact": [
+    {
+      "relationship": [{"coding": [{"system": "http://terminology.hl7.org/CodeSystem/v2-0131", "code": "N"}]}],
+      "name": {"family": "du Marché", "given": ["Bénédicte"]},
+      "telecom": [{"system": "phone", "value": "+33 (237) 998327"}],
+      "gender": "female"
+    }
+  ],
+  "managingOrganization": {"reference": "Organization/1"}
+}`
+
+const testQuestionnaireJSON = `{
+  "resourceType": "Questionnaire",
+  "id": "intake",
+  "url": "https://example.com/fhir/Questionnaire/intake",
+  "status": "active",
+  "item": [
+    {
+      "linkId": "1",
+      "text": "Do you smoke?",
+      "type": "choice",
+      "required": true,
+      "answerOption": [
+        {"valueCoding": {"system": "http://snomed.info/sct", "code": "373066001", "display": "Yes"}},
+        {"valueCoding": {"system": "http://snomed.info/sct", "code": "373067005", "display": "No"}, "initialSelected": true},
+        {"valueString": "Prefer not to say"}
+      ]
+    },
+    {
+      "linkId": "2",
+      "text": "Packs per day",
+      "type": "decimal",
+      "enableWhen": [{"question": "1", "operator": "=", "answerCoding": {"system": "http://snomed.info/sct", "code": "373066001"}}],
+      "initial": [{"valueDecimal": 0.5}],
+      "extension": [
+        {"url": "http://hl7.org/fhir/StructureDefinition/minValue", "valueDecimal": 0},
+        {"url": "http://hl7.org/fhir/StructureDefinition/questionnaire-unit", "valueCoding": {"code": "{packs}/d"}}
+      ]
+    },
+    {
+      "linkId": "3",
+      "type": "group",
+      "item": [
+        {"linkId": "3.1", "text": "Notes", "type": "text", "maxLength": 500},
+        {"linkId": "3.2", "type": "display", "text": "Thank you"}
+      ]
+    }
+  ]
+}`
+
+const testBundleJSON = `{
+  "resourceType": "Bundle",
+  "type": "transaction",
+  "entry": [
+    {
+      "fullUrl": "urn:uuid:61ebe359-bfdc-4613-8bf2-c5e300945f0a",
+      "resource": {"resourceType": "Patient", "active": true, "name": [{"family": "Smith"}]},
+      "request": {"method": "POST", "url": "Patient"}
+    },
+    {
+      "fullUrl": "urn:uuid:88f151c0-a954-468a-88bd-5ae15c08e059",
+      "resource": {
+        "resourceType": "Observation",
+        "status": "final",
+        "code": {"coding": [{"system": "http://loinc.org", "code": "15074-8"}]},
+        "subject": {"reference": "urn:uuid:61ebe359-bfdc-4613-8bf2-c5e300945f0a"},
+        "valueQuantity": {"value": 6.3, "unit": "mmol/l", "system": "http://unitsofmeasure.org", "code": "mmol/L"},
+        "referenceRange": [{"low": {"value": 3.1}, "high": {"value": 6.2}}]
+      },
+      "request": {"method": "POST", "url": "Observation"}
+    },
+    {
+      "request": {"method": "DELETE", "url": "Patient/old"}
+    }
+  ]
+}`
+
+func TestFhirRoundTrip(t *testing.T) {
+	tt := []struct {
+		name     string
+		resource string
+	}{
+		{name: "patient", resource: testPatientJSON},
+		{name: "questionnaire", resource: testQuestionnaireJSON},
+		{name: "bundle", resource: testBundleJSON},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			state, diags := mapToTerraformObject(t.Context(), decodeFhirJSON(t, tc.resource))
+			require.False(t, diags.HasError(), diags)
+
+			data, diags := terraformObjectToMap(t.Context(), state)
+			require.False(t, diags.HasError(), diags)
+			encoded, err := json.Marshal(data)
+			require.NoError(t, err)
+			assert.JSONEq(t, tc.resource, string(encoded))
+
+			// Sending the state and reading it back is no change
+			echoed, diags := mapToTerraformObject(t.Context(), decodeFhirJSON(t, string(encoded)))
+			require.False(t, diags.HasError(), diags)
+			assert.True(t, echoed.Equal(state))
+		})
+	}
+}
+
+func TestHeterogeneousArrays(t *testing.T) {
+	patient := decodeFhirJSON(t, testPatientJSON)
+	state, diags := mapToTerraformObject(t.Context(), patient)
+	require.False(t, diags.HasError(), diags)
+
+	// Elements with differing fields and nulls are kept as they are
+	telecom, ok := state.Attributes()["telecom"].(types.Tuple)
+	require.True(t, ok)
+	assert.Len(t, telecom.Elements(), 4)
+	name := state.Attributes()["name"].(types.Tuple).Elements()[0].(types.Object)
+	given := name.Attributes()["_given"].(types.Tuple).Elements()
+	assert.Equal(t, types.DynamicNull(), given[0])
+	assert.IsType(t, types.Object{}, given[1])
+
+	// An element gaining a field changes only the type of that element
+	patient["telecom"].([]any)[0].(map[string]any)["rank"] = json.Number("3")
+	changed, diags := mapToTerraformObject(t.Context(), patient)
+	require.False(t, diags.HasError(), diags)
+	changedTelecom := changed.Attributes()["telecom"].(types.Tuple)
+	elementTypes, changedElementTypes := telecom.ElementTypes(t.Context()), changedTelecom.ElementTypes(t.Context())
+	assert.False(t, elementTypes[0].Equal(changedElementTypes[0]))
+	for i := 1; i < len(elementTypes); i++ {
+		assert.True(t, elementTypes[i].Equal(changedElementTypes[i]))
+	}
+}
+
+func TestTerraformValueToValue(t *testing.T) {
+	tt := []struct {
+		name     string
+		value    attr.Value
+		expected any
+	}{
+		{name: "null", value: types.StringNull(), expected: nil},
+		{name: "dynamic", value: types.DynamicValue(types.StringValue("a")), expected: "a"},
+		{name: "dynamic null", value: types.DynamicNull(), expected: nil},
+		{
+			name:     "tuple with null",
+			value:    types.TupleValueMust([]attr.Type{types.DynamicType, types.StringType}, []attr.Value{types.DynamicNull(), types.StringValue("a")}),
+			expected: []any{nil, "a"},
+		},
+		{
+			name:     "list",
+			value:    types.ListValueMust(types.StringType, []attr.Value{types.StringValue("a"), types.StringValue("b")}),
+			expected: []any{"a", "b"},
+		},
+		{
+			name:     "set",
+			value:    types.SetValueMust(types.StringType, []attr.Value{types.StringValue("a")}),
+			expected: []any{"a"},
+		},
+		{
+			name:     "map",
+			value:    types.MapValueMust(types.BoolType, map[string]attr.Value{"a": types.BoolValue(true)}),
+			expected: map[string]any{"a": true},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			value, diags := terraformValueToValue(t.Context(), tc.value)
+			require.False(t, diags.HasError(), diags)
+			assert.Equal(t, tc.expected, value)
+		})
+	}
+}
@@ -2,7 +2,6 @@ package provider
 
 import (
 	"context"
-	"strings"
 
 	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
@@ -152,7 +151,7 @@ func (r *Z3BucketResource) Read(ctx context.Context, req resource.ReadRequest, r
 
 	clientBucket, err := r.client.Z3.GetBucket(ctx, state.Name.ValueString())
 	if err != nil {
-		if strings.Contains(err.Error(), "unexpected status code: 404") {
+		if client.IsNotFound(err) {
 			resp.State.RemoveResource(ctx)
 			return
 		}
@@ -0,0 +1,40 @@
+package provider
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+
+	"github.com/masslight/terraform-provider-oystehr/internal/testing/fakeoystehr"
+)
+
+func TestAccZ3BucketResource(t *testing.T) {
+	server := newTestAccServer(t)
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		CheckDestroy:             testAccCheckDestroyed(server, "oystehr_z3_bucket", fakeoystehr.CollectionZ3Buckets, "name"),
+		Steps: []resource.TestStep{
+			{
+				Config: server.ProviderConfig() + `
+resource "oystehr_z3_bucket" "test" {
+  name = "acceptance-test"
+}
+`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttrSet("oystehr_z3_bucket.test", "id"),
+					resource.TestCheckResourceAttr("oystehr_z3_bucket.test", "removal_policy", "delete"),
+				),
+			},
+			{
+				ResourceName:                         "oystehr_z3_bucket.test",
+				ImportState:                          true,
+				ImportStateId:                        "acceptance-test",
+				ImportStateVerify:                    true,
+				ImportStateVerifyIdentifierAttribute: "name",
+				// The removal policy only exists in the configuration
+				ImportStateVerifyIgnore: []string{"removal_policy"},
+			},
+		},
+	})
+}
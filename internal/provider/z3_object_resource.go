@@ -166,7 +166,7 @@ func (r *Z3ObjectResource) Read(ctx context.Context, req resource.ReadRequest, r
 
 	object, err := r.client.Z3.ListObject(ctx, state.Bucket.ValueString(), state.Key.ValueString())
 	if err != nil {
-		if strings.Contains(err.Error(), "unexpected status code: 404") {
+		if client.IsNotFound(err) {
 			resp.State.RemoveResource(ctx)
 			return
 		}
@@ -0,0 +1,84 @@
+package provider
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/terraform"
+
+	"github.com/masslight/terraform-provider-oystehr/internal/testing/fakeoystehr"
+)
+
+func TestAccZ3ObjectResource(t *testing.T) {
+	server := newTestAccServer(t)
+	first := writeTestFile(t, "first.txt", "first")
+	second := writeTestFile(t, "second.txt", "second")
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		CheckDestroy: func(s *terraform.State) error {
+			for _, rs := range s.RootModule().Resources {
+				if rs.Type != "oystehr_z3_object" {
+					continue
+				}
+				key := rs.Primary.Attributes["bucket"] + "/" + rs.Primary.Attributes["key"]
+				if _, ok := server.Object(fakeoystehr.CollectionZ3Objects, key); ok {
+					return fmt.Errorf("oystehr_z3_object %s still exists", key)
+				}
+			}
+			return nil
+		},
+		Steps: []resource.TestStep{
+			{
+				Config: server.ProviderConfig() + testAccZ3ObjectResourceConfig(first),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttrSet("oystehr_z3_object.test", "last_modified"),
+					resource.TestCheckResourceAttrSet("oystehr_z3_object.test", "source_checksum"),
+					testAccCheckUpload(server, "z3/acceptance-test/dir/object.txt", "first"),
+				),
+			},
+			{
+				ResourceName:                         "oystehr_z3_object.test",
+				ImportState:                          true,
+				ImportStateId:                        "acceptance-test/dir/object.txt",
+				ImportStateVerify:                    true,
+				ImportStateVerifyIdentifierAttribute: "key",
+				ImportStateVerifyIgnore:              []string{"source", "source_checksum"},
+			},
+			{
+				// Changing the source uploads the new content
+				Config: server.ProviderConfig() + testAccZ3ObjectResourceConfig(second),
+				Check:  testAccCheckUpload(server, "z3/acceptance-test/dir/object.txt", "second"),
+			},
+		},
+	})
+}
+
+func testAccZ3ObjectResourceConfig(source string) string {
+	return fmt.Sprintf(`
+resource "oystehr_z3_bucket" "test" {
+  name = "acceptance-test"
+}
+
+resource "oystehr_z3_object" "test" {
+  bucket = oystehr_z3_bucket.test.name
+  key    = "dir/object.txt"
+  source = %q
+}
+`, source)
+}
+
+// testAccCheckUpload checks the content last uploaded to a signed URL.
+func testAccCheckUpload(server *fakeoystehr.Server, key, expected string) resource.TestCheckFunc {
+	return func(*terraform.State) error {
+		data, ok := server.Upload(key)
+		if !ok {
+			return fmt.Errorf("nothing was uploaded to %s", key)
+		}
+		if string(data) != expected {
+			return fmt.Errorf("expected %s to contain %q, got %q", key, expected, data)
+		}
+		return nil
+	}
+}
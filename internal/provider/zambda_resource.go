@@ -3,7 +3,6 @@ package provider
 import (
 	"context"
 	"fmt"
-	"strings"
 	"time"
 
 	"github.com/hashicorp/terraform-plugin-framework/attr"
@@ -301,7 +300,7 @@ func (r *ZambdaResource) Read(ctx context.Context, req resource.ReadRequest, res
 
 	zambda, err := r.client.Zambda.GetZambda(ctx, id)
 	if err != nil {
-		if strings.Contains(err.Error(), "unexpected status code: 404") {
+		if client.IsNotFound(err) {
 			resp.State.RemoveResource(ctx)
 			return
 		}
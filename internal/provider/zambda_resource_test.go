@@ -0,0 +1,58 @@
+package provider
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+
+	"github.com/masslight/terraform-provider-oystehr/internal/testing/fakeoystehr"
+)
+
+func TestAccZambdaResource(t *testing.T) {
+	server := newTestAccServer(t)
+	first := writeTestFile(t, "first.zip", "first")
+	second := writeTestFile(t, "second.zip", "second")
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		CheckDestroy:             testAccCheckDestroyed(server, "oystehr_zambda", fakeoystehr.CollectionZambdas, "id"),
+		Steps: []resource.TestStep{
+			{
+				Config: server.ProviderConfig() + testAccZambdaResourceConfig(first, 1024),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttrSet("oystehr_zambda.test", "id"),
+					resource.TestCheckResourceAttr("oystehr_zambda.test", "status", "Active"),
+					resource.TestCheckResourceAttr("oystehr_zambda.test", "file_info.name", "first.zip"),
+					resource.TestCheckResourceAttr("oystehr_zambda.test", "source_checksum", fmt.Sprintf("%x", sha256.Sum256([]byte("first")))),
+				),
+			},
+			{
+				ResourceName:            "oystehr_zambda.test",
+				ImportState:             true,
+				ImportStateVerify:       true,
+				ImportStateVerifyIgnore: []string{"source", "source_checksum"},
+			},
+			{
+				Config: server.ProviderConfig() + testAccZambdaResourceConfig(second, 2048),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("oystehr_zambda.test", "memory_size", "2048"),
+					resource.TestCheckResourceAttr("oystehr_zambda.test", "file_info.name", "second.zip"),
+					resource.TestCheckResourceAttr("oystehr_zambda.test", "source_checksum", fmt.Sprintf("%x", sha256.Sum256([]byte("second")))),
+				),
+			},
+		},
+	})
+}
+
+func testAccZambdaResourceConfig(source string, memorySize int) string {
+	return fmt.Sprintf(`
+resource "oystehr_zambda" "test" {
+  name        = "acceptance-test"
+  runtime     = "nodejs20.x"
+  memory_size = %d
+  source      = %q
+}
+`, memorySize, source)
+}
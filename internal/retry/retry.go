@@ -2,6 +2,7 @@ package retry
 
 import (
 	"context"
+	"errors"
 	"math"
 	"math/rand"
 	"time"
@@ -39,6 +40,49 @@ type RetryConfig struct {
 	DisableJitter bool
 }
 
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent wraps err so that RetryWithBackoff returns it immediately instead
+// of retrying. The wrapped error is returned unchanged.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err}
+}
+
+type retryAfterError struct {
+	err   error
+	delay time.Duration
+}
+
+func (e *retryAfterError) Error() string {
+	return e.err.Error()
+}
+
+func (e *retryAfterError) Unwrap() error {
+	return e.err
+}
+
+// After wraps err so that RetryWithBackoff waits for delay, e.g. from a
+// Retry-After header, instead of the computed backoff before the next attempt.
+func After(err error, delay time.Duration) error {
+	if err == nil {
+		return nil
+	}
+	return &retryAfterError{err, delay}
+}
+
 func RetryWithBackoff[T any](ctx context.Context, operation func() (T, error), config RetryConfig) (T, error) {
 	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
 	start := time.Now()
@@ -48,6 +92,11 @@ func RetryWithBackoff[T any](ctx context.Context, operation func() (T, error), c
 		if err == nil {
 			return res, nil
 		}
+		var permanent *permanentError
+		if errors.As(err, &permanent) {
+			var zero T
+			return zero, permanent.err
+		}
 		// If this was the last attempt, return the error
 		if config.MaxAttempts > Disabled && attempt == config.MaxAttempts-1 {
 			var zero T
@@ -60,13 +109,30 @@ func RetryWithBackoff[T any](ctx context.Context, operation func() (T, error), c
 		}
 		// Compute exponential backoff and apply full jitter
 		backoff, jitter := calculateBackoffAndJitter(rng, config, attempt)
+		// A server-provided delay replaces the computed one
+		var retryAfter *retryAfterError
+		if errors.As(err, &retryAfter) {
+			backoff, jitter = retryAfter.delay, retryAfter.delay
+			if config.MaxDuration > Disabled && time.Since(start)+retryAfter.delay > config.MaxDuration {
+				var zero T
+				return zero, err
+			}
+		}
 		tflog.Debug(ctx, "Retrying operation after backoff", map[string]any{
 			"attempt":      attempt + 1,
 			"max_attempts": config.MaxAttempts,
 			"backoff":      backoff,
 			"jitter":       jitter,
+			"error":        err.Error(),
 		})
-		time.Sleep(jitter)
+		timer := time.NewTimer(jitter)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			var zero T
+			return zero, errors.Join(err, ctx.Err())
+		case <-timer.C:
+		}
 	}
 
 	var zero T
@@ -1,6 +1,7 @@
 package retry
 
 import (
+	"context"
 	"fmt"
 	"math/rand"
 	"sync"
@@ -129,3 +130,64 @@ func TestCalculateJitter(t *testing.T) {
 		assert.LessOrEqual(t, jitter, config.MaxBackoff)
 	}
 }
+
+func TestRetryPermanent(t *testing.T) {
+	var attempts int
+	permanentErr := fmt.Errorf("permanent error")
+	res, err := RetryWithBackoff(t.Context(), func() (int, error) {
+		attempts++
+		return 0, Permanent(permanentErr)
+	}, DefaultRetryConfig)
+	assert.Equal(t, 0, res)
+	assert.Equal(t, permanentErr, err, "expected the unwrapped error")
+	assert.Equal(t, 1, attempts, "expected no retries")
+}
+
+func TestRetryAfter(t *testing.T) {
+	var attempts int
+	start := time.Now()
+	res, err := RetryWithBackoff(t.Context(), func() (int, error) {
+		attempts++
+		if attempts == 1 {
+			return 0, After(fmt.Errorf("throttled"), 1500*time.Millisecond)
+		}
+		return 42, nil
+	}, RetryConfig{
+		BaseBackoff: time.Millisecond,
+		MaxBackoff:  time.Millisecond,
+		MaxDuration: MaxDurationDefault,
+		MaxAttempts: MaxAttemptsDefault,
+	})
+	assert.Equal(t, 42, res)
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), 1500*time.Millisecond, "expected the retry-after delay to be honored")
+}
+
+func TestRetryAfterExceedsMaxDuration(t *testing.T) {
+	var attempts int
+	throttled := fmt.Errorf("throttled")
+	_, err := RetryWithBackoff(t.Context(), func() (int, error) {
+		attempts++
+		return 0, After(throttled, time.Minute)
+	}, DefaultRetryConfig)
+	assert.ErrorIs(t, err, throttled)
+	assert.Equal(t, 1, attempts, "expected to give up rather than wait past max duration")
+}
+
+func TestRetryContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(t.Context())
+	var attempts int
+	_, err := RetryWithBackoff(ctx, func() (int, error) {
+		attempts++
+		cancel()
+		return 0, fmt.Errorf("some error")
+	}, RetryConfig{
+		BaseBackoff:   time.Second,
+		MaxBackoff:    time.Second,
+		MaxDuration:   MaxDurationDefault,
+		MaxAttempts:   MaxAttemptsDefault,
+		DisableJitter: true,
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 1, attempts)
+}
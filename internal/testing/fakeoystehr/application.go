@@ -0,0 +1,70 @@
+package fakeoystehr
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+const CollectionApplications = "application"
+
+func (s *Server) registerApplication() {
+	s.mux.HandleFunc("POST /app/v1/application", s.handleCreateApplication)
+	s.mux.HandleFunc("GET /app/v1/application/{id}", s.handleGetObject(CollectionApplications))
+	s.mux.HandleFunc("PATCH /app/v1/application/{id}", s.handleUpdateApplication)
+	s.mux.HandleFunc("DELETE /app/v1/application/{id}", s.handleDeleteObject(CollectionApplications))
+}
+
+func (s *Server) handleCreateApplication(w http.ResponseWriter, r *http.Request) {
+	application, err := decodeObject(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if name, _ := application["name"].(string); name == "" {
+		writeError(w, http.StatusBadRequest, "name is required")
+		return
+	}
+	id := uuid.NewString()
+	application["id"] = id
+	application["clientId"] = uuid.NewString()
+	setConnectionName(application)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.collection(CollectionApplications)[id] = application
+	writeJSON(w, http.StatusOK, application)
+}
+
+func (s *Server) handleUpdateApplication(w http.ResponseWriter, r *http.Request) {
+	patch, err := decodeObject(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	for _, field := range []string{"id", "clientId", "connectionName"} {
+		delete(patch, field)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	application, ok := s.collection(CollectionApplications)[r.PathValue("id")]
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("application %s not found", r.PathValue("id")))
+		return
+	}
+	mergePatch(application, patch)
+	setConnectionName(application)
+	writeJSON(w, http.StatusOK, application)
+}
+
+// setConnectionName sets the login connection of an application, which
+// depends on whether login with email is enabled.
+func setConnectionName(application map[string]any) {
+	if enabled, ok := application["loginWithEmailEnabled"].(bool); !ok || enabled {
+		application["connectionName"] = "Username-Password-Authentication"
+	} else {
+		application["connectionName"] = "sms"
+	}
+}
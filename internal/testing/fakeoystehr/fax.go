@@ -0,0 +1,53 @@
+package fakeoystehr
+
+import (
+	"net/http"
+	"sort"
+)
+
+const (
+	CollectionFaxNumbers = "fax"
+	// FaxNumber is the number provisioned by onboarding.
+	FaxNumber = "+15555550100"
+)
+
+func (s *Server) registerFax() {
+	s.mux.HandleFunc("POST /fax/v1/onboard", s.handleFaxOnboard)
+	s.mux.HandleFunc("GET /fax/v1/config", s.handleFaxConfig)
+	s.mux.HandleFunc("POST /fax/v1/offboard", s.handleFaxOffboard)
+}
+
+// handleFaxOnboard provisions a number for the project. A project has at most
+// one number, so onboarding again returns the existing one.
+func (s *Server) handleFaxOnboard(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	numbers := s.collection(CollectionFaxNumbers)
+	for number := range numbers {
+		writeJSON(w, http.StatusOK, map[string]any{"faxNumber": number})
+		return
+	}
+	numbers[FaxNumber] = map[string]any{"faxNumber": FaxNumber}
+	writeJSON(w, http.StatusOK, map[string]any{"faxNumber": FaxNumber})
+}
+
+func (s *Server) handleFaxConfig(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	numbers := make([]string, 0, len(s.collection(CollectionFaxNumbers)))
+	for number := range s.collection(CollectionFaxNumbers) {
+		numbers = append(numbers, number)
+	}
+	sort.Strings(numbers)
+	writeJSON(w, http.StatusOK, map[string]any{
+		"configured": len(numbers) > 0,
+		"faxNumbers": numbers,
+	})
+}
+
+func (s *Server) handleFaxOffboard(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	clear(s.collection(CollectionFaxNumbers))
+	writeJSON(w, http.StatusOK, map[string]any{})
+}
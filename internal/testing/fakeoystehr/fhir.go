@@ -0,0 +1,408 @@
+package fakeoystehr
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+type fhirRecord struct {
+	resource map[string]any
+	version  int
+	deleted  bool
+}
+
+// fhirChange is a change made to a resource just before a versioned update of
+// it is served, as another system updating it concurrently would.
+type fhirChange struct {
+	change func(resource map[string]any)
+	count  int
+}
+
+// fhirResponse is the outcome of one bundle entry.
+type fhirResponse struct {
+	status   int
+	resource map[string]any
+	outcome  map[string]any
+}
+
+func (s *Server) registerFhir() {
+	s.mux.HandleFunc("POST /fhir", s.handleFhirBundle)
+	s.mux.HandleFunc("GET /fhir/{type}", s.handleFhirSearch)
+	s.mux.HandleFunc("POST /fhir/{type}/$validate", s.handleFhirValidate)
+	s.mux.HandleFunc("GET /fhir/ValueSet/{id}/$expand", s.handleFhirExpand)
+	s.mux.HandleFunc("GET /fhir/{type}/{id}/$validate-code", s.handleFhirValidateCode)
+}
+
+// FhirResource returns a copy of the current version of a FHIR resource, or
+// false if it does not exist or was deleted.
+func (s *Server) FhirResource(resourceType, id string) (map[string]any, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, ok := s.fhir[resourceType][id]
+	if !ok || record.deleted {
+		return nil, false
+	}
+	return clone(record.resource), true
+}
+
+// AddFhirResource stores a FHIR resource as if it had been created outside of
+// the test, returning its ID.
+func (s *Server) AddFhirResource(resource map[string]any) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := uuid.NewString()
+	s.fhirWrite(resource["resourceType"].(string), id, resource, http.StatusCreated)
+	return id
+}
+
+// UpdateFhirResource stores a new version of a FHIR resource as if it had been
+// updated outside of the test.
+func (s *Server) UpdateFhirResource(resource map[string]any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fhirWrite(resource["resourceType"].(string), resource["id"].(string), resource, http.StatusOK)
+}
+
+// ChangeBeforeFhirUpdate makes the next count updates of a FHIR resource with
+// an If-Match version conflict, by applying change to the stored resource and
+// storing it as a new version first.
+func (s *Server) ChangeBeforeFhirUpdate(resourceType, id string, count int, change func(resource map[string]any)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fhirChanges[resourceType+"/"+id] = &fhirChange{change: change, count: count}
+}
+
+func (s *Server) handleFhirBundle(w http.ResponseWriter, r *http.Request) {
+	var bundle struct {
+		ResourceType string `json:"resourceType"`
+		Type         string `json:"type"`
+		Entry        []struct {
+			FullURL  string         `json:"fullUrl"`
+			Resource map[string]any `json:"resource"`
+			Request  struct {
+				Method      string `json:"method"`
+				URL         string `json:"url"`
+				IfMatch     string `json:"ifMatch"`
+				IfNoneExist string `json:"ifNoneExist"`
+			} `json:"request"`
+		} `json:"entry"`
+	}
+	if err := decodeJSON(r.Body, &bundle); err != nil {
+		writeJSON(w, http.StatusBadRequest, operationOutcome("invalid", err.Error()))
+		return
+	}
+	if bundle.ResourceType != "Bundle" || (bundle.Type != "batch" && bundle.Type != "transaction") {
+		writeJSON(w, http.StatusBadRequest, operationOutcome("not-supported", "only batch and transaction bundles are supported"))
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if bundle.Type == "transaction" {
+		// Assign the IDs of created resources up front, so references to their
+		// fullUrl placeholders can be resolved in every entry
+		ids := make([]string, len(bundle.Entry))
+		references := map[string]string{}
+		for i, entry := range bundle.Entry {
+			if entry.Request.Method == http.MethodPost {
+				ids[i] = uuid.NewString()
+				if entry.FullURL != "" {
+					references[entry.FullURL] = strings.TrimPrefix(entry.Request.URL, "/") + "/" + ids[i]
+				}
+			}
+		}
+
+		snapshot := s.snapshotFhir()
+		entries := make([]map[string]any, len(bundle.Entry))
+		for i, entry := range bundle.Entry {
+			resolveReferences(entry.Resource, references)
+			var response fhirResponse
+			if ids[i] != "" {
+				response = s.fhirCreate(entry.Request.URL, ids[i], entry.Resource)
+			} else {
+				response = s.fhirEntry(entry.Request.Method, entry.Request.URL, entry.Request.IfMatch, entry.Request.IfNoneExist, entry.Resource)
+			}
+			if response.status >= 400 {
+				// Transactions are all or nothing
+				s.fhir = snapshot
+				writeJSON(w, response.status, response.outcome)
+				return
+			}
+			entries[i] = response.entry()
+		}
+		writeJSON(w, http.StatusOK, map[string]any{
+			"resourceType": "Bundle",
+			"type":         "transaction-response",
+			"entry":        entries,
+		})
+		return
+	}
+
+	entries := make([]map[string]any, len(bundle.Entry))
+	for i, entry := range bundle.Entry {
+		response := s.fhirEntry(entry.Request.Method, entry.Request.URL, entry.Request.IfMatch, entry.Request.IfNoneExist, entry.Resource)
+		entries[i] = response.entry()
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"resourceType": "Bundle",
+		"type":         "batch-response",
+		"entry":        entries,
+	})
+}
+
+// snapshotFhir copies the FHIR resources, to roll back a failed transaction.
+// The caller must hold s.mu.
+func (s *Server) snapshotFhir() map[string]map[string]*fhirRecord {
+	snapshot := make(map[string]map[string]*fhirRecord, len(s.fhir))
+	for resourceType, resources := range s.fhir {
+		snapshot[resourceType] = make(map[string]*fhirRecord, len(resources))
+		for id, record := range resources {
+			copied := *record
+			snapshot[resourceType][id] = &copied
+		}
+	}
+	return snapshot
+}
+
+// resolveReferences replaces references to the fullUrl of a transaction entry
+// with the resource the entry created.
+func resolveReferences(value any, references map[string]string) {
+	switch v := value.(type) {
+	case map[string]any:
+		for k, elem := range v {
+			if reference, ok := elem.(string); ok && k == "reference" {
+				if resolved, ok := references[reference]; ok {
+					v[k] = resolved
+				}
+				continue
+			}
+			resolveReferences(elem, references)
+		}
+	case []any:
+		for _, elem := range v {
+			resolveReferences(elem, references)
+		}
+	}
+}
+
+// fhirEntry serves one bundle entry. The caller must hold s.mu.
+func (s *Server) fhirEntry(method, url, ifMatch, ifNoneExist string, resource map[string]any) fhirResponse {
+	path, rawQuery, _ := strings.Cut(strings.TrimPrefix(url, "/"), "?")
+	resourceType, id, _ := strings.Cut(path, "/")
+	if resourceType == "" || strings.Contains(id, "/") {
+		return fhirError(http.StatusBadRequest, "invalid", fmt.Sprintf("unsupported URL %q", url))
+	}
+
+	switch {
+	case method == http.MethodPost && id == "" && ifNoneExist != "":
+		// Conditional create adopts a single existing match
+		matches, resp, ok := s.fhirMatches(resourceType, ifNoneExist)
+		if !ok {
+			return resp
+		}
+		switch len(matches) {
+		case 0:
+			return s.fhirCreate(url, uuid.NewString(), resource)
+		case 1:
+			return fhirResponse{status: http.StatusOK, resource: clone(matches[0])}
+		default:
+			return fhirError(http.StatusPreconditionFailed, "multiple-matches", fmt.Sprintf("%d resources match %s?%s", len(matches), resourceType, ifNoneExist))
+		}
+	case method == http.MethodPost && id == "":
+		return s.fhirCreate(url, uuid.NewString(), resource)
+	case method == http.MethodPut && id == "" && rawQuery != "":
+		// Conditional update resolves the ID from the single match, creating
+		// the resource when nothing matches
+		matches, resp, ok := s.fhirMatches(resourceType, rawQuery)
+		if !ok {
+			return resp
+		}
+		switch len(matches) {
+		case 0:
+			id, _ = resource["id"].(string)
+			if id == "" {
+				id = uuid.NewString()
+			}
+		case 1:
+			id = matches[0]["id"].(string)
+			if bodyID, ok := resource["id"].(string); ok && bodyID != id {
+				return fhirError(http.StatusBadRequest, "invalid", fmt.Sprintf("resource id %s does not match %s/%s, which matches the search", bodyID, resourceType, id))
+			}
+		default:
+			return fhirError(http.StatusPreconditionFailed, "multiple-matches", fmt.Sprintf("%d resources match %s?%s", len(matches), resourceType, rawQuery))
+		}
+		resource = clone(resource)
+		resource["id"] = id
+		return s.fhirEntry(method, "/"+resourceType+"/"+id, ifMatch, "", resource)
+	case method == http.MethodPatch && id == "" && rawQuery != "":
+		// Conditional patch applies to the single match
+		matches, resp, ok := s.fhirMatches(resourceType, rawQuery)
+		if !ok {
+			return resp
+		}
+		switch len(matches) {
+		case 0:
+			return fhirError(http.StatusNotFound, "not-found", fmt.Sprintf("no resources match %s?%s", resourceType, rawQuery))
+		case 1:
+			return s.fhirPatch(resourceType, matches[0]["id"].(string), ifMatch, resource)
+		default:
+			return fhirError(http.StatusPreconditionFailed, "multiple-matches", fmt.Sprintf("%d resources match %s?%s", len(matches), resourceType, rawQuery))
+		}
+	case method == http.MethodPatch && id != "":
+		return s.fhirPatch(resourceType, id, ifMatch, resource)
+	case method == http.MethodGet && id != "":
+		record, resp, ok := s.fhirRecord(resourceType, id)
+		if !ok {
+			return resp
+		}
+		return fhirResponse{status: http.StatusOK, resource: clone(record.resource)}
+	case method == http.MethodPut && id != "":
+		if resp, ok := checkResourceType(resource, resourceType); !ok {
+			return resp
+		}
+		if change, ok := s.fhirChanges[resourceType+"/"+id]; ok && ifMatch != "" && change.count > 0 {
+			change.count--
+			if record, ok := s.fhir[resourceType][id]; ok && !record.deleted {
+				changed := clone(record.resource)
+				change.change(changed)
+				s.fhirWrite(resourceType, id, changed, http.StatusOK)
+			}
+		}
+		status := http.StatusOK
+		record, exists := s.fhir[resourceType][id]
+		if !exists || record.deleted {
+			status = http.StatusCreated
+		}
+		if ifMatch != "" && (!exists || ifMatch != fmt.Sprintf(`W/"%d"`, record.version)) {
+			return fhirError(http.StatusPreconditionFailed, "conflict", fmt.Sprintf("version %s does not match the current version of %s/%s", ifMatch, resourceType, id))
+		}
+		return s.fhirWrite(resourceType, id, resource, status)
+	case method == http.MethodDelete && id != "":
+		if record, ok := s.fhir[resourceType][id]; ok && !record.deleted {
+			record.deleted = true
+			record.version++
+		}
+		return fhirResponse{status: http.StatusNoContent}
+	default:
+		return fhirError(http.StatusBadRequest, "not-supported", fmt.Sprintf("unsupported request %s %s", method, url))
+	}
+}
+
+// fhirMatches returns the current resources of a type matching a search query.
+// The caller must hold s.mu.
+func (s *Server) fhirMatches(resourceType, rawQuery string) ([]map[string]any, fhirResponse, bool) {
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return nil, fhirError(http.StatusBadRequest, "invalid", fmt.Sprintf("invalid search %q", rawQuery)), false
+	}
+	var matches []map[string]any
+	for _, id := range s.fhirIDs(resourceType) {
+		if resource := s.fhir[resourceType][id].resource; matchesSearch(resource, query) {
+			matches = append(matches, resource)
+		}
+	}
+	return matches, fhirResponse{}, true
+}
+
+// fhirCreate creates a resource with the given ID. The caller must hold s.mu.
+func (s *Server) fhirCreate(url, id string, resource map[string]any) fhirResponse {
+	resourceType := strings.TrimPrefix(url, "/")
+	if resourceType == "" || strings.Contains(resourceType, "/") {
+		return fhirError(http.StatusBadRequest, "invalid", fmt.Sprintf("unsupported URL %q", url))
+	}
+	if resp, ok := checkResourceType(resource, resourceType); !ok {
+		return resp
+	}
+	return s.fhirWrite(resourceType, id, resource, http.StatusCreated)
+}
+
+// fhirRecord looks up a resource, returning the error response for missing and
+// deleted resources. The caller must hold s.mu.
+func (s *Server) fhirRecord(resourceType, id string) (*fhirRecord, fhirResponse, bool) {
+	record, ok := s.fhir[resourceType][id]
+	if !ok {
+		return nil, fhirError(http.StatusNotFound, "not-found", fmt.Sprintf("%s/%s not found", resourceType, id)), false
+	}
+	if record.deleted {
+		return nil, fhirError(http.StatusGone, "deleted", fmt.Sprintf("%s/%s has been deleted", resourceType, id)), false
+	}
+	return record, fhirResponse{}, true
+}
+
+// fhirWrite stores a new version of a resource. The caller must hold s.mu.
+func (s *Server) fhirWrite(resourceType, id string, resource map[string]any, status int) fhirResponse {
+	resources, ok := s.fhir[resourceType]
+	if !ok {
+		resources = map[string]*fhirRecord{}
+		s.fhir[resourceType] = resources
+	}
+	record, ok := resources[id]
+	if !ok {
+		record = &fhirRecord{}
+		resources[id] = record
+	}
+	record.version++
+	record.deleted = false
+
+	stored := clone(resource)
+	stored["id"] = id
+	meta, _ := stored["meta"].(map[string]any)
+	if meta == nil {
+		meta = map[string]any{}
+	}
+	meta["versionId"] = strconv.Itoa(record.version)
+	meta["lastUpdated"] = now()
+	stored["meta"] = meta
+	record.resource = stored
+
+	return fhirResponse{status: status, resource: clone(stored)}
+}
+
+func (r fhirResponse) entry() map[string]any {
+	response := map[string]any{
+		"status": fmt.Sprintf("%d %s", r.status, http.StatusText(r.status)),
+	}
+	if r.outcome != nil {
+		response["outcome"] = r.outcome
+	}
+	entry := map[string]any{"response": response}
+	if r.resource != nil {
+		entry["resource"] = r.resource
+		meta, _ := r.resource["meta"].(map[string]any)
+		response["etag"] = fmt.Sprintf(`W/"%s"`, meta["versionId"])
+		response["lastModified"] = meta["lastUpdated"]
+	}
+	return entry
+}
+
+func checkResourceType(resource map[string]any, resourceType string) (fhirResponse, bool) {
+	if resource == nil {
+		return fhirError(http.StatusBadRequest, "required", "resource is required"), false
+	}
+	if resource["resourceType"] != resourceType {
+		return fhirError(http.StatusBadRequest, "invalid", fmt.Sprintf("resourceType %v does not match URL type %s", resource["resourceType"], resourceType)), false
+	}
+	return fhirResponse{}, true
+}
+
+func fhirError(status int, code, diagnostics string) fhirResponse {
+	return fhirResponse{status: status, outcome: operationOutcome(code, diagnostics)}
+}
+
+func operationOutcome(code, diagnostics string) map[string]any {
+	return map[string]any{
+		"resourceType": "OperationOutcome",
+		"issue": []any{
+			map[string]any{
+				"severity":    "error",
+				"code":        code,
+				"diagnostics": diagnostics,
+			},
+		},
+	}
+}
@@ -0,0 +1,217 @@
+package fakeoystehr
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// jsonPatchContentType is the content type of the Binary resource carrying a
+// JSON Patch in a bundle entry.
+const jsonPatchContentType = "application/json-patch+json"
+
+type patchOperation struct {
+	Op    string `json:"op"`
+	Path  string `json:"path"`
+	Value any    `json:"value"`
+}
+
+// fhirPatch applies a Binary wrapped JSON Patch to a resource. The add,
+// remove, replace and test operations are supported. The caller must hold s.mu.
+func (s *Server) fhirPatch(resourceType, id, ifMatch string, binary map[string]any) fhirResponse {
+	record, resp, ok := s.fhirRecord(resourceType, id)
+	if !ok {
+		return resp
+	}
+	if ifMatch != "" && ifMatch != fmt.Sprintf(`W/"%d"`, record.version) {
+		return fhirError(http.StatusPreconditionFailed, "conflict", fmt.Sprintf("version %s does not match the current version of %s/%s", ifMatch, resourceType, id))
+	}
+	if binary["resourceType"] != "Binary" || binary["contentType"] != jsonPatchContentType {
+		return fhirError(http.StatusBadRequest, "invalid", "a patch must be a Binary resource with content type "+jsonPatchContentType)
+	}
+	data, _ := binary["data"].(string)
+	raw, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return fhirError(http.StatusBadRequest, "invalid", "invalid patch data: "+err.Error())
+	}
+	var operations []patchOperation
+	if err := decodeJSON(bytes.NewReader(raw), &operations); err != nil {
+		return fhirError(http.StatusBadRequest, "invalid", "invalid patch: "+err.Error())
+	}
+	// RFC 6902 requires value on add, replace and test, even when it is null
+	var members []map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &members); err != nil {
+		return fhirError(http.StatusBadRequest, "invalid", "invalid patch: "+err.Error())
+	}
+	for i, operation := range operations {
+		if _, ok := members[i]["value"]; !ok && operation.Op != "remove" {
+			return fhirError(http.StatusBadRequest, "invalid", fmt.Sprintf("%s %s: missing value", operation.Op, operation.Path))
+		}
+	}
+
+	var patched any = clone(record.resource)
+	for _, operation := range operations {
+		patched, err = applyPatchOperation(patched, operation)
+		if err != nil {
+			return fhirError(http.StatusUnprocessableEntity, "processing", fmt.Sprintf("%s %s: %s", operation.Op, operation.Path, err))
+		}
+	}
+	resource, _ := patched.(map[string]any)
+	if resource["resourceType"] != resourceType || resource["id"] != id {
+		return fhirError(http.StatusUnprocessableEntity, "processing", "a patch cannot change the resourceType or id")
+	}
+	return s.fhirWrite(resourceType, id, resource, http.StatusOK)
+}
+
+// applyPatchOperation applies an operation to a document, returning the
+// updated document.
+func applyPatchOperation(document any, operation patchOperation) (any, error) {
+	if operation.Path == "" {
+		switch operation.Op {
+		case "test":
+			if !jsonEqual(document, operation.Value) {
+				return nil, fmt.Errorf("test failed")
+			}
+			return document, nil
+		case "add", "replace":
+			return operation.Value, nil
+		}
+		return nil, fmt.Errorf("unsupported operation")
+	}
+	if !strings.HasPrefix(operation.Path, "/") {
+		return nil, fmt.Errorf("invalid path")
+	}
+	tokens := strings.Split(operation.Path[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+
+	// Walk to the parent of the target
+	parent := document
+	for _, token := range tokens[:len(tokens)-1] {
+		child, ok := patchChild(parent, token)
+		if !ok {
+			return nil, fmt.Errorf("path not found")
+		}
+		parent = child
+	}
+	last := tokens[len(tokens)-1]
+	current, exists := patchChild(parent, last)
+
+	switch operation.Op {
+	case "test":
+		if !exists || !jsonEqual(current, operation.Value) {
+			return nil, fmt.Errorf("test failed")
+		}
+		return document, nil
+	case "replace", "remove":
+		if !exists {
+			return nil, fmt.Errorf("path not found")
+		}
+	case "add":
+	default:
+		return nil, fmt.Errorf("unsupported operation")
+	}
+
+	switch p := parent.(type) {
+	case map[string]any:
+		if operation.Op == "remove" {
+			delete(p, last)
+		} else {
+			p[last] = operation.Value
+		}
+	case []any:
+		// Arrays are replaced in their parent, since their length may change
+		index := len(p)
+		if last != "-" {
+			var err error
+			if index, err = strconv.Atoi(last); err != nil || index < 0 || index > len(p) {
+				return nil, fmt.Errorf("invalid array index")
+			}
+		}
+		var updated []any
+		switch operation.Op {
+		case "add":
+			updated = append(append(append([]any{}, p[:index]...), operation.Value), p[index:]...)
+		case "remove":
+			updated = append(append([]any{}, p[:index]...), p[index+1:]...)
+		case "replace":
+			updated = append([]any{}, p...)
+			updated[index] = operation.Value
+		}
+		return applyPatchOperation(document, patchOperation{Op: "replace", Path: patchPath(tokens[:len(tokens)-1]), Value: updated})
+	default:
+		return nil, fmt.Errorf("path not found")
+	}
+	return document, nil
+}
+
+// patchChild returns the field or array element of a value named by a token.
+func patchChild(value any, token string) (any, bool) {
+	switch v := value.(type) {
+	case map[string]any:
+		child, ok := v[token]
+		return child, ok
+	case []any:
+		index, err := strconv.Atoi(token)
+		if err != nil || index < 0 || index >= len(v) {
+			return nil, false
+		}
+		return v[index], true
+	}
+	return nil, false
+}
+
+// patchPath returns the JSON Pointer of the tokens.
+func patchPath(tokens []string) string {
+	var path strings.Builder
+	for _, token := range tokens {
+		path.WriteString("/" + strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1"))
+	}
+	return path.String()
+}
+
+// jsonEqual reports whether two JSON values are equal, comparing numbers by
+// value as RFC 6902 requires for test operations, so 1.5 equals 1.50.
+func jsonEqual(a, b any) bool {
+	switch a := a.(type) {
+	case json.Number:
+		b, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		x, _, errA := big.ParseFloat(a.String(), 10, 512, big.ToNearestEven)
+		y, _, errB := big.ParseFloat(b.String(), 10, 512, big.ToNearestEven)
+		return errA == nil && errB == nil && x.Cmp(y) == 0
+	case map[string]any:
+		b, ok := b.(map[string]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for k, v := range a {
+			w, exists := b[k]
+			if !exists || !jsonEqual(v, w) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		b, ok := b.([]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !jsonEqual(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return a == b
+	}
+}
@@ -0,0 +1,212 @@
+package fakeoystehr
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// defaultSearchCount is the page size of searches without _count.
+const defaultSearchCount = 20
+
+// handleFhirSearch serves a FHIR search. Besides _id, url and identifier, every
+// search parameter matches a top level field of the same name: strings by
+// case-insensitive prefix and anything else by its string form. Comma
+// separated values match any of the values, and modifiers are ignored.
+// _include, _revinclude, _elements and _count are supported, and pages are
+// linked with an _offset parameter.
+func (s *Server) handleFhirSearch(w http.ResponseWriter, r *http.Request) {
+	resourceType := r.PathValue("type")
+	query := r.URL.Query()
+	count := defaultSearchCount
+	if query.Has("_count") {
+		n, err := strconv.Atoi(query.Get("_count"))
+		if err != nil || n < 1 {
+			writeJSON(w, http.StatusBadRequest, operationOutcome("invalid", "invalid _count"))
+			return
+		}
+		count = n
+	}
+	offset, _ := strconv.Atoi(query.Get("_offset"))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var matches []map[string]any
+	for _, id := range s.fhirIDs(resourceType) {
+		resource := s.fhir[resourceType][id].resource
+		if matchesSearch(resource, query) {
+			matches = append(matches, resource)
+		}
+	}
+
+	page := matches[min(offset, len(matches)):min(offset+count, len(matches))]
+	var elements []string
+	if query.Has("_elements") {
+		elements = strings.Split(query.Get("_elements"), ",")
+	}
+	entries := make([]any, 0, len(page))
+	for _, resource := range page {
+		entries = append(entries, searchEntry(selectElements(resource, elements), "match"))
+	}
+	for _, resource := range s.searchIncludes(page, query) {
+		entries = append(entries, searchEntry(clone(resource), "include"))
+	}
+
+	links := []any{map[string]any{"relation": "self", "url": s.URL + r.URL.RequestURI()}}
+	if offset+count < len(matches) {
+		next := url.Values{}
+		for k, v := range query {
+			next[k] = v
+		}
+		next.Set("_offset", strconv.Itoa(offset+count))
+		links = append(links, map[string]any{"relation": "next", "url": s.URL + r.URL.Path + "?" + next.Encode()})
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"resourceType": "Bundle",
+		"type":         "searchset",
+		"total":        len(matches),
+		"link":         links,
+		"entry":        entries,
+	})
+}
+
+// fhirIDs returns the sorted IDs of the current resources of a type, so pages
+// are stable. The caller must hold s.mu.
+func (s *Server) fhirIDs(resourceType string) []string {
+	var ids []string
+	for id, record := range s.fhir[resourceType] {
+		if !record.deleted {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+// searchIncludes returns the resources that _include and _revinclude add for
+// the matched resources. The caller must hold s.mu.
+func (s *Server) searchIncludes(matches []map[string]any, query url.Values) []map[string]any {
+	var included []map[string]any
+	seen := map[string]bool{}
+	add := func(resource map[string]any) {
+		key := fmt.Sprintf("%v/%v", resource["resourceType"], resource["id"])
+		if !seen[key] {
+			seen[key] = true
+			included = append(included, resource)
+		}
+	}
+
+	for _, include := range query["_include"] {
+		_, field, _ := strings.Cut(include, ":")
+		for _, match := range matches {
+			for _, reference := range references(match[field]) {
+				resourceType, id, _ := strings.Cut(reference, "/")
+				if record, ok := s.fhir[resourceType][id]; ok && !record.deleted {
+					add(record.resource)
+				}
+			}
+		}
+	}
+	for _, revinclude := range query["_revinclude"] {
+		resourceType, field, _ := strings.Cut(revinclude, ":")
+		for _, id := range s.fhirIDs(resourceType) {
+			resource := s.fhir[resourceType][id].resource
+			for _, match := range matches {
+				target := fmt.Sprintf("%v/%v", match["resourceType"], match["id"])
+				if slices.Contains(references(resource[field]), target) {
+					add(resource)
+				}
+			}
+		}
+	}
+	return included
+}
+
+// references returns the references of a Reference or list of References.
+func references(value any) []string {
+	switch v := value.(type) {
+	case map[string]any:
+		if reference, ok := v["reference"].(string); ok {
+			return []string{reference}
+		}
+	case []any:
+		var result []string
+		for _, elem := range v {
+			result = append(result, references(elem)...)
+		}
+		return result
+	}
+	return nil
+}
+
+func matchesSearch(resource map[string]any, query url.Values) bool {
+	for param, values := range query {
+		if strings.HasPrefix(param, "_") && param != "_id" {
+			continue
+		}
+		param, _, _ = strings.Cut(param, ":")
+		for _, value := range values {
+			if !slices.ContainsFunc(strings.Split(value, ","), func(value string) bool {
+				return matchesParam(resource, param, value)
+			}) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func matchesParam(resource map[string]any, param, value string) bool {
+	switch param {
+	case "_id":
+		return resource["id"] == value
+	case "url":
+		return resource["url"] == value
+	case "identifier":
+		system, code, hasSystem := strings.Cut(value, "|")
+		if !hasSystem {
+			system, code = "", value
+		}
+		identifiers, _ := resource["identifier"].([]any)
+		for _, elem := range identifiers {
+			identifier, _ := elem.(map[string]any)
+			if identifier["value"] == code && (!hasSystem || identifier["system"] == system) {
+				return true
+			}
+		}
+		return false
+	}
+	switch field := resource[param].(type) {
+	case nil:
+		return false
+	case string:
+		return strings.HasPrefix(strings.ToLower(field), strings.ToLower(value))
+	default:
+		return fmt.Sprint(field) == value
+	}
+}
+
+// selectElements applies _elements, keeping the mandatory elements.
+func selectElements(resource map[string]any, elements []string) map[string]any {
+	if elements == nil {
+		return clone(resource)
+	}
+	selected := map[string]any{}
+	for k, v := range clone(resource) {
+		if k == "resourceType" || k == "id" || k == "meta" || slices.Contains(elements, k) {
+			selected[k] = v
+		}
+	}
+	return selected
+}
+
+func searchEntry(resource map[string]any, mode string) map[string]any {
+	return map[string]any{
+		"fullUrl":  fmt.Sprintf("%v/%v", resource["resourceType"], resource["id"]),
+		"resource": resource,
+		"search":   map[string]any{"mode": mode},
+	}
+}
@@ -0,0 +1,148 @@
+package fakeoystehr
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// terminologyCode is a code of a CodeSystem or of a ValueSet expansion.
+type terminologyCode struct {
+	system  string
+	code    string
+	display any
+}
+
+// handleFhirExpand serves the $expand operation on a stored ValueSet. The
+// expansion holds the concepts listed by the includes of the compose, or every
+// concept of a stored CodeSystem an include names without listing concepts.
+// Filters are not supported.
+func (s *Server) handleFhirExpand(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, resp, ok := s.fhirRecord("ValueSet", r.PathValue("id"))
+	if !ok {
+		writeJSON(w, resp.status, resp.outcome)
+		return
+	}
+	codes, resp, ok := s.expandValueSet(record.resource)
+	if !ok {
+		writeJSON(w, resp.status, resp.outcome)
+		return
+	}
+
+	contains := make([]any, len(codes))
+	for i, code := range codes {
+		contains[i] = map[string]any{"system": code.system, "code": code.code, "display": code.display}
+	}
+	valueSet := clone(record.resource)
+	valueSet["expansion"] = map[string]any{
+		"total":    len(codes),
+		"contains": contains,
+	}
+	writeJSON(w, http.StatusOK, valueSet)
+}
+
+// handleFhirValidateCode serves the $validate-code operation on a stored
+// CodeSystem or ValueSet.
+func (s *Server) handleFhirValidateCode(w http.ResponseWriter, r *http.Request) {
+	resourceType := r.PathValue("type")
+	if resourceType != "CodeSystem" && resourceType != "ValueSet" {
+		writeJSON(w, http.StatusBadRequest, operationOutcome("not-supported", fmt.Sprintf("$validate-code is not supported on %s", resourceType)))
+		return
+	}
+	system := r.URL.Query().Get("system")
+	code := r.URL.Query().Get("code")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, resp, ok := s.fhirRecord(resourceType, r.PathValue("id"))
+	if !ok {
+		writeJSON(w, resp.status, resp.outcome)
+		return
+	}
+	var codes []terminologyCode
+	if resourceType == "CodeSystem" {
+		url, _ := record.resource["url"].(string)
+		if system == "" {
+			system = url
+		}
+		codes = codeSystemCodes(url, record.resource["concept"])
+	} else {
+		codes, resp, ok = s.expandValueSet(record.resource)
+		if !ok {
+			writeJSON(w, resp.status, resp.outcome)
+			return
+		}
+	}
+
+	var found *terminologyCode
+	for _, candidate := range codes {
+		if candidate.system == system && candidate.code == code {
+			found = &candidate
+			break
+		}
+	}
+	parameters := []any{map[string]any{"name": "result", "valueBoolean": found != nil}}
+	if found == nil {
+		parameters = append(parameters, map[string]any{
+			"name":        "message",
+			"valueString": fmt.Sprintf("Unknown code '%s|%s' in %s/%s", system, code, resourceType, r.PathValue("id")),
+		})
+	} else if found.display != nil {
+		parameters = append(parameters, map[string]any{"name": "display", "valueString": found.display})
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"resourceType": "Parameters",
+		"parameter":    parameters,
+	})
+}
+
+// expandValueSet returns the codes of a ValueSet. The caller must hold s.mu.
+func (s *Server) expandValueSet(valueSet map[string]any) ([]terminologyCode, fhirResponse, bool) {
+	compose, _ := valueSet["compose"].(map[string]any)
+	includes, _ := compose["include"].([]any)
+	var codes []terminologyCode
+	for _, elem := range includes {
+		include, _ := elem.(map[string]any)
+		system, _ := include["system"].(string)
+		if concepts, ok := include["concept"].([]any); ok {
+			for _, elem := range concepts {
+				concept, _ := elem.(map[string]any)
+				code, _ := concept["code"].(string)
+				codes = append(codes, terminologyCode{system: system, code: code, display: concept["display"]})
+			}
+			continue
+		}
+		codeSystem := s.codeSystem(system)
+		if codeSystem == nil {
+			return nil, fhirError(http.StatusBadRequest, "not-found", fmt.Sprintf("unable to expand: CodeSystem %s is unknown", system)), false
+		}
+		codes = append(codes, codeSystemCodes(system, codeSystem["concept"])...)
+	}
+	return codes, fhirResponse{}, true
+}
+
+// codeSystem returns the stored CodeSystem with a canonical URL. The caller
+// must hold s.mu.
+func (s *Server) codeSystem(url string) map[string]any {
+	for _, id := range s.fhirIDs("CodeSystem") {
+		if resource := s.fhir["CodeSystem"][id].resource; resource["url"] == url {
+			return resource
+		}
+	}
+	return nil
+}
+
+// codeSystemCodes flattens the concepts of a CodeSystem, including nested
+// concepts.
+func codeSystemCodes(system string, concepts any) []terminologyCode {
+	list, _ := concepts.([]any)
+	var codes []terminologyCode
+	for _, elem := range list {
+		concept, _ := elem.(map[string]any)
+		code, _ := concept["code"].(string)
+		codes = append(codes, terminologyCode{system: system, code: code, display: concept["display"]})
+		codes = append(codes, codeSystemCodes(system, concept["concept"])...)
+	}
+	return codes
+}
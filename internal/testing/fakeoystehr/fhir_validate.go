@@ -0,0 +1,87 @@
+package fakeoystehr
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+)
+
+// patientGenders are the codes of the administrative-gender value set.
+var patientGenders = []any{"male", "female", "other", "unknown"}
+
+// handleFhirValidate serves the $validate operation. It checks a few rules
+// rather than whole profiles: the resourceType, Questionnaire.status and the
+// linkId and type of Questionnaire items, and Patient.gender. A profile must
+// be the url of a stored StructureDefinition.
+func (s *Server) handleFhirValidate(w http.ResponseWriter, r *http.Request) {
+	resourceType := r.PathValue("type")
+	var resource map[string]any
+	if err := decodeJSON(r.Body, &resource); err != nil {
+		writeJSON(w, http.StatusBadRequest, operationOutcome("invalid", err.Error()))
+		return
+	}
+
+	var issues []any
+	addIssue := func(severity, code, diagnostics, expression string) {
+		issues = append(issues, map[string]any{
+			"severity":    severity,
+			"code":        code,
+			"diagnostics": diagnostics,
+			"expression":  []any{expression},
+		})
+	}
+	if resource["resourceType"] != resourceType {
+		addIssue("error", "invalid", fmt.Sprintf("resourceType %v does not match %s", resource["resourceType"], resourceType), resourceType)
+	}
+	switch resourceType {
+	case "Questionnaire":
+		if resource["status"] == nil {
+			addIssue("error", "required", "Questionnaire.status: minimum required = 1, but only found 0", "Questionnaire")
+		}
+		var checkItems func(items any, expression string)
+		checkItems = func(items any, expression string) {
+			list, _ := items.([]any)
+			for i, elem := range list {
+				item, _ := elem.(map[string]any)
+				itemExpression := fmt.Sprintf("%s.item[%d]", expression, i)
+				for _, field := range []string{"linkId", "type"} {
+					if item[field] == nil {
+						addIssue("error", "required", fmt.Sprintf("Questionnaire.item.%s: minimum required = 1, but only found 0", field), itemExpression)
+					}
+				}
+				checkItems(item["item"], itemExpression)
+			}
+		}
+		checkItems(resource["item"], "Questionnaire")
+	case "Patient":
+		if gender, ok := resource["gender"]; ok && !slices.Contains(patientGenders, gender) {
+			addIssue("error", "code-invalid", fmt.Sprintf("The value provided (%v) is not in the value set administrative-gender", gender), "Patient.gender")
+		}
+	}
+
+	if profile := r.URL.Query().Get("profile"); profile != "" {
+		s.mu.Lock()
+		found := false
+		for _, id := range s.fhirIDs("StructureDefinition") {
+			if s.fhir["StructureDefinition"][id].resource["url"] == profile {
+				found = true
+			}
+		}
+		s.mu.Unlock()
+		if !found {
+			addIssue("error", "not-found", fmt.Sprintf("Profile reference '%s' has not been checked because it is unknown", profile), resourceType)
+		}
+	}
+
+	if len(issues) == 0 {
+		issues = append(issues, map[string]any{
+			"severity":    "information",
+			"code":        "informational",
+			"diagnostics": "No issues detected during validation",
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"resourceType": "OperationOutcome",
+		"issue":        issues,
+	})
+}
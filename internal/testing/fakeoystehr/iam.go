@@ -0,0 +1,122 @@
+package fakeoystehr
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+const (
+	CollectionM2M   = "m2m"
+	CollectionRoles = "role"
+)
+
+func (s *Server) registerIAM() {
+	s.mux.HandleFunc("POST /iam/v1/iam/role", s.handleCreateObject(CollectionRoles, nil))
+	s.mux.HandleFunc("GET /iam/v1/iam/role/{id}", s.handleGetObject(CollectionRoles))
+	s.mux.HandleFunc("PATCH /iam/v1/iam/role/{id}", s.handleUpdateObject(CollectionRoles))
+	s.mux.HandleFunc("DELETE /iam/v1/iam/role/{id}", s.handleDeleteObject(CollectionRoles))
+
+	s.mux.HandleFunc("POST /iam/v1/m2m", s.handleCreateM2M)
+	s.mux.HandleFunc("GET /iam/v1/m2m/{id}", s.handleGetM2M)
+	s.mux.HandleFunc("PATCH /iam/v1/m2m/{id}", s.handleUpdateM2M)
+	s.mux.HandleFunc("DELETE /iam/v1/m2m/{id}", s.handleDeleteObject(CollectionM2M))
+	s.mux.HandleFunc("POST /iam/v1/m2m/{id}/rotate-secret", s.handleRotateM2MSecret)
+}
+
+func (s *Server) handleCreateM2M(w http.ResponseWriter, r *http.Request) {
+	m2m, err := decodeObject(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	id := uuid.NewString()
+	m2m["id"] = id
+	m2m["clientId"] = uuid.NewString()
+	if m2m["profile"] == nil {
+		m2m["profile"] = "Device/" + uuid.NewString()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.rolesExist(w, m2m["roles"]) {
+		return
+	}
+	s.collection(CollectionM2M)[id] = m2m
+	writeJSON(w, http.StatusOK, s.m2mOutput(m2m))
+}
+
+func (s *Server) handleGetM2M(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m2m, ok := s.collection(CollectionM2M)[r.PathValue("id")]
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("m2m %s not found", r.PathValue("id")))
+		return
+	}
+	writeJSON(w, http.StatusOK, s.m2mOutput(m2m))
+}
+
+func (s *Server) handleUpdateM2M(w http.ResponseWriter, r *http.Request) {
+	patch, err := decodeObject(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	delete(patch, "id")
+	delete(patch, "clientId")
+	if patch["profile"] == nil {
+		delete(patch, "profile")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m2m, ok := s.collection(CollectionM2M)[r.PathValue("id")]
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("m2m %s not found", r.PathValue("id")))
+		return
+	}
+	if !s.rolesExist(w, patch["roles"]) {
+		return
+	}
+	mergePatch(m2m, patch)
+	writeJSON(w, http.StatusOK, s.m2mOutput(m2m))
+}
+
+func (s *Server) handleRotateM2MSecret(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.collection(CollectionM2M)[r.PathValue("id")]; !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("m2m %s not found", r.PathValue("id")))
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"secret": uuid.NewString()})
+}
+
+// rolesExist checks that every role ID of an M2M input exists, writing an
+// error response if not. The caller must hold s.mu.
+func (s *Server) rolesExist(w http.ResponseWriter, roles any) bool {
+	ids, _ := roles.([]any)
+	for _, id := range ids {
+		if _, ok := s.collection(CollectionRoles)[fmt.Sprint(id)]; !ok {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("role %v not found", id))
+			return false
+		}
+	}
+	return true
+}
+
+// m2mOutput expands the stored role IDs of an M2M into role stubs, as the API
+// returns them. The caller must hold s.mu.
+func (s *Server) m2mOutput(m2m map[string]any) map[string]any {
+	output := clone(m2m)
+	ids, _ := m2m["roles"].([]any)
+	roles := make([]any, 0, len(ids))
+	for _, id := range ids {
+		role := s.collection(CollectionRoles)[fmt.Sprint(id)]
+		roles = append(roles, map[string]any{"id": id, "name": role["name"]})
+	}
+	output["roles"] = roles
+	return output
+}
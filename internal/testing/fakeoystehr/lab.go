@@ -0,0 +1,55 @@
+package fakeoystehr
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+const CollectionLabRoutes = "route"
+
+func (s *Server) registerLab() {
+	s.mux.HandleFunc("POST /labs/v1/route", s.handleCreateLabRoute)
+	s.mux.HandleFunc("GET /labs/v1/route/{id}", s.handleGetLabRoute)
+	s.mux.HandleFunc("DELETE /labs/v1/route/{id}", s.handleDeleteObject(CollectionLabRoutes))
+}
+
+func (s *Server) handleCreateLabRoute(w http.ResponseWriter, r *http.Request) {
+	route, err := decodeObject(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	labGUID, _ := route["labGuid"].(string)
+	if labGUID == "" {
+		writeError(w, http.StatusBadRequest, "labGuid is required")
+		return
+	}
+	if account, _ := route["accountNumber"].(string); account == "" {
+		writeError(w, http.StatusBadRequest, "accountNumber is required")
+		return
+	}
+	id := uuid.NewString()
+	route["routeGuid"] = id
+	route["labName"] = "Lab " + labGUID
+	route["primaryId"] = strings.ToUpper(id[:8])
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.collection(CollectionLabRoutes)[id] = route
+	writeJSON(w, http.StatusOK, map[string]any{"routeGuid": id})
+}
+
+// handleGetLabRoute responds with null rather than a 404 for unknown routes,
+// as the API does.
+func (s *Server) handleGetLabRoute(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	route, ok := s.collection(CollectionLabRoutes)[r.PathValue("id")]
+	if !ok {
+		writeJSON(w, http.StatusOK, nil)
+		return
+	}
+	writeJSON(w, http.StatusOK, route)
+}
@@ -0,0 +1,51 @@
+package fakeoystehr
+
+import (
+	"fmt"
+	"net/http"
+)
+
+func (s *Server) registerProject() {
+	s.mux.HandleFunc("GET /project/v1/project", s.handleGetProject)
+	s.mux.HandleFunc("PATCH /project/v1/project", s.handleUpdateProject)
+}
+
+// Project returns a copy of the project.
+func (s *Server) Project() map[string]any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return clone(s.project)
+}
+
+func (s *Server) handleGetProject(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	writeJSON(w, http.StatusOK, s.project)
+}
+
+func (s *Server) handleUpdateProject(w http.ResponseWriter, r *http.Request) {
+	patch, err := decodeObject(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	update := map[string]any{}
+	for _, field := range []string{"name", "description", "signupEnabled"} {
+		if v, ok := patch[field]; ok {
+			update[field] = v
+		}
+	}
+	if id, ok := patch["defaultPatientRoleId"].(string); ok {
+		role, ok := s.collection(CollectionRoles)[id]
+		if !ok {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("role %s not found", id))
+			return
+		}
+		s.project["defaultPatientRole"] = map[string]any{"id": id, "name": role["name"]}
+	}
+	mergePatch(s.project, update)
+	writeJSON(w, http.StatusOK, s.project)
+}
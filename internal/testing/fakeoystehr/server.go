@@ -0,0 +1,483 @@
+// Package fakeoystehr is an in-memory fake of the Oystehr APIs used by the
+// provider, for tests that run fully offline.
+//
+// Every service is served from a single httptest.Server using the layout of
+// the provider's endpoints.base_url setting, i.e. each service is reached at
+// <URL>/<service>, e.g. <URL>/fhir or <URL>/zambda/v1/zambda. State is kept in
+// memory for the lifetime of the server, and faults can be injected to
+// exercise retries and error handling.
+package fakeoystehr
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	ProjectID    = "00000000-0000-4000-8000-000000000001"
+	ClientID     = "fake-client-id"
+	ClientSecret = "fake-client-secret"
+	// tokenLifetime is the expires_in of minted access tokens, in seconds.
+	tokenLifetime = 3600
+)
+
+// Request is a request received by the server.
+type Request struct {
+	Method string
+	Path   string
+	Header http.Header
+	Body   []byte
+}
+
+// Fault makes matching requests fail instead of being served.
+type Fault struct {
+	// Method matches any method when empty.
+	Method string
+	// Path is a path prefix, e.g. "/zambda/v1/zambda". It matches any path when
+	// empty.
+	Path string
+	// Status is the status code to respond with. When zero, the request is
+	// served normally after Delay.
+	Status int
+	Header http.Header
+	Body   string
+	// Delay is waited before responding.
+	Delay time.Duration
+	// CloseConnection drops the connection without a response, as a connection
+	// reset would.
+	CloseConnection bool
+	// Count is the number of matching requests to fail. Zero fails every
+	// matching request.
+	Count int
+}
+
+func (f *Fault) matches(r *http.Request) bool {
+	return (f.Method == "" || f.Method == r.Method) && strings.HasPrefix(r.URL.Path, f.Path)
+}
+
+// Server is a fake Oystehr API. It is safe for concurrent use.
+type Server struct {
+	// URL is the base URL of every service, for the endpoints.base_url provider
+	// setting or client.Endpoints.Base.
+	URL string
+
+	server *httptest.Server
+	mux    *http.ServeMux
+
+	mu          sync.Mutex
+	requests    []Request
+	faults      []*Fault
+	tokens      map[string]bool
+	collections map[string]map[string]map[string]any
+	fhir        map[string]map[string]*fhirRecord
+	fhirChanges map[string]*fhirChange
+	uploads     map[string][]byte
+	uploadHooks map[string]uploadHook
+	project     map[string]any
+}
+
+// New starts a server. Callers must call Close when done.
+func New() *Server {
+	s := &Server{
+		mux:         http.NewServeMux(),
+		tokens:      map[string]bool{},
+		collections: map[string]map[string]map[string]any{},
+		fhir:        map[string]map[string]*fhirRecord{},
+		fhirChanges: map[string]*fhirChange{},
+		uploads:     map[string][]byte{},
+		uploadHooks: map[string]uploadHook{},
+		project: map[string]any{
+			"id":                 ProjectID,
+			"name":               "Fake Project",
+			"description":        "",
+			"signupEnabled":      false,
+			"defaultPatientRole": map[string]any{},
+			"fhirVersion":        "r4",
+			"sandbox":            true,
+		},
+	}
+	s.mux.HandleFunc("POST /auth/oauth/token", s.handleToken)
+	s.mux.HandleFunc("PUT /s3/{key...}", s.handleSignedUpload)
+	s.registerApplication()
+	s.registerFax()
+	s.registerFhir()
+	s.registerIAM()
+	s.registerLab()
+	s.registerProject()
+	s.registerZ3()
+	s.registerZambda()
+
+	s.server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	s.URL = s.server.URL
+	return s
+}
+
+// Close shuts the server down.
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// ProviderConfig returns a provider block configured to use the server.
+func (s *Server) ProviderConfig() string {
+	return fmt.Sprintf(`
+provider "oystehr" {
+  project_id    = %q
+  client_id     = %q
+  client_secret = %q
+  endpoints = {
+    base_url = %q
+  }
+}
+`, ProjectID, ClientID, ClientSecret, s.URL)
+}
+
+// InjectFault adds a fault. Faults are matched in the order they were added.
+func (s *Server) InjectFault(f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = append(s.faults, &f)
+}
+
+// ClearFaults removes all faults.
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = nil
+}
+
+// Requests returns the requests received so far, in order.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// Object returns a copy of an object of a non-FHIR collection, e.g.
+// Object(CollectionZambda, id).
+func (s *Server) Object(collection, id string) (map[string]any, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	object, ok := s.collections[collection][id]
+	if !ok {
+		return nil, false
+	}
+	return clone(object), true
+}
+
+// Upload returns the content uploaded to a signed URL with the given key.
+func (s *Server) Upload(key string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.uploads[key]
+	return data, ok
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		decompressed, err := gunzip(body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid gzip request body")
+			return
+		}
+		body = decompressed
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	s.mu.Lock()
+	s.requests = append(s.requests, Request{Method: r.Method, Path: r.URL.Path, Header: r.Header.Clone(), Body: body})
+	fault := s.takeFault(r)
+	s.mu.Unlock()
+
+	if fault != nil {
+		if fault.Delay > 0 {
+			select {
+			case <-time.After(fault.Delay):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		if fault.CloseConnection {
+			if hijacker, ok := w.(http.Hijacker); ok {
+				if conn, _, err := hijacker.Hijack(); err == nil {
+					conn.Close()
+					return
+				}
+			}
+		}
+		if fault.Status != 0 {
+			for k, v := range fault.Header {
+				w.Header()[k] = v
+			}
+			w.WriteHeader(fault.Status)
+			_, _ = io.WriteString(w, fault.Body)
+			return
+		}
+	}
+
+	if !s.authorized(r) {
+		writeError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+func gunzip(data []byte) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(reader)
+}
+
+// takeFault returns the first fault matching r, consuming one of its counts.
+// The caller must hold s.mu.
+func (s *Server) takeFault(r *http.Request) *Fault {
+	for i, f := range s.faults {
+		if !f.matches(r) {
+			continue
+		}
+		if f.Count > 0 {
+			f.Count--
+			if f.Count == 0 {
+				s.faults = append(s.faults[:i:i], s.faults[i+1:]...)
+			}
+		}
+		return f
+	}
+	return nil
+}
+
+// authorized checks the access token and project of API requests. Token and
+// signed URL requests are authorized by their body and URL instead.
+func (s *Server) authorized(r *http.Request) bool {
+	if strings.HasPrefix(r.URL.Path, "/auth/") || strings.HasPrefix(r.URL.Path, "/s3/") {
+		return true
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tokens[token] && r.Header.Get("x-oystehr-project-id") == ProjectID
+}
+
+func (s *Server) handleToken(w http.ResponseWriter, r *http.Request) {
+	var body map[string]string
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if body["grant_type"] != "client_credentials" || body["client_id"] != ClientID {
+		writeError(w, http.StatusUnauthorized, "access_denied")
+		return
+	}
+	// Client assertions are accepted without verifying the signature
+	if body["client_secret"] != ClientSecret && body["client_assertion"] == "" {
+		writeError(w, http.StatusUnauthorized, "access_denied")
+		return
+	}
+	token := "fake-access-token-" + uuid.NewString()
+	s.mu.Lock()
+	s.tokens[token] = true
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": token,
+		"token_type":   "Bearer",
+		"expires_in":   tokenLifetime,
+	})
+}
+
+// signedURL returns a URL that accepts an upload for key without an access
+// token, as an S3 presigned URL would.
+func (s *Server) signedURL(key string) string {
+	return fmt.Sprintf("%s/s3/%s?X-Amz-Signature=%s", s.URL, key, uuid.NewString())
+}
+
+// uploadHook is called with the server lock held after content is uploaded to
+// a signed URL whose key starts with the hook's prefix.
+type uploadHook func(key string, data []byte)
+
+func (s *Server) handleSignedUpload(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("X-Amz-Signature") == "" {
+		writeError(w, http.StatusForbidden, "missing signature")
+		return
+	}
+	key := r.PathValue("key")
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.uploads[key] = data
+	prefix, _, _ := strings.Cut(key, "/")
+	if hook, ok := s.uploadHooks[prefix]; ok {
+		hook(key, data)
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// collection returns a non-FHIR collection, creating it if needed. The caller
+// must hold s.mu.
+func (s *Server) collection(name string) map[string]map[string]any {
+	c, ok := s.collections[name]
+	if !ok {
+		c = map[string]map[string]any{}
+		s.collections[name] = c
+	}
+	return c
+}
+
+// handleCreateObject stores the request body under a new ID and echoes it
+// back. defaults are applied to fields the body leaves unset.
+func (s *Server) handleCreateObject(collection string, defaults func() map[string]any) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		object, err := decodeObject(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if defaults != nil {
+			for k, v := range defaults() {
+				if _, ok := object[k]; !ok || object[k] == nil {
+					object[k] = v
+				}
+			}
+		}
+		object["id"] = uuid.NewString()
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.collection(collection)[object["id"].(string)] = object
+		writeJSON(w, http.StatusOK, object)
+	}
+}
+
+func (s *Server) handleGetObject(collection string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		object, ok := s.collection(collection)[r.PathValue("id")]
+		if !ok {
+			writeError(w, http.StatusNotFound, fmt.Sprintf("%s %s not found", collection, r.PathValue("id")))
+			return
+		}
+		writeJSON(w, http.StatusOK, object)
+	}
+}
+
+// handleUpdateObject merges the request body into an object. Fields set by the
+// server, such as the ID, cannot be changed.
+func (s *Server) handleUpdateObject(collection string, readOnly ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		patch, err := decodeObject(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		for _, field := range append(readOnly, "id") {
+			delete(patch, field)
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		object, ok := s.collection(collection)[r.PathValue("id")]
+		if !ok {
+			writeError(w, http.StatusNotFound, fmt.Sprintf("%s %s not found", collection, r.PathValue("id")))
+			return
+		}
+		mergePatch(object, patch)
+		writeJSON(w, http.StatusOK, object)
+	}
+}
+
+func (s *Server) handleDeleteObject(collection string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		objects := s.collection(collection)
+		if _, ok := objects[r.PathValue("id")]; !ok {
+			writeError(w, http.StatusNotFound, fmt.Sprintf("%s %s not found", collection, r.PathValue("id")))
+			return
+		}
+		delete(objects, r.PathValue("id"))
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func decodeObject(r *http.Request) (map[string]any, error) {
+	var object map[string]any
+	if err := decodeJSON(r.Body, &object); err != nil {
+		return nil, fmt.Errorf("invalid request body: %w", err)
+	}
+	if object == nil {
+		object = map[string]any{}
+	}
+	return object, nil
+}
+
+// mergePatch applies a JSON merge patch (RFC 7386) to target: null values
+// remove keys, objects are merged recursively and anything else replaces.
+func mergePatch(target, patch map[string]any) {
+	for k, v := range patch {
+		if v == nil {
+			delete(target, k)
+			continue
+		}
+		if patchObject, ok := v.(map[string]any); ok {
+			if targetObject, ok := target[k].(map[string]any); ok {
+				mergePatch(targetObject, patchObject)
+				continue
+			}
+			merged := map[string]any{}
+			mergePatch(merged, patchObject)
+			target[k] = merged
+			continue
+		}
+		target[k] = v
+	}
+}
+
+// decodeJSON decodes numbers as json.Number, so they are stored and echoed
+// back exactly as sent.
+func decodeJSON(r io.Reader, v any) error {
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+// clone deep copies a JSON object.
+func clone(object map[string]any) map[string]any {
+	data, _ := json.Marshal(object)
+	var copied map[string]any
+	_ = decodeJSON(bytes.NewReader(data), &copied)
+	return copied
+}
+
+func now() string {
+	return time.Now().UTC().Format("2006-01-02T15:04:05.000Z")
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]any{"message": message, "code": status})
+}
@@ -0,0 +1,381 @@
+package fakeoystehr_test
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/masslight/terraform-provider-oystehr/internal/client"
+	"github.com/masslight/terraform-provider-oystehr/internal/retry"
+	"github.com/masslight/terraform-provider-oystehr/internal/testing/fakeoystehr"
+)
+
+func newClient(t *testing.T) (*client.Client, *fakeoystehr.Server) {
+	t.Helper()
+	server := fakeoystehr.New()
+	t.Cleanup(server.Close)
+	projectID := fakeoystehr.ProjectID
+	clientID := fakeoystehr.ClientID
+	clientSecret := fakeoystehr.ClientSecret
+	return client.New(&client.ClientConfig{
+		ProjectID:    &projectID,
+		ClientID:     &clientID,
+		ClientSecret: &clientSecret,
+		Endpoints:    &client.Endpoints{Base: &server.URL},
+		Retry: &retry.RetryConfig{
+			BaseBackoff: time.Millisecond,
+			MaxBackoff:  time.Millisecond,
+			MaxDuration: retry.MaxDurationDefault,
+			MaxAttempts: retry.MaxAttemptsDefault,
+		},
+	}), server
+}
+
+func TestUnauthorized(t *testing.T) {
+	server := fakeoystehr.New()
+	defer server.Close()
+	projectID := fakeoystehr.ProjectID
+	accessToken := "not-issued-by-the-server"
+	c := client.New(&client.ClientConfig{
+		ProjectID:   &projectID,
+		AccessToken: &accessToken,
+		Endpoints:   &client.Endpoints{Base: &server.URL},
+	})
+
+	_, err := c.Project.GetProject(t.Context())
+	var apiErr *client.APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusUnauthorized, apiErr.StatusCode)
+}
+
+func TestFhir(t *testing.T) {
+	c, server := newClient(t)
+	ctx := t.Context()
+
+	created, err := c.Fhir.CreateResource(ctx, "Patient", map[string]any{"active": true})
+	require.NoError(t, err)
+	id := created["id"].(string)
+	assert.Equal(t, "1", created["meta"].(map[string]any)["versionId"])
+
+	updated, err := c.Fhir.UpdateResource(ctx, "Patient", id, "1", map[string]any{"active": false})
+	require.NoError(t, err)
+	assert.Equal(t, "2", updated["meta"].(map[string]any)["versionId"])
+
+	// The version no longer matches
+	_, err = c.Fhir.UpdateResource(ctx, "Patient", id, "1", map[string]any{"active": true})
+	var apiErr *client.APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusPreconditionFailed, apiErr.StatusCode)
+
+	stored, ok := server.FhirResource("Patient", id)
+	require.True(t, ok)
+	assert.Equal(t, false, stored["active"])
+
+	require.NoError(t, c.Fhir.DeleteResource(ctx, "Patient", id))
+	_, err = c.Fhir.GetResource(ctx, "Patient", id)
+	assert.True(t, client.IsGone(err))
+	_, err = c.Fhir.GetResource(ctx, "Patient", "missing")
+	assert.True(t, client.IsNotFound(err))
+}
+
+func TestFhirTransaction(t *testing.T) {
+	c, server := newClient(t)
+	ctx := t.Context()
+
+	resources, err := c.Fhir.Transaction(ctx, []client.TransactionEntry{
+		{FullURL: "urn:uuid:org", Method: http.MethodPost, URL: "/Organization", Resource: map[string]any{"resourceType": "Organization"}},
+		{FullURL: "urn:uuid:loc", Method: http.MethodPost, URL: "/Location", Resource: map[string]any{
+			"resourceType":         "Location",
+			"managingOrganization": map[string]any{"reference": "urn:uuid:org"},
+		}},
+	})
+	require.NoError(t, err)
+	require.Len(t, resources, 2)
+	orgID := resources[0]["id"].(string)
+	location, ok := server.FhirResource("Location", resources[1]["id"].(string))
+	require.True(t, ok)
+	assert.Equal(t, "Organization/"+orgID, location["managingOrganization"].(map[string]any)["reference"])
+
+	// A failed entry rolls back the whole transaction
+	_, err = c.Fhir.Transaction(ctx, []client.TransactionEntry{
+		{Method: http.MethodDelete, URL: "/Organization/" + orgID},
+		{Method: http.MethodPut, URL: "/Location/" + resources[1]["id"].(string), IfMatch: `W/"2"`, Resource: map[string]any{"resourceType": "Location"}},
+	})
+	var apiErr *client.APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusPreconditionFailed, apiErr.StatusCode)
+	_, ok = server.FhirResource("Organization", orgID)
+	assert.True(t, ok)
+}
+
+func TestFhirConditional(t *testing.T) {
+	c, server := newClient(t)
+	ctx := t.Context()
+	identifier := []any{map[string]any{"system": "https://example.com/mrn", "value": "123"}}
+	query := "identifier=https://example.com/mrn|123"
+
+	created, ok, err := c.Fhir.ConditionalCreateResource(ctx, "Patient", query, map[string]any{"identifier": identifier})
+	require.NoError(t, err)
+	assert.True(t, ok)
+	adopted, ok, err := c.Fhir.ConditionalCreateResource(ctx, "Patient", query, map[string]any{"identifier": identifier})
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, created["id"], adopted["id"])
+
+	updated, err := c.Fhir.ConditionalUpdateResource(ctx, "Patient", query, "1", map[string]any{"active": true})
+	require.NoError(t, err)
+	assert.Equal(t, created["id"], updated["id"])
+	assert.Equal(t, "2", updated["meta"].(map[string]any)["versionId"])
+
+	// Ambiguous queries are rejected
+	server.AddFhirResource(map[string]any{"resourceType": "Patient", "identifier": identifier})
+	server.AddFhirResource(map[string]any{"resourceType": "Patient", "identifier": identifier})
+	_, _, err = c.Fhir.ConditionalCreateResource(ctx, "Patient", query, map[string]any{"identifier": identifier})
+	var apiErr *client.APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusPreconditionFailed, apiErr.StatusCode)
+}
+
+func TestFhirPatch(t *testing.T) {
+	c, server := newClient(t)
+	ctx := t.Context()
+	created, err := c.Fhir.CreateResource(ctx, "Patient", map[string]any{
+		"active": true,
+		"name":   []any{map[string]any{"family": "Smith"}},
+	})
+	require.NoError(t, err)
+	id := created["id"].(string)
+
+	patched, err := c.Fhir.PatchResource(ctx, "Patient", id, []client.PatchOperation{
+		{Op: "test", Path: "/name/0/family", Value: "Smith"},
+		{Op: "replace", Path: "/name/0/family", Value: "Jones"},
+		{Op: "add", Path: "/name/-", Value: map[string]any{"family": "Doe"}},
+		{Op: "remove", Path: "/active"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []any{map[string]any{"family": "Jones"}, map[string]any{"family": "Doe"}}, patched["name"])
+	assert.NotContains(t, patched, "active")
+	assert.Equal(t, "2", patched["meta"].(map[string]any)["versionId"])
+
+	// A failed test leaves the resource unchanged
+	_, err = c.Fhir.PatchResource(ctx, "Patient", id, []client.PatchOperation{
+		{Op: "test", Path: "/name/0/family", Value: "Smith"},
+		{Op: "replace", Path: "/name/0/family", Value: "Brown"},
+	})
+	var apiErr *client.APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusUnprocessableEntity, apiErr.StatusCode)
+	stored, _ := server.FhirResource("Patient", id)
+	assert.Equal(t, "2", stored["meta"].(map[string]any)["versionId"])
+
+	patched, err = c.Fhir.ConditionalPatchResource(ctx, "Patient", "_id="+id, []client.PatchOperation{
+		{Op: "add", Path: "/gender", Value: "female"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "female", patched["gender"])
+
+	// Falsy values are sent rather than dropped
+	patched, err = c.Fhir.PatchResource(ctx, "Patient", id, []client.PatchOperation{
+		{Op: "add", Path: "/active", Value: false},
+		{Op: "test", Path: "/active", Value: false},
+		{Op: "replace", Path: "/active", Value: true},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, true, patched["active"])
+}
+
+func TestFhirNumbers(t *testing.T) {
+	c, _ := newClient(t)
+	ctx := t.Context()
+	created, err := c.Fhir.CreateResource(ctx, "Observation", map[string]any{
+		"valueQuantity": map[string]any{"value": json.Number("1.50")},
+		"component":     []any{map[string]any{"valueInteger": json.Number("12345678901234567890")}},
+	})
+	require.NoError(t, err)
+	id := created["id"].(string)
+
+	// Numbers are returned exactly as stored
+	read, err := c.Fhir.GetResource(ctx, "Observation", id)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"value": json.Number("1.50")}, read["valueQuantity"])
+	assert.Equal(t, []any{map[string]any{"valueInteger": json.Number("12345678901234567890")}}, read["component"])
+
+	// Test operations compare numbers by value
+	_, err = c.Fhir.PatchResource(ctx, "Observation", id, []client.PatchOperation{
+		{Op: "test", Path: "/valueQuantity/value", Value: json.Number("1.5")},
+		{Op: "replace", Path: "/valueQuantity/value", Value: json.Number("2.25")},
+	})
+	require.NoError(t, err)
+}
+
+func TestInjectFault(t *testing.T) {
+	c, server := newClient(t)
+	server.InjectFault(fakeoystehr.Fault{
+		Method: http.MethodGet,
+		Path:   "/project",
+		Status: http.StatusServiceUnavailable,
+		Count:  2,
+	})
+
+	project, err := c.Project.GetProject(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, fakeoystehr.ProjectID, *project.ID)
+
+	attempts := 0
+	for _, r := range server.Requests() {
+		if r.Path == "/project/v1/project" {
+			attempts++
+		}
+	}
+	assert.Equal(t, 3, attempts)
+}
+
+func TestZambdaUpload(t *testing.T) {
+	c, server := newClient(t)
+	ctx := t.Context()
+	source := filepath.Join(t.TempDir(), "zambda.zip")
+	require.NoError(t, os.WriteFile(source, []byte("source"), 0o600))
+
+	name := "zambda"
+	zambda, err := c.Zambda.CreateZambda(ctx, &client.ZambdaFunction{Name: &name})
+	require.NoError(t, err)
+	require.NoError(t, c.Zambda.UploadZambdaSource(ctx, *zambda.ID, source))
+
+	zambda, err = c.Zambda.GetZambda(ctx, *zambda.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Active", *zambda.Status)
+	require.NotNil(t, zambda.FileInfo)
+	assert.Equal(t, "zambda.zip", *zambda.FileInfo.Name)
+	assert.Equal(t, fmt.Sprintf("%x", sha256.Sum256([]byte("source"))), *zambda.FileInfo.Checksum)
+
+	data, ok := server.Upload("zambda/" + *zambda.ID + "/zambda.zip")
+	require.True(t, ok)
+	assert.Equal(t, "source", string(data))
+}
+
+func TestZ3Object(t *testing.T) {
+	c, _ := newClient(t)
+	ctx := t.Context()
+	source := filepath.Join(t.TempDir(), "object.txt")
+	require.NoError(t, os.WriteFile(source, []byte("object"), 0o600))
+
+	name := "bucket"
+	_, err := c.Z3.CreateBucket(ctx, &client.Bucket{Name: &name})
+	require.NoError(t, err)
+	require.NoError(t, c.Z3.UploadObject(ctx, name, "dir/object.txt", source))
+
+	object, err := c.Z3.ListObject(ctx, name, "dir/object.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "bucket", *object.Bucket)
+	assert.Equal(t, "dir/object.txt", *object.Key)
+
+	// Buckets must be emptied before they are deleted
+	assert.Error(t, c.Z3.DeleteBucket(ctx, name))
+	require.NoError(t, c.Z3.DeleteObject(ctx, name, "dir/object.txt"))
+	_, err = c.Z3.ListObject(ctx, name, "dir/object.txt")
+	assert.True(t, client.IsNotFound(err))
+	require.NoError(t, c.Z3.DeleteBucket(ctx, name))
+}
+
+func TestM2MRoles(t *testing.T) {
+	c, _ := newClient(t)
+	ctx := t.Context()
+
+	roleName := "role"
+	role, err := c.Role.CreateRole(ctx, &client.Role{Name: &roleName, AccessPolicy: &client.AccessPolicy{Rule: []client.Rule{}}})
+	require.NoError(t, err)
+
+	m2mName := "m2m"
+	m2m, err := c.M2M.CreateM2M(ctx, &client.M2M{Name: &m2mName, Roles: []string{*role.ID}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{*role.ID}, m2m.Roles)
+	assert.NotEmpty(t, *m2m.ClientID)
+
+	_, err = c.M2M.CreateM2M(ctx, &client.M2M{Name: &m2mName, Roles: []string{"missing"}})
+	assert.Error(t, err)
+}
+
+func TestFhirValidate(t *testing.T) {
+	c, server := newClient(t)
+	ctx := t.Context()
+
+	issues, err := c.Fhir.ValidateResource(ctx, "Questionnaire", map[string]any{
+		"resourceType": "Questionnaire",
+		"status":       "active",
+		"item":         []any{map[string]any{"linkId": "1", "type": "group", "item": []any{map[string]any{"linkId": "1.1"}}}},
+	}, "")
+	require.NoError(t, err)
+	require.Len(t, issues, 1)
+	assert.Equal(t, "error", issues[0].Severity)
+	assert.Equal(t, []string{"Questionnaire.item[0].item[0]"}, issues[0].Expression)
+
+	issues, err = c.Fhir.ValidateResource(ctx, "Patient", map[string]any{"resourceType": "Patient"}, "")
+	require.NoError(t, err)
+	require.Len(t, issues, 1)
+	assert.Equal(t, "information", issues[0].Severity)
+
+	// Profiles must be known
+	issues, err = c.Fhir.ValidateResource(ctx, "Patient", map[string]any{"resourceType": "Patient"}, "https://example.com/profile")
+	require.NoError(t, err)
+	require.Len(t, issues, 1)
+	assert.Equal(t, "not-found", issues[0].Code)
+	server.AddFhirResource(map[string]any{"resourceType": "StructureDefinition", "url": "https://example.com/profile"})
+	issues, err = c.Fhir.ValidateResource(ctx, "Patient", map[string]any{"resourceType": "Patient"}, "https://example.com/profile")
+	require.NoError(t, err)
+	assert.Equal(t, "information", issues[0].Severity)
+}
+
+func TestFhirTerminology(t *testing.T) {
+	c, server := newClient(t)
+	ctx := t.Context()
+
+	codeSystemID := server.AddFhirResource(map[string]any{
+		"resourceType": "CodeSystem",
+		"url":          "https://example.com/colors",
+		"concept": []any{
+			map[string]any{"code": "red", "display": "Red", "concept": []any{map[string]any{"code": "crimson"}}},
+			map[string]any{"code": "blue"},
+		},
+	})
+	valueSetID := server.AddFhirResource(map[string]any{
+		"resourceType": "ValueSet",
+		"compose": map[string]any{"include": []any{
+			map[string]any{"system": "https://example.com/colors"},
+			map[string]any{"system": "https://example.com/shapes", "concept": []any{map[string]any{"code": "circle"}}},
+		}},
+	})
+
+	expanded, err := c.Fhir.ExpandValueSet(ctx, valueSetID)
+	require.NoError(t, err)
+	expansion, _ := expanded["expansion"].(map[string]any)
+	assert.Equal(t, json.Number("4"), expansion["total"])
+
+	valid, _, err := c.Fhir.ValidateCode(ctx, "CodeSystem", codeSystemID, "", "crimson")
+	require.NoError(t, err)
+	assert.True(t, valid)
+	valid, message, err := c.Fhir.ValidateCode(ctx, "ValueSet", valueSetID, "https://example.com/colors", "circle")
+	require.NoError(t, err)
+	assert.False(t, valid)
+	assert.Contains(t, message, "Unknown code")
+	valid, _, err = c.Fhir.ValidateCode(ctx, "ValueSet", valueSetID, "https://example.com/shapes", "circle")
+	require.NoError(t, err)
+	assert.True(t, valid)
+
+	// Includes of unknown code systems cannot be expanded
+	unknownID := server.AddFhirResource(map[string]any{
+		"resourceType": "ValueSet",
+		"compose":      map[string]any{"include": []any{map[string]any{"system": "https://example.com/unknown"}}},
+	})
+	_, err = c.Fhir.ExpandValueSet(ctx, unknownID)
+	var apiErr *client.APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+}
@@ -0,0 +1,151 @@
+package fakeoystehr
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+const (
+	CollectionZ3Buckets = "bucket"
+	// CollectionZ3Objects is keyed by "<bucket>/<key>".
+	CollectionZ3Objects = "object"
+)
+
+var bucketNameRegexp = regexp.MustCompile(`^[a-z0-9][a-z0-9.-]{1,61}[a-z0-9]$`)
+
+func (s *Server) registerZ3() {
+	s.mux.HandleFunc("GET /z3/v1", s.handleListBuckets)
+	s.mux.HandleFunc("PUT /z3/v1/{bucket}", s.handleCreateBucket)
+	s.mux.HandleFunc("DELETE /z3/v1/{bucket}", s.handleDeleteBucket)
+	s.mux.HandleFunc("GET /z3/v1/{bucket}/{key...}", s.handleListObjects)
+	s.mux.HandleFunc("POST /z3/v1/{bucket}/{key...}", s.handleObjectAction)
+	s.mux.HandleFunc("DELETE /z3/v1/{bucket}/{key...}", s.handleDeleteZ3Object)
+	s.uploadHooks["z3"] = s.z3Uploaded
+}
+
+func (s *Server) handleListBuckets(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	buckets := make([]any, 0, len(s.collection(CollectionZ3Buckets)))
+	for _, bucket := range s.collection(CollectionZ3Buckets) {
+		buckets = append(buckets, bucket)
+	}
+	writeJSON(w, http.StatusOK, buckets)
+}
+
+func (s *Server) handleCreateBucket(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("bucket")
+	if !bucketNameRegexp.MatchString(name) {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid bucket name %q", name))
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	buckets := s.collection(CollectionZ3Buckets)
+	if _, ok := buckets[name]; ok {
+		writeError(w, http.StatusConflict, fmt.Sprintf("bucket %s already exists", name))
+		return
+	}
+	bucket := map[string]any{"id": uuid.NewString(), "name": name}
+	buckets[name] = bucket
+	writeJSON(w, http.StatusOK, bucket)
+}
+
+// handleDeleteBucket responds with an empty body, and refuses to delete
+// buckets that still contain objects, as S3 does.
+func (s *Server) handleDeleteBucket(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("bucket")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	buckets := s.collection(CollectionZ3Buckets)
+	if _, ok := buckets[name]; !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("bucket %s not found", name))
+		return
+	}
+	for key := range s.collection(CollectionZ3Objects) {
+		if strings.HasPrefix(key, name+"/") {
+			writeError(w, http.StatusConflict, fmt.Sprintf("bucket %s is not empty", name))
+			return
+		}
+	}
+	delete(buckets, name)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleListObjects lists the object with the exact key, if any. Keys in the
+// response are prefixed with the bucket name.
+func (s *Server) handleListObjects(w http.ResponseWriter, r *http.Request) {
+	key := path.Join(r.PathValue("bucket"), r.PathValue("key"))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.bucketExists(w, r.PathValue("bucket")) {
+		return
+	}
+	objects := []any{}
+	if object, ok := s.collection(CollectionZ3Objects)[key]; ok {
+		objects = append(objects, object)
+	}
+	writeJSON(w, http.StatusOK, objects)
+}
+
+func (s *Server) handleObjectAction(w http.ResponseWriter, r *http.Request) {
+	body, err := decodeObject(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if body["action"] != "upload" {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("unsupported action %v", body["action"]))
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.bucketExists(w, r.PathValue("bucket")) {
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"signedUrl": s.signedURL(path.Join("z3", r.PathValue("bucket"), r.PathValue("key"))),
+	})
+}
+
+func (s *Server) handleDeleteZ3Object(w http.ResponseWriter, r *http.Request) {
+	key := path.Join(r.PathValue("bucket"), r.PathValue("key"))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.bucketExists(w, r.PathValue("bucket")) {
+		return
+	}
+	// Deleting a missing object succeeds, as in S3
+	delete(s.collection(CollectionZ3Objects), key)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// z3Uploaded creates or replaces an object once its content is uploaded.
+func (s *Server) z3Uploaded(key string, data []byte) {
+	key = strings.TrimPrefix(key, "z3/")
+	s.collection(CollectionZ3Objects)[key] = map[string]any{
+		"key":          key,
+		"size":         len(data),
+		"lastModified": now(),
+	}
+}
+
+// bucketExists writes a 404 response if a bucket does not exist. The caller
+// must hold s.mu.
+func (s *Server) bucketExists(w http.ResponseWriter, bucket string) bool {
+	if _, ok := s.collection(CollectionZ3Buckets)[bucket]; !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("bucket %s not found", bucket))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,109 @@
+package fakeoystehr
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+const (
+	CollectionSecrets = "secret"
+	CollectionZambdas = "zambda"
+)
+
+func (s *Server) registerZambda() {
+	s.mux.HandleFunc("POST /zambda/v1/zambda", s.handleCreateZambda)
+	s.mux.HandleFunc("GET /zambda/v1/zambda/{id}", s.handleGetObject(CollectionZambdas))
+	s.mux.HandleFunc("PATCH /zambda/v1/zambda/{id}", s.handleUpdateObject(CollectionZambdas))
+	s.mux.HandleFunc("DELETE /zambda/v1/zambda/{id}", s.handleDeleteObject(CollectionZambdas))
+	s.mux.HandleFunc("POST /zambda/v1/zambda/{id}/s3-upload", s.handleZambdaUpload)
+	s.uploadHooks["zambda"] = s.zambdaUploaded
+
+	s.mux.HandleFunc("POST /zambda/v1/secret", s.handleSetSecret)
+	s.mux.HandleFunc("GET /zambda/v1/secret/{id}", s.handleGetObject(CollectionSecrets))
+	s.mux.HandleFunc("DELETE /zambda/v1/secret/{id}", s.handleDeleteObject(CollectionSecrets))
+}
+
+func (s *Server) handleCreateZambda(w http.ResponseWriter, r *http.Request) {
+	zambda, err := decodeObject(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if name, _ := zambda["name"].(string); name == "" {
+		writeError(w, http.StatusBadRequest, "name is required")
+		return
+	}
+	zambda["id"] = uuid.NewString()
+	// Deployment is instantaneous, so zambdas are active as soon as they exist
+	zambda["status"] = "Active"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.collection(CollectionZambdas)[zambda["id"].(string)] = zambda
+	writeJSON(w, http.StatusOK, zambda)
+}
+
+func (s *Server) handleZambdaUpload(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	body, err := decodeObject(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	filename, _ := body["filename"].(string)
+	if filename == "" || strings.Contains(filename, "/") {
+		writeError(w, http.StatusBadRequest, "invalid filename")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.collection(CollectionZambdas)[id]; !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("zambda %s not found", id))
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"signedUrl": s.signedURL(path.Join("zambda", id, filename))})
+}
+
+// zambdaUploaded updates the file info of a zambda once its source is
+// uploaded.
+func (s *Server) zambdaUploaded(key string, data []byte) {
+	id := path.Base(path.Dir(key))
+	zambda, ok := s.collection(CollectionZambdas)[id]
+	if !ok {
+		return
+	}
+	zambda["fileInfo"] = map[string]any{
+		"name":         path.Base(key),
+		"size":         len(data),
+		"lastModified": now(),
+		"checksum":     fmt.Sprintf("%x", sha256.Sum256(data)),
+	}
+}
+
+func (s *Server) handleSetSecret(w http.ResponseWriter, r *http.Request) {
+	secret, err := decodeObject(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	name, _ := secret["name"].(string)
+	if name == "" {
+		writeError(w, http.StatusBadRequest, "name is required")
+		return
+	}
+	if _, ok := secret["value"].(string); !ok {
+		writeError(w, http.StatusBadRequest, "value is required")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.collection(CollectionSecrets)[name] = secret
+	writeJSON(w, http.StatusOK, secret)
+}